@@ -3,45 +3,196 @@ package options
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	"github.com/cloudpilot-ai/priceserver/pkg/apis"
 	"github.com/cloudpilot-ai/priceserver/pkg/client"
+	"github.com/cloudpilot-ai/priceserver/pkg/config"
 )
 
 type Options struct {
-	AWSGlobalAK string
-	AWSGlobalSK string
-	AWSCNAK     string
-	AWSCNSK     string
+	// ConfigFile is optional, Config holds the defaults when it is not set
+	ConfigFile string
+	Config     *config.Config
+
+	// Offline serves the snapshots of the providers without calling any cloud api, the credentials are not
+	// needed and the providers are enabled when their snapshots exist
+	Offline            bool
+	OfflineSnapshotDir string
+
+	// EnabledProviders are the names of the built-in providers to serve
+	EnabledProviders sets.Set[string]
+
+	// AWSCredentialConfig is optional, the aws provider is enabled when any partition is enabled
+	AWSCredentialConfig client.AWSCredentialConfig
+
+	// AlibabaCloudCredentialConfig is optional, the alibabacloud provider is enabled when any credential is set
+	AlibabaCloudCredentialConfig client.AlibabaCloudCredentialConfig
+
+	// GCPProjectID is optional, the gcp provider is enabled only when it is set
+	GCPProjectID string
+
+	// AzureConfig is optional, the azure provider is enabled only when the subscription id is set
+	AzureConfig client.AzureConfig
+
+	// TencentCloudAKSKPool is optional, the tencentcloud provider is enabled only when it is set
+	TencentCloudAKSKPool    []client.AKSKPair
+	TencentCloudCVMEndpoint string
+
+	// HuaweiCloudAKSKPool is optional, the huaweicloud provider is enabled only when it is set
+	HuaweiCloudAKSKPool    []client.AKSKPair
+	HuaweiCloudBSSEndpoint string
+
+	// OCIConfigFile is optional, the oci provider is enabled only when it is set
+	OCIConfigFile    string
+	OCIConfigProfile string
 
-	AlibabaCloudAKSKPool []client.AKSKPair
+	// VolcengineAKSKPool is optional, the volcengine provider is enabled only when it is set
+	VolcengineAKSKPool []client.AKSKPair
+
+	// StaticPriceSheetPaths is optional, the static provider is enabled only when it is set
+	StaticPriceSheetPaths []string
+
+	// Plugins are the out-of-process providers
+	Plugins []client.PluginConfig
 }
 
 func NewOptions() *Options {
-	return &Options{}
+	return &Options{EnabledProviders: sets.Set[string]{}}
 }
 
 func (o *Options) ApplyAndValidate() error {
-	o.AWSGlobalAK = os.Getenv(apis.AWSGlobalAKEnv)
-	if o.AWSGlobalAK == "" {
-		return fmt.Errorf("aws global access key is not set")
+	plugins, err := client.ParsePluginConfigs(os.Getenv(apis.PluginsEnv))
+	if err != nil {
+		return err
 	}
-	o.AWSGlobalSK = os.Getenv(apis.AWSGlobalSKEnv)
-	if o.AWSGlobalSK == "" {
-		return fmt.Errorf("aws global secret key is not set")
+	o.Plugins = plugins
+
+	o.ConfigFile = os.Getenv(apis.ConfigFileEnv)
+	if o.Config, err = o.LoadConfig(); err != nil {
+		return err
 	}
-	o.AWSCNAK = os.Getenv(apis.AWSCNAKEnv)
-	if o.AWSCNAK == "" {
-		return fmt.Errorf("aws china access key is not set")
+
+	if value := os.Getenv(apis.OfflineEnv); value != "" {
+		offline, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: %v", apis.OfflineEnv, value, err)
+		}
+		o.Offline = offline
 	}
-	o.AWSCNSK = os.Getenv(apis.AWSCNSKEnv)
-	if o.AWSCNSK == "" {
-		return fmt.Errorf("aws china secret key is not set")
+	o.OfflineSnapshotDir = os.Getenv(apis.OfflineSnapshotDirEnv)
+
+	awsCredentialConfig, err := client.ExtractAWSCredentialConfig()
+	if err != nil {
+		return err
 	}
-	o.AlibabaCloudAKSKPool = client.ExtractAlibabaCloudAKSKPool()
-	if len(o.AlibabaCloudAKSKPool) == 0 {
-		return fmt.Errorf("alibaba cloud access key and secret key pool is not set")
+	o.AWSCredentialConfig = awsCredentialConfig
+	alibabaCloudCredentialConfig, err := client.ExtractAlibabaCloudCredentialConfig()
+	if err != nil {
+		return err
+	}
+	o.AlibabaCloudCredentialConfig = alibabaCloudCredentialConfig
+	o.GCPProjectID = os.Getenv(apis.GCPProjectIDEnv)
+	o.AzureConfig = client.ExtractAzureConfig()
+	o.TencentCloudAKSKPool = client.ExtractTencentCloudAKSKPool()
+	o.TencentCloudCVMEndpoint = os.Getenv(apis.TencentCloudCVMEndpointEnv)
+	o.HuaweiCloudAKSKPool = client.ExtractHuaweiCloudAKSKPool()
+	o.HuaweiCloudBSSEndpoint = os.Getenv(apis.HuaweiCloudBSSEndpointEnv)
+	o.OCIConfigFile = os.Getenv(apis.OCIConfigFileEnv)
+	o.OCIConfigProfile = os.Getenv(apis.OCIConfigProfileEnv)
+	o.VolcengineAKSKPool = client.ExtractVolcengineAKSKPool()
+	for _, path := range strings.Split(os.Getenv(apis.StaticPriceSheetPathsEnv), ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			o.StaticPriceSheetPaths = append(o.StaticPriceSheetPaths, path)
+		}
+	}
+
+	for _, p := range []struct {
+		name       string
+		env        string
+		configured bool
+	}{
+		{apis.AWSProviderName, apis.AWSEnabledEnv, o.AWSCredentialConfig.Enabled()},
+		{apis.AlibabaCloudProviderName, apis.AlibabaCloudEnabledEnv, o.AlibabaCloudCredentialConfig.Enabled()},
+		{apis.GCPProviderName, apis.GCPEnabledEnv, o.GCPProjectID != ""},
+		{apis.AzureProviderName, apis.AzureEnabledEnv, o.AzureConfig.SubscriptionID != ""},
+		{apis.TencentCloudProviderName, apis.TencentCloudEnabledEnv, len(o.TencentCloudAKSKPool) != 0},
+		{apis.HuaweiCloudProviderName, apis.HuaweiCloudEnabledEnv, len(o.HuaweiCloudAKSKPool) != 0},
+		{apis.OCIProviderName, apis.OCIEnabledEnv, o.OCIConfigFile != ""},
+		{apis.VolcengineProviderName, apis.VolcengineEnabledEnv, len(o.VolcengineAKSKPool) != 0},
+		{apis.StaticProviderName, apis.StaticEnabledEnv, len(o.StaticPriceSheetPaths) != 0},
+	} {
+		// The static price sheets are local files, the other providers are served from the snapshots offline
+		if o.Offline && p.name != apis.StaticProviderName {
+			p.configured = client.HasSnapshot(p.name, o.OfflineSnapshotDir)
+		}
+		if err := o.applyProviderEnabled(p.name, p.env, p.configured); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
+
+// LoadConfig loads the config file, the providers in it should be the built-in providers or the plugins.
+func (o *Options) LoadConfig() (*config.Config, error) {
+	if o.ConfigFile == "" {
+		return config.Default(), nil
+	}
+
+	c, err := config.Load(o.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	knownProviders := sets.New(apis.AWSProviderName, apis.AlibabaCloudProviderName, apis.GCPProviderName,
+		apis.AzureProviderName, apis.TencentCloudProviderName, apis.HuaweiCloudProviderName, apis.OCIProviderName,
+		apis.VolcengineProviderName, apis.StaticProviderName)
+	for _, p := range o.Plugins {
+		knownProviders.Insert(p.Name)
+	}
+	for name := range c.Providers {
+		if !knownProviders.Has(name) {
+			return nil, fmt.Errorf("invalid config file %s: unknown provider %s", o.ConfigFile, name)
+		}
+	}
+	return c, nil
+}
+
+// PluginEnabled returns whether the plugin is served, the plugins are disabled only by the config file.
+func (o *Options) PluginEnabled(name string) bool {
+	enabled, ok := o.Config.ProviderEnabled(name)
+	return !ok || enabled
+}
+
+// ProviderEnabled returns whether the built-in provider should be served.
+func (o *Options) ProviderEnabled(name string) bool {
+	return o.EnabledProviders.Has(name)
+}
+
+// applyProviderEnabled enables the provider when it is configured, unless it is disabled explicitly by the env
+// or the config file, the env takes precedence.
+func (o *Options) applyProviderEnabled(name, env string, configured bool) error {
+	enabled, source := configured, ""
+	if configEnabled, ok := o.Config.ProviderEnabled(name); ok {
+		enabled, source = configEnabled, "the config file"
+	}
+	if value := os.Getenv(env); value != "" {
+		var err error
+		enabled, err = strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: %v", env, value, err)
+		}
+		source = env
+	}
+	if enabled && !configured && source != "" {
+		return fmt.Errorf("provider %s is enabled by %s but it is not configured", name, source)
+	}
+
+	if enabled {
+		o.EnabledProviders.Insert(name)
+	}
+	return nil
+}
@@ -2,20 +2,42 @@ package app
 
 import (
 	"context"
+	"errors"
 	"flag"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
 	"golang.org/x/sync/errgroup"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	cliflag "k8s.io/component-base/cli/flag"
 	"k8s.io/klog"
 
 	"github.com/cloudpilot-ai/priceserver/cmd/app/options"
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+	"github.com/cloudpilot-ai/priceserver/pkg/apiserver/auth"
 	"github.com/cloudpilot-ai/priceserver/pkg/apiserver/router"
+	"github.com/cloudpilot-ai/priceserver/pkg/apiserver/server"
 	"github.com/cloudpilot-ai/priceserver/pkg/client"
+	"github.com/cloudpilot-ai/priceserver/pkg/config"
+	"github.com/cloudpilot-ai/priceserver/pkg/health"
+	"github.com/cloudpilot-ai/priceserver/pkg/metrics"
+	"github.com/cloudpilot-ai/priceserver/pkg/refresh"
+	"github.com/cloudpilot-ai/priceserver/pkg/replication"
+	"github.com/cloudpilot-ai/priceserver/pkg/scheduler"
 	"github.com/cloudpilot-ai/priceserver/pkg/version"
 )
 
+// shutdownTimeout bounds the graceful shutdown, it should be shorter than the termination grace period of the pod
+const shutdownTimeout = time.Second * 20
+
 func NewPriceServerCommand(ctx context.Context) *cobra.Command {
 	opts := options.NewOptions()
 
@@ -44,37 +66,416 @@ func NewPriceServerCommand(ctx context.Context) *cobra.Command {
 
 func run(ctx context.Context, opts *options.Options) error {
 	klog.Infof("Start cloudpilot-agent, version: %s, commit: %s...", version.Get().GitVersion, version.Get().GitCommit)
-	var (
-		awsPriceClient     *client.AWSPriceClient
-		alibabaCloudClient *client.AlibabaCloudPriceClient
-	)
+	// With the leader election, the prices are refreshed after the leadership is taken, so the followers never call
+	// the cloud apis
+	var kubeClient kubernetes.Interface
+	if opts.Config.LeaderElection.Enabled {
+		if opts.Offline {
+			klog.Warningf("The leader election is ignored in the offline mode")
+		} else {
+			restConfig, err := rest.InClusterConfig()
+			if err != nil {
+				klog.Errorf("Failed to load the in-cluster config for the leader election: %v", err)
+				return err
+			}
+			if kubeClient, err = kubernetes.NewForConfig(restConfig); err != nil {
+				klog.Errorf("Failed to create the kubernetes client: %v", err)
+				return err
+			}
+		}
+	}
+	initialUpdate := kubeClient == nil
+	builtinProviders := []struct {
+		name    string
+		service string
+		new     func() (client.Provider, error)
+	}{
+		{apis.AlibabaCloudProviderName, apis.AlibabaCloudServiceName, func() (client.Provider, error) {
+			return client.NewAlibabaCloudPriceClient(ctx, opts.AlibabaCloudCredentialConfig, initialUpdate)
+		}},
+		{apis.AWSProviderName, apis.AWSServiceName, func() (client.Provider, error) {
+			return client.NewAWSPriceClient(ctx, opts.AWSCredentialConfig, initialUpdate)
+		}},
+		{apis.GCPProviderName, apis.GCPServiceName, func() (client.Provider, error) {
+			return client.NewGCPPriceClient(ctx, opts.GCPProjectID, initialUpdate)
+		}},
+		{apis.AzureProviderName, apis.AzureServiceName, func() (client.Provider, error) {
+			return client.NewAzurePriceClient(ctx, opts.AzureConfig, initialUpdate)
+		}},
+		{apis.TencentCloudProviderName, apis.TencentCloudServiceName, func() (client.Provider, error) {
+			return client.NewTencentCloudPriceClient(ctx, opts.TencentCloudAKSKPool, opts.TencentCloudCVMEndpoint,
+				initialUpdate)
+		}},
+		{apis.HuaweiCloudProviderName, apis.HuaweiCloudServiceName, func() (client.Provider, error) {
+			return client.NewHuaweiCloudPriceClient(ctx, opts.HuaweiCloudAKSKPool, opts.HuaweiCloudBSSEndpoint,
+				initialUpdate)
+		}},
+		{apis.OCIProviderName, apis.OCIServiceName, func() (client.Provider, error) {
+			return client.NewOCIPriceClient(ctx, opts.OCIConfigFile, opts.OCIConfigProfile, initialUpdate)
+		}},
+		{apis.VolcengineProviderName, apis.VolcengineServiceName, func() (client.Provider, error) {
+			return client.NewVolcenginePriceClient(ctx, opts.VolcengineAKSKPool, initialUpdate)
+		}},
+		{apis.StaticProviderName, apis.StaticServiceName, func() (client.Provider, error) {
+			return client.NewStaticPriceClient(opts.StaticPriceSheetPaths)
+		}},
+	}
 
 	timeStart := time.Now()
-	eg, ctx := errgroup.WithContext(ctx)
-	eg.Go(func() (err error) {
-		alibabaCloudClient, err = client.NewAlibabaCloudPriceClient(opts.AlibabaCloudAKSKPool, true)
+	providers := make([]client.Provider, len(builtinProviders))
+	// The ctx of errgroup.WithContext is canceled once Wait returns, so it must not be passed to Run
+	var eg errgroup.Group
+	for i, p := range builtinProviders {
+		if !opts.ProviderEnabled(p.name) {
+			continue
+		}
+		newProvider := p.new
+		if opts.Offline && p.name != apis.StaticProviderName {
+			newProvider = func() (client.Provider, error) {
+				return client.NewSnapshotPriceClient(p.name, opts.OfflineSnapshotDir)
+			}
+		}
+		eg.Go(func() (err error) {
+			providers[i], err = newProvider()
+			return err
+		})
+	}
+	if err := eg.Wait(); err != nil {
 		return err
-	})
+	}
+
+	klog.Infof("Init price client cost: %v", time.Since(timeStart))
+
+	registry := client.NewProviderRegistry()
+	for i, p := range builtinProviders {
+		if !opts.ProviderEnabled(p.name) {
+			klog.Infof("Provider %s is not enabled", p.name)
+			if err := registry.RegisterDisabled(p.name, p.service); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := registry.Register(p.name, p.service, providers[i]); err != nil {
+			return err
+		}
+	}
 
-	eg.Go(func() (err error) {
-		awsPriceClient, err = client.NewAWSPriceClient(opts.AWSGlobalAK, opts.AWSGlobalSK, opts.AWSCNAK, opts.AWSCNSK, true)
+	// The plugins are connected lazily and refreshed in Run, they never block the startup
+	plugins := opts.Plugins
+	if opts.Offline && len(plugins) != 0 {
+		klog.Warningf("The plugins are not served in the offline mode")
+		plugins = nil
+	}
+	for _, pluginConfig := range plugins {
+		if !opts.PluginEnabled(pluginConfig.Name) {
+			klog.Infof("Plugin %s is disabled by the config file", pluginConfig.Name)
+			continue
+		}
+		pluginClient, err := client.NewPluginPriceClient(pluginConfig)
+		if err != nil {
+			return err
+		}
+		if err := registry.Register(pluginConfig.Name, pluginConfig.Service, pluginClient); err != nil {
+			return err
+		}
+	}
+
+	metrics.Registry.MustRegister(metrics.NewInstanceTypeCollector(func() map[string]map[string]int {
+		ret := map[string]map[string]int{}
+		for _, p := range registry.List() {
+			if counter, ok := p.Provider.(client.InstanceTypeCounter); ok {
+				ret[p.Name] = counter.InstanceTypeCounts()
+			}
+		}
+		return ret
+	}))
+
+	// The server config is read before the reloading starts, the changes of it need a restart
+	serverConfig := opts.Config.Server
+	configureProviders(registry, opts.Config)
+	corsHandler := router.NewCORS(serverConfig.CORS)
+	authenticator, err := auth.NewAuthenticator(opts.Config.Auth)
+	if err != nil {
+		klog.Errorf("Failed to load the api keys: %v", err)
 		return err
+	}
+	go authenticator.Watch(ctx)
+	checker := health.NewChecker(registry, opts.Config.Health)
+	jobScheduler := scheduler.New()
+	refreshManager := refresh.NewManager(ctx, registry, jobScheduler)
+	serverRouter := router.NewPriceServerRouter(registry, router.Options{
+		Offline:   opts.Offline,
+		CORS:      corsHandler,
+		Auth:      authenticator,
+		Health:    checker,
+		Refresh:   refreshManager,
+		Scheduler: jobScheduler,
 	})
+	go reloadConfigOnSIGHUP(ctx, opts, registry, corsHandler, authenticator, checker, jobScheduler)
 
-	if err := eg.Wait(); err != nil {
-		return err
+	// The refreshes in flight are canceled by the ctx, and the Run loops are waited for on shutdown
+	var runners sync.WaitGroup
+	if opts.Offline {
+		klog.Infof("Serve the price snapshots in the offline mode, the prices are never refreshed")
+	} else {
+		var local, replicated []*client.RegisteredProvider
+		for _, p := range registry.List() {
+			if registrar, ok := p.Provider.(client.JobRegistrar); ok {
+				if err := registrar.RegisterJobs(jobScheduler); err != nil {
+					klog.Errorf("Failed to register the jobs of provider %s: %v", p.Name, err)
+					return err
+				}
+			}
+			watchSecretFiles(ctx, p)
+			// The price sheets are local files, so every replica reloads its own
+			if _, ok := p.Provider.(*client.StaticPriceClient); ok || kubeClient == nil {
+				local = append(local, p)
+			} else {
+				replicated = append(replicated, p)
+			}
+		}
+
+		if kubeClient == nil {
+			runners.Add(1)
+			go func() {
+				defer runners.Done()
+				runRefreshes(ctx, local, checker, jobScheduler)
+			}()
+		} else {
+			replica, err := newReplica(kubeClient, opts.Config.LeaderElection, replicated, func(ctx context.Context) {
+				runRefreshes(ctx, replicated, checker, jobScheduler)
+			})
+			if err != nil {
+				return err
+			}
+			refreshManager.SetLeadership(replica)
+			checker.SetLeadership(func(provider string) bool {
+				refreshes, _ := replica.Refreshes(provider)
+				return refreshes
+			})
+
+			runners.Add(2)
+			go func() {
+				defer runners.Done()
+				runRefreshes(ctx, local, checker, nil)
+			}()
+			go func() {
+				defer runners.Done()
+				replica.Run(ctx)
+			}()
+		}
 	}
 
-	klog.Infof("Init price client cost: %v", time.Since(timeStart))
+	servers := []*http.Server{{
+		Addr:    serverConfig.ListenAddress,
+		Handler: serverRouter,
+	}}
+	if serverConfig.TLS.Enabled() {
+		certificateReloader, err := server.NewCertificateReloader(serverConfig.TLS)
+		if err != nil {
+			klog.Errorf("Failed to load the tls files: %v", err)
+			return err
+		}
+		go certificateReloader.Watch(ctx)
+		servers[0].TLSConfig = certificateReloader.TLSConfig()
+	}
+	if serverConfig.HealthListenAddress != "" {
+		servers = append(servers, &http.Server{
+			Addr:    serverConfig.HealthListenAddress,
+			Handler: router.NewHealthRouter(checker),
+		})
+	}
+
+	serveErr := make(chan error, len(servers))
+	for _, s := range servers {
+		go func() {
+			if s.TLSConfig != nil {
+				klog.Infof("Serve priceserver on %s over https", s.Addr)
+				// The certificate is taken from the TLSConfig, so the files are not passed here
+				serveErr <- s.ListenAndServeTLS("", "")
+			} else {
+				klog.Infof("Serve priceserver on %s", s.Addr)
+				serveErr <- s.ListenAndServe()
+			}
+		}()
+	}
 
-	serverRouter := router.NewPriceServerRouter(awsPriceClient, alibabaCloudClient)
+	var serveFailure error
+	select {
+	case serveFailure = <-serveErr:
+		klog.Errorf("Failed to start priceserver router: %v", serveFailure)
+	case <-ctx.Done():
+	}
 
-	go awsPriceClient.Run(ctx)
-	go alibabaCloudClient.Run(ctx)
-	if err := serverRouter.Run(":8080"); err != nil {
-		klog.Fatalf("Failed to start priceserver router: %v", err)
+	klog.Infof("Shutting down priceserver...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	for _, s := range servers {
+		if err := s.Shutdown(shutdownCtx); err != nil {
+			klog.Errorf("Failed to shutdown priceserver router on %s: %v", s.Addr, err)
+			return err
+		}
+	}
+	if serveFailure != nil {
+		return serveFailure
+	}
+	for range servers {
+		if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+			klog.Errorf("Failed to serve priceserver router: %v", err)
+		}
 	}
 
-	<-ctx.Done()
+	if !waitWithContext(shutdownCtx, &runners) {
+		klog.Warningf("The refreshes of the providers are not stopped in %v", shutdownTimeout)
+		return nil
+	}
+	klog.Infof("Priceserver is stopped")
 	return nil
 }
+
+// runRefreshes runs the Run loops of the providers and the scheduler until the ctx is done, the scheduler is nil
+// when the jobs are not run with the providers.
+func runRefreshes(ctx context.Context, providers []*client.RegisteredProvider, checker *health.Checker,
+	jobScheduler *scheduler.Scheduler) {
+	var wg sync.WaitGroup
+	for _, p := range providers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checker.Run(ctx, p)
+		}()
+	}
+	if jobScheduler != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			jobScheduler.Run(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// newReplica creates the replica campaigning for the leadership in the namespace of the pod.
+func newReplica(kubeClient kubernetes.Interface, c config.LeaderElectionConfig,
+	providers []*client.RegisteredProvider, refresh func(ctx context.Context)) (*replication.Replica, error) {
+	namespace, err := replication.Namespace(c.Namespace)
+	if err != nil {
+		klog.Errorf("Failed to get the namespace of the leader election: %v", err)
+		return nil, err
+	}
+	identity, err := replication.Identity()
+	if err != nil {
+		klog.Errorf("Failed to get the identity of the leader election: %v", err)
+		return nil, err
+	}
+	replica, err := replication.NewReplica(kubeClient, c, namespace, identity, providers, refresh)
+	if err != nil {
+		klog.Errorf("Failed to create the replica of the leader election: %v", err)
+		return nil, err
+	}
+	return replica, nil
+}
+
+// waitWithContext waits for the wait group, it returns false if the ctx is done first.
+func waitWithContext(ctx context.Context, wg *sync.WaitGroup) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// configureProviders applies the settings of the config file to the providers.
+func configureProviders(registry *client.ProviderRegistry, c *config.Config) {
+	for _, p := range registry.List() {
+		if configurable, ok := p.Provider.(client.Configurable); ok {
+			configurable.Configure(c.ProviderSettings(p.Name))
+		}
+	}
+}
+
+// reloadConfigOnSIGHUP reloads the config file on SIGHUP, the invalid config is rejected and the previous one
+// is kept. Only the provider settings, the cors settings, the api keys and the health settings are applied, the
+// others need a restart.
+func reloadConfigOnSIGHUP(ctx context.Context, opts *options.Options, registry *client.ProviderRegistry,
+	corsHandler *router.CORS, authenticator *auth.Authenticator, checker *health.Checker,
+	jobScheduler *scheduler.Scheduler) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-signals:
+		case <-ctx.Done():
+			return
+		}
+
+		if opts.ConfigFile == "" {
+			klog.Warningf("Received SIGHUP but no config file is set by %s", apis.ConfigFileEnv)
+			continue
+		}
+		c, err := opts.LoadConfig()
+		if err != nil {
+			klog.Errorf("Failed to reload config file, keep using the previous one:%v", err)
+			continue
+		}
+
+		if c.Server.ListenAddress != opts.Config.Server.ListenAddress ||
+			c.Server.HealthListenAddress != opts.Config.Server.HealthListenAddress ||
+			c.Server.TLS != opts.Config.Server.TLS {
+			klog.Warningf("The listen addresses and the tls settings are changed, restart to apply them")
+		}
+		if !reflect.DeepEqual(c.LeaderElection, opts.Config.LeaderElection) {
+			klog.Warningf("The leader election settings are changed, restart to apply them")
+		}
+		for _, name := range sets.List(sets.KeySet(c.Providers).Union(sets.KeySet(opts.Config.Providers))) {
+			oldEnabled, oldSet := opts.Config.ProviderEnabled(name)
+			newEnabled, newSet := c.ProviderEnabled(name)
+			if oldEnabled != newEnabled || oldSet != newSet {
+				klog.Warningf("The enabled setting of provider %s is changed, restart to apply it", name)
+			}
+		}
+
+		if err := authenticator.Update(c.Auth); err != nil {
+			klog.Errorf("Failed to reload the api keys, keep using the previous config:%v", err)
+			continue
+		}
+		configureProviders(registry, c)
+		jobScheduler.Reschedule()
+		checker.Update(c.Health)
+		if !reflect.DeepEqual(c.Server.CORS, opts.Config.Server.CORS) {
+			corsHandler.Update(c.Server.CORS)
+		}
+		opts.Config = c
+		klog.Infof("Config file %s is reloaded", opts.ConfigFile)
+	}
+}
+
+// watchSecretFiles reloads the credentials of the provider when its secret files change.
+func watchSecretFiles(ctx context.Context, p *client.RegisteredProvider) {
+	reloader, ok := p.Provider.(client.CredentialReloader)
+	if !ok {
+		return
+	}
+	files := reloader.SecretFiles()
+	if len(files) == 0 {
+		return
+	}
+
+	klog.Infof("Watch the secret files of provider %s: %v", p.Name, files)
+	go client.WatchSecretFiles(ctx, files, func() {
+		if err := reloader.ReloadCredentials(); err != nil {
+			klog.Errorf("Failed to reload the credentials of provider %s, keep using the previous ones:%v", p.Name, err)
+			return
+		}
+		klog.Infof("The credentials of provider %s are reloaded", p.Name)
+	})
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/plugin"
+	pluginv1 "github.com/cloudpilot-ai/priceserver/pkg/plugin/api/v1"
+)
+
+// examplePlugin serves a fixed price list, it can be started by priceserver with
+// PRICESERVER_PLUGINS="example/vm=exec:go run ./hack/tools/plugin-example"
+type examplePlugin struct {
+	pluginv1.UnimplementedPriceProviderServer
+
+	prices map[string]map[string]*pluginv1.InstanceTypePrice
+}
+
+func (e *examplePlugin) ListRegions(context.Context, *pluginv1.ListRegionsRequest) (*pluginv1.ListRegionsResponse, error) {
+	resp := &pluginv1.ListRegionsResponse{}
+	for region := range e.prices {
+		resp.Regions = append(resp.Regions, region)
+	}
+	return resp, nil
+}
+
+func (e *examplePlugin) ListInstancePrices(_ context.Context,
+	req *pluginv1.ListInstancePricesRequest) (*pluginv1.ListInstancePricesResponse, error) {
+	return &pluginv1.ListInstancePricesResponse{InstanceTypePrices: e.prices[req.GetRegion()]}, nil
+}
+
+func (e *examplePlugin) GetInstancePrice(_ context.Context,
+	req *pluginv1.GetInstancePriceRequest) (*pluginv1.InstanceTypePrice, error) {
+	price, ok := e.prices[req.GetRegion()][req.GetInstanceType()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "%s is not found in region %s", req.GetInstanceType(), req.GetRegion())
+	}
+	return price, nil
+}
+
+func main() {
+	err := plugin.Serve(&examplePlugin{
+		prices: map[string]map[string]*pluginv1.InstanceTypePrice{
+			"example-1": {
+				"small": {
+					Arch:                 "amd64",
+					Vcpu:                 2,
+					Memory:               4,
+					Zones:                []string{"example-1a"},
+					OnDemandPricePerHour: 0.05,
+				},
+			},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+}
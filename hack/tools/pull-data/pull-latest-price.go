@@ -1,72 +1,196 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	"github.com/cloudpilot-ai/priceserver/pkg/apis"
 	"github.com/cloudpilot-ai/priceserver/pkg/client"
 )
 
+// writeBuiltinData writes the prices as the builtin data of the provider, the prices of no region are rejected, so
+// a failed pull never replaces the builtin data with an empty one.
+func writeBuiltinData(provider string, data map[string]*apis.RegionalInstancePrice) error {
+	if len(data) == 0 {
+		return fmt.Errorf("no price of %s is pulled", provider)
+	}
+	marshalData, err := json.MarshalIndent(data, "", "   ")
+	if err != nil {
+		return err
+	}
+	fileName := filepath.Join("pkg/client/builtin-data", client.SnapshotFileName(provider))
+	return os.WriteFile(fileName, marshalData, 0644)
+}
+
 func handleAWSData() error {
-	globalAK := os.Getenv(apis.AWSGlobalAKEnv)
-	globalSK := os.Getenv(apis.AWSGlobalSKEnv)
-	cnAK := os.Getenv(apis.AWSCNAKEnv)
-	cnSK := os.Getenv(apis.AWSCNSKEnv)
+	credentialConfig, err := client.ExtractAWSCredentialConfig()
+	if err != nil {
+		return err
+	}
 
-	awsPriceClient, err := client.NewAWSPriceClient(globalAK, globalSK, cnAK, cnSK, false)
+	awsPriceClient, err := client.NewAWSPriceClient(context.Background(), credentialConfig, false)
 	if err != nil {
 		return err
 	}
-	awsPriceClient.RefreshOnDemandPrice("", "")
-	awsPriceClient.RefreshSavingsPlanPrice("", "")
+	awsPriceClient.RefreshOnDemandPrice(context.Background(), "", "")
+	awsPriceClient.RefreshSavingsPlanPrice(context.Background(), "", "")
 
-	data := awsPriceClient.ListRegionsInstancesPrice()
-	marshalData, err := json.MarshalIndent(data, "", "   ")
+	return writeBuiltinData(apis.AWSProviderName, awsPriceClient.ListRegionsInstancesPrice())
+}
+
+func handleAlibabaCloudData() error {
+	credentialConfig, err := client.ExtractAlibabaCloudCredentialConfig()
 	if err != nil {
 		return err
 	}
-	err = os.WriteFile("pkg/client/builtin-data/aws_price.json", marshalData, 0644)
+	if !credentialConfig.Enabled() {
+		return fmt.Errorf("empty alibaba cloud credentials")
+	}
+
+	alibabaCloudClient, err := client.NewAlibabaCloudPriceClient(context.Background(), credentialConfig, false)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	alibabaCloudClient.RefreshOnDemandPrice(context.Background())
+
+	return writeBuiltinData(apis.AlibabaCloudProviderName, alibabaCloudClient.ListRegionsInstancesPrice())
 }
 
-func handleAlibabaCloudData() error {
-	alibabaCloudAKSKPool := client.ExtractAlibabaCloudAKSKPool()
-	if alibabaCloudAKSKPool == nil {
-		return fmt.Errorf("empty aksk pool")
+func handleGCPData() error {
+	projectID := os.Getenv(apis.GCPProjectIDEnv)
+	if projectID == "" {
+		return fmt.Errorf("empty gcp project id")
 	}
 
-	alibabaCloudClient, err := client.NewAlibabaCloudPriceClient(alibabaCloudAKSKPool, false)
+	gcpPriceClient, err := client.NewGCPPriceClient(context.Background(), projectID, false)
 	if err != nil {
 		return err
 	}
 
-	alibabaCloudClient.RefreshOnDemandPrice()
+	gcpPriceClient.RefreshOnDemandPrice(context.Background())
 
-	data := alibabaCloudClient.ListRegionsInstancesPrice()
-	marshalData, err := json.MarshalIndent(data, "", "   ")
+	return writeBuiltinData(apis.GCPProviderName, gcpPriceClient.ListRegionsInstancesPrice())
+}
+
+func handleAzureData() error {
+	azureConfig := client.ExtractAzureConfig()
+	if azureConfig.SubscriptionID == "" {
+		return fmt.Errorf("empty azure subscription id")
+	}
+
+	azurePriceClient, err := client.NewAzurePriceClient(context.Background(), azureConfig, false)
 	if err != nil {
 		return err
 	}
-	err = os.WriteFile("pkg/client/builtin-data/alibabacloud_price.json", marshalData, 0644)
+
+	azurePriceClient.RefreshOnDemandPrice(context.Background())
+
+	return writeBuiltinData(apis.AzureProviderName, azurePriceClient.ListRegionsInstancesPrice())
+}
+
+func handleTencentCloudData() error {
+	tencentCloudAKSKPool := client.ExtractTencentCloudAKSKPool()
+	if tencentCloudAKSKPool == nil {
+		return fmt.Errorf("empty tencentcloud aksk pool")
+	}
+
+	tencentCloudClient, err := client.NewTencentCloudPriceClient(context.Background(), tencentCloudAKSKPool,
+		os.Getenv(apis.TencentCloudCVMEndpointEnv), false)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	tencentCloudClient.RefreshOnDemandPrice(context.Background())
+
+	return writeBuiltinData(apis.TencentCloudProviderName, tencentCloudClient.ListRegionsInstancesPrice())
 }
 
+func handleHuaweiCloudData() error {
+	huaweiCloudAKSKPool := client.ExtractHuaweiCloudAKSKPool()
+	if huaweiCloudAKSKPool == nil {
+		return fmt.Errorf("empty huaweicloud aksk pool")
+	}
+
+	huaweiCloudClient, err := client.NewHuaweiCloudPriceClient(context.Background(), huaweiCloudAKSKPool,
+		os.Getenv(apis.HuaweiCloudBSSEndpointEnv), false)
+	if err != nil {
+		return err
+	}
+
+	huaweiCloudClient.RefreshOnDemandPrice(context.Background())
+
+	return writeBuiltinData(apis.HuaweiCloudProviderName, huaweiCloudClient.ListRegionsInstancesPrice())
+}
+
+func handleOCIData() error {
+	configFile := os.Getenv(apis.OCIConfigFileEnv)
+	if configFile == "" {
+		return fmt.Errorf("empty oci config file")
+	}
+
+	ociPriceClient, err := client.NewOCIPriceClient(context.Background(), configFile, os.Getenv(apis.OCIConfigProfileEnv), false)
+	if err != nil {
+		return err
+	}
+
+	ociPriceClient.RefreshOnDemandPrice(context.Background())
+
+	return writeBuiltinData(apis.OCIProviderName, ociPriceClient.ListRegionsInstancesPrice())
+}
+
+func handleVolcengineData() error {
+	volcengineAKSKPool := client.ExtractVolcengineAKSKPool()
+	if volcengineAKSKPool == nil {
+		return fmt.Errorf("empty volcengine aksk pool")
+	}
+
+	volcengineClient, err := client.NewVolcenginePriceClient(context.Background(), volcengineAKSKPool, false)
+	if err != nil {
+		return err
+	}
+
+	volcengineClient.RefreshOnDemandPrice(context.Background())
+
+	return writeBuiltinData(apis.VolcengineProviderName, volcengineClient.ListRegionsInstancesPrice())
+}
+
+// main pulls the prices of the providers in the args, like gcp azure, or all the providers without any arg.
 func main() {
-	if err := handleAWSData(); err != nil {
-		panic(err)
+	handlers := []struct {
+		provider string
+		handle   func() error
+	}{
+		{apis.AWSProviderName, handleAWSData},
+		{apis.AlibabaCloudProviderName, handleAlibabaCloudData},
+		{apis.GCPProviderName, handleGCPData},
+		{apis.AzureProviderName, handleAzureData},
+		{apis.TencentCloudProviderName, handleTencentCloudData},
+		{apis.HuaweiCloudProviderName, handleHuaweiCloudData},
+		{apis.OCIProviderName, handleOCIData},
+		{apis.VolcengineProviderName, handleVolcengineData},
+	}
+
+	selected := sets.New(os.Args[1:]...)
+	known := sets.New[string]()
+	for _, h := range handlers {
+		known.Insert(h.provider)
+	}
+	if unknown := selected.Difference(known); unknown.Len() != 0 {
+		panic(fmt.Sprintf("unknown providers %v", sets.List(unknown)))
 	}
 
-	if err := handleAlibabaCloudData(); err != nil {
-		panic(err)
+	for _, h := range handlers {
+		if selected.Len() != 0 && !selected.Has(h.provider) {
+			continue
+		}
+		if err := h.handle(); err != nil {
+			panic(err)
+		}
 	}
 }
@@ -1,12 +1,50 @@
 package apis
 
-import "k8s.io/apimachinery/pkg/util/sets"
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
 
 type RegionTypeKey struct {
 	Region       string
 	InstanceType string
 }
 
+// RefreshScope is what a refresh job refreshes, the region and the instance type are optional and narrow the
+// refresh when the provider supports them.
+type RefreshScope struct {
+	Provider     string `json:"provider"`
+	Source       string `json:"source"`
+	Region       string `json:"region,omitempty"`
+	InstanceType string `json:"instanceType,omitempty"`
+}
+
+const (
+	RefreshJobPending   = "pending"
+	RefreshJobRunning   = "running"
+	RefreshJobSucceeded = "succeeded"
+	RefreshJobFailed    = "failed"
+	RefreshJobCanceled  = "canceled"
+)
+
+// RefreshJob represents a refresh requested by the admin apis.
+type RefreshJob struct {
+	ID string `json:"id"`
+	RefreshScope
+	// State is pending until the previous jobs of the provider finish, then running, and then one of succeeded,
+	// failed and canceled
+	State      string     `json:"state"`
+	CreateTime time.Time  `json:"createTime"`
+	StartTime  *time.Time `json:"startTime,omitempty"`
+	FinishTime *time.Time `json:"finishTime,omitempty"`
+	// Refreshes are the refreshes of the source attempted since the job started, they show the progress of the
+	// running job and the result of the finished one
+	Refreshes []RefreshStatus `json:"refreshes"`
+	Message   string          `json:"message,omitempty"`
+}
+
 type RegionalInstancePrice struct {
 	InstanceTypePrices map[string]*InstanceTypePrice `json:"instanceTypePrices"`
 	// TODO: delete this field when all the customer upgrades the components
@@ -22,6 +60,53 @@ type InstanceTypePrice struct {
 	AWSEC2Billing map[string]AWSEC2Billing `json:"awsEC2Billing,omitempty"`
 	// SpotPricePerHour represents the smallest spot price per hour in different zones
 	SpotPricePerHour map[string]float64 `json:"spotPricePerHour,omitempty"`
+	// CommittedPricePerHour represents the effective price per hour with a resource commitment,
+	// key is the term length, like 1yr or 3yr
+	CommittedPricePerHour map[string]float64 `json:"committedPricePerHour,omitempty"`
+	// UnitPrice is only set for the instance types priced by the resources, like the OCI flexible shapes,
+	// OnDemandPricePerHour is the price of the default configuration for them
+	UnitPrice *UnitPrice `json:"unitPrice,omitempty"`
+}
+
+// UnitPrice represents the price per hour of every cpu unit and GB of memory, the cpu unit is provider
+// specific, like the OCPU of OCI.
+type UnitPrice struct {
+	CPUUnit        string  `json:"cpuUnit"`
+	VCPUPerCPUUnit float64 `json:"vcpuPerCPUUnit"`
+
+	CPUPerHour      float64 `json:"cpuPerHour"`
+	MemoryGBPerHour float64 `json:"memoryGBPerHour"`
+	// GPUPerHour is the price of one gpu, the gpus are not configurable
+	GPUPerHour float64 `json:"gpuPerHour,omitempty"`
+	GPU        float64 `json:"gpu,omitempty"`
+
+	// The ranges of the configuration, 0 means no limit
+	MinCPU            float64 `json:"minCPU,omitempty"`
+	MaxCPU            float64 `json:"maxCPU,omitempty"`
+	MinMemoryGB       float64 `json:"minMemoryGB,omitempty"`
+	MaxMemoryGB       float64 `json:"maxMemoryGB,omitempty"`
+	MinMemoryGBPerCPU float64 `json:"minMemoryGBPerCPU,omitempty"`
+	MaxMemoryGBPerCPU float64 `json:"maxMemoryGBPerCPU,omitempty"`
+}
+
+// PriceFor returns the price per hour of the configuration with cpu units and memory GBs.
+func (u *UnitPrice) PriceFor(cpu, memoryGB float64) (float64, error) {
+	if cpu <= 0 || memoryGB <= 0 {
+		return 0, fmt.Errorf("cpu and memory should be positive")
+	}
+	if u.MinCPU > 0 && cpu < u.MinCPU || u.MaxCPU > 0 && cpu > u.MaxCPU {
+		return 0, fmt.Errorf("%s %v is out of range [%v, %v]", u.CPUUnit, cpu, u.MinCPU, u.MaxCPU)
+	}
+	if u.MinMemoryGB > 0 && memoryGB < u.MinMemoryGB || u.MaxMemoryGB > 0 && memoryGB > u.MaxMemoryGB {
+		return 0, fmt.Errorf("memory %vGB is out of range [%v, %v]", memoryGB, u.MinMemoryGB, u.MaxMemoryGB)
+	}
+	perCPU := memoryGB / cpu
+	if u.MinMemoryGBPerCPU > 0 && perCPU < u.MinMemoryGBPerCPU || u.MaxMemoryGBPerCPU > 0 && perCPU > u.MaxMemoryGBPerCPU {
+		return 0, fmt.Errorf("memory per %s %vGB is out of range [%v, %v]", u.CPUUnit, perCPU,
+			u.MinMemoryGBPerCPU, u.MaxMemoryGBPerCPU)
+	}
+
+	return cpu*u.CPUPerHour + memoryGB*u.MemoryGBPerHour + u.GPU*u.GPUPerHour, nil
 }
 
 type InstanceInfo struct {
@@ -35,6 +120,111 @@ type InstanceTypeMetadata struct {
 	VCPU   float64 `json:"vcpu"`
 	Memory float64 `json:"memory"`
 	GPU    float64 `json:"gpu"`
+	// InstanceFamily is only filled by the providers that report it
+	InstanceFamily string `json:"instanceFamily,omitempty"`
+}
+
+// CredentialHealth represents the health of one credential in the pool of a provider.
+type CredentialHealth struct {
+	// ID is the masked access key or the credential type, the secrets are never exposed
+	ID      string `json:"id"`
+	Healthy bool   `json:"healthy"`
+	// QuarantinedUntil is set when the credential is skipped after failures or throttling
+	QuarantinedUntil    *time.Time `json:"quarantinedUntil,omitempty"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	Requests            int64      `json:"requests"`
+	Errors              int64      `json:"errors"`
+	Throttles           int64      `json:"throttles"`
+	LastError           string     `json:"lastError,omitempty"`
+	LastErrorTime       *time.Time `json:"lastErrorTime,omitempty"`
+}
+
+// RefreshStatus represents the refreshes of one source in one region of a provider.
+type RefreshStatus struct {
+	// Region is empty for the refreshes not split by region, like the metadata
+	Region          string     `json:"region,omitempty"`
+	Source          string     `json:"source"`
+	LastAttemptTime *time.Time `json:"lastAttemptTime,omitempty"`
+	LastSuccessTime *time.Time `json:"lastSuccessTime,omitempty"`
+	LastError       string     `json:"lastError,omitempty"`
+	LastErrorTime   *time.Time `json:"lastErrorTime,omitempty"`
+	// DurationSeconds is the duration of the last attempt
+	DurationSeconds float64 `json:"durationSeconds"`
+	// Items is the instance types served with the prices of the source in the region
+	Items int `json:"items"`
+}
+
+// ProviderRefreshStatus represents the refreshes of all the sources and regions of a provider.
+type ProviderRefreshStatus struct {
+	// Ready is false until every source of RequiredSources is refreshed successfully in any region
+	Ready           bool            `json:"ready"`
+	RequiredSources []string        `json:"requiredSources,omitempty"`
+	Refreshes       []RefreshStatus `json:"refreshes"`
+	// PublishedRefreshTimes are the last successful refreshes of the sources by the leader, keyed by the source,
+	// they are set by the followers serving the published snapshots only
+	PublishedRefreshTimes map[string]time.Time `json:"publishedRefreshTimes,omitempty"`
+	// UnsupportedSources are the sources the provider can not serve, keyed by the source, the values are the
+	// reasons
+	UnsupportedSources map[string]string `json:"unsupportedSources,omitempty"`
+}
+
+// HealthReport represents the result of the liveness or the readiness checks.
+type HealthReport struct {
+	Healthy bool `json:"healthy"`
+	// Failures are the failing checks, it is empty when healthy
+	Failures []HealthCheckFailure `json:"failures,omitempty"`
+	// Stale are the regions not refreshed within the staleness while the other regions of the source are, they
+	// do not fail the check, so a region failing alone does not take the replicas out of the Service
+	Stale []HealthCheckFailure `json:"stale,omitempty"`
+}
+
+// HealthCheckFailure represents a failing check of a provider, and of a source if the check is about one.
+type HealthCheckFailure struct {
+	// Check is the name of the check, like running, loaded, refreshed and fresh
+	Check           string     `json:"check"`
+	Provider        string     `json:"provider"`
+	Source          string     `json:"source,omitempty"`
+	Region          string     `json:"region,omitempty"`
+	Message         string     `json:"message"`
+	LastSuccessTime *time.Time `json:"lastSuccessTime,omitempty"`
+}
+
+// ScheduledJob represents the periodic refreshes of one source of a provider run by the scheduler.
+type ScheduledJob struct {
+	Provider string `json:"provider"`
+	Source   string `json:"source"`
+	// Schedule is like "every 30m0s" or a cron expression
+	Schedule       string     `json:"schedule"`
+	Jitter         string     `json:"jitter"`
+	MaxRuntime     string     `json:"maxRuntime"`
+	Paused         bool       `json:"paused"`
+	Running        bool       `json:"running"`
+	NextRunTime    *time.Time `json:"nextRunTime,omitempty"`
+	LastStartTime  *time.Time `json:"lastStartTime,omitempty"`
+	LastFinishTime *time.Time `json:"lastFinishTime,omitempty"`
+	// LastTimedOut is set when the last run is canceled by the max runtime
+	LastTimedOut bool `json:"lastTimedOut"`
+	// Skipped counts the scheduled runs skipped because the source was being refreshed by a refresh job
+	Skipped int64 `json:"skipped"`
+}
+
+// APIKeyUsage represents the requests made with one api key since the server started.
+type APIKeyUsage struct {
+	Name  string  `json:"name"`
+	Admin bool    `json:"admin"`
+	QPS   float64 `json:"qps"`
+	Burst int     `json:"burst"`
+	// Requests counts all the authenticated requests, including the throttled ones
+	Requests        int64      `json:"requests"`
+	Throttled       int64      `json:"throttled"`
+	LastRequestTime *time.Time `json:"lastRequestTime,omitempty"`
+}
+
+// APIKeysUsage represents the usage of all the api keys.
+type APIKeysUsage struct {
+	Keys []APIKeyUsage `json:"keys"`
+	// Unauthorized counts the requests rejected for a missing or an unknown key
+	Unauthorized int64 `json:"unauthorized"`
 }
 
 type AWSEC2Billing struct {
@@ -62,12 +252,7 @@ func (r *RegionalInstancePrice) DeepCopy() *RegionalInstancePrice {
 
 func (i *InstanceTypePrice) DeepCopy() *InstanceTypePrice {
 	d := &InstanceTypePrice{
-		InstanceTypeMetadata: InstanceTypeMetadata{
-			Arch:   i.Arch,
-			VCPU:   i.VCPU,
-			Memory: i.Memory,
-			GPU:    i.GPU,
-		},
+		InstanceTypeMetadata: i.InstanceTypeMetadata,
 		Zones:                make([]string, len(i.Zones)),
 		OnDemandPricePerHour: i.OnDemandPricePerHour,
 		AWSEC2Billing:        make(map[string]AWSEC2Billing),
@@ -80,5 +265,15 @@ func (i *InstanceTypePrice) DeepCopy() *InstanceTypePrice {
 	for k, v := range i.SpotPricePerHour {
 		d.SpotPricePerHour[k] = v
 	}
+	if i.UnitPrice != nil {
+		unitPrice := *i.UnitPrice
+		d.UnitPrice = &unitPrice
+	}
+	if i.CommittedPricePerHour != nil {
+		d.CommittedPricePerHour = make(map[string]float64)
+		for k, v := range i.CommittedPricePerHour {
+			d.CommittedPricePerHour[k] = v
+		}
+	}
 	return d
 }
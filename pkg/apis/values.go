@@ -1,13 +1,131 @@
 package apis
 
 const (
-	AWSPriceClientContextKey     = "aws"
-	AlibabaCloudClientContextKey = "alibabacloud"
+	ProviderContextKey = "provider"
+	RegistryContextKey = "registry"
+	// HealthCheckerContextKey holds the checker of the liveness and the readiness
+	HealthCheckerContextKey = "healthChecker"
+	// RefreshManagerContextKey holds the manager of the refresh jobs
+	RefreshManagerContextKey = "refreshManager"
+	// SchedulerContextKey holds the scheduler of the periodic refreshes
+	SchedulerContextKey = "scheduler"
+	// AuthenticatorContextKey holds the api key authenticator, APIKeyNameContextKey holds the name of the key
+	// authenticated for the request
+	AuthenticatorContextKey = "authenticator"
+	APIKeyNameContextKey    = "apiKeyName"
+
+	// APIKeyHeader carries the api key, the key can also be sent as a bearer token in the Authorization header
+	APIKeyHeader = "X-API-Key"
+
+	// DataSourceHeader is set to DataSourceStatic in the responses of the offline mode
+	DataSourceHeader = "X-Price-Data-Source"
+	DataSourceStatic = "static"
+
+	// The sources of the price data, each of them is refreshed separately
+	SourceOnDemand    = "onDemand"
+	SourceSpot        = "spot"
+	SourceSavingsPlan = "savingsPlan"
+	SourceMetadata    = "metadata"
+	// SourcePriceSheet is the price sheets of the static provider and SourcePlugin is the prices polled from a
+	// plugin, they are not split by the price types
+	SourcePriceSheet = "priceSheet"
+	SourcePlugin     = "plugin"
+	// SourceSnapshot is the sync of the prices with the snapshot published by the leader replica
+	SourceSnapshot = "snapshot"
+
+	AWSProviderName          = "aws"
+	AWSServiceName           = "ec2"
+	AlibabaCloudProviderName = "alibabacloud"
+	AlibabaCloudServiceName  = "ecs"
+	GCPProviderName          = "gcp"
+	GCPServiceName           = "gce"
+	AzureProviderName        = "azure"
+	AzureServiceName         = "vm"
+	TencentCloudProviderName = "tencentcloud"
+	TencentCloudServiceName  = "cvm"
+	HuaweiCloudProviderName  = "huaweicloud"
+	HuaweiCloudServiceName   = "ecs"
+	OCIProviderName          = "oci"
+	OCIServiceName           = "compute"
+	VolcengineProviderName   = "volcengine"
+	VolcengineServiceName    = "ecs"
+	StaticProviderName       = "static"
+	StaticServiceName        = "compute"
 
 	AWSGlobalAKEnv = "AWS_GLOBAL_ACCESS_KEY"
 	AWSGlobalSKEnv = "AWS_GLOBAL_SECRET_KEY"
 	AWSCNAKEnv     = "AWS_CN_ACCESS_KEY"
 	AWSCNSKEnv     = "AWS_CN_SECRET_KEY"
+	// The roles are assumed on top of the keys, or the default credential chain when the keys are not set
+	AWSGlobalRoleARNEnv    = "AWS_GLOBAL_ROLE_ARN"
+	AWSGlobalExternalIDEnv = "AWS_GLOBAL_EXTERNAL_ID"
+	AWSCNRoleARNEnv        = "AWS_CN_ROLE_ARN"
+	AWSCNExternalIDEnv     = "AWS_CN_EXTERNAL_ID"
+	// AWSPartitionsEnv is the comma separated partitions to serve, like global,cn, the partitions without keys
+	// use the default credential chain, like IRSA and the instance profile
+	AWSPartitionsEnv = "AWS_PARTITIONS"
 
 	AlibabaCloudAKSKPoolEnv = "ALIBABACLOUD_AKSK_POOL"
+	// AlibabaCloudCredentialTypeEnv is optional, it is ram_role_arn, ecs_ram_role or oidc_role_arn
+	AlibabaCloudCredentialTypeEnv  = "ALIBABACLOUD_CREDENTIAL_TYPE"
+	AlibabaCloudRoleARNEnv         = "ALIBABACLOUD_ROLE_ARN"
+	AlibabaCloudExternalIDEnv      = "ALIBABACLOUD_EXTERNAL_ID"
+	AlibabaCloudECSRAMRoleEnv      = "ALIBABACLOUD_ECS_RAM_ROLE"
+	AlibabaCloudOIDCProviderARNEnv = "ALIBABACLOUD_OIDC_PROVIDER_ARN"
+	AlibabaCloudOIDCTokenFileEnv   = "ALIBABACLOUD_OIDC_TOKEN_FILE"
+	// AlibabaCloudKeyQPSEnv is the rate limit of each credential, 10 by default
+	AlibabaCloudKeyQPSEnv = "ALIBABACLOUD_KEY_QPS"
+
+	GCPProjectIDEnv = "GCP_PROJECT_ID"
+
+	AzureTenantIDEnv       = "AZURE_TENANT_ID"
+	AzureClientIDEnv       = "AZURE_CLIENT_ID"
+	AzureClientSecretEnv   = "AZURE_CLIENT_SECRET"
+	AzureSubscriptionIDEnv = "AZURE_SUBSCRIPTION_ID"
+	// The azure endpoints are optional, like for the sovereign clouds or the recorded responses
+	AzureRetailPricesEndpointEnv = "AZURE_RETAIL_PRICES_ENDPOINT"
+	AzureManagementEndpointEnv   = "AZURE_MANAGEMENT_ENDPOINT"
+	AzureLoginEndpointEnv        = "AZURE_LOGIN_ENDPOINT"
+
+	TencentCloudAKSKPoolEnv    = "TENCENTCLOUD_AKSK_POOL"
+	TencentCloudCVMEndpointEnv = "TENCENTCLOUD_CVM_ENDPOINT"
+
+	HuaweiCloudAKSKPoolEnv    = "HUAWEICLOUD_AKSK_POOL"
+	HuaweiCloudBSSEndpointEnv = "HUAWEICLOUD_BSS_ENDPOINT"
+
+	OCIConfigFileEnv    = "OCI_CONFIG_FILE"
+	OCIConfigProfileEnv = "OCI_CONFIG_PROFILE"
+
+	VolcengineAKSKPoolEnv = "VOLCENGINE_AKSK_POOL"
+
+	// StaticPriceSheetPathsEnv is the comma separated price sheet files or directories
+	StaticPriceSheetPathsEnv = "STATIC_PRICE_SHEET_PATHS"
+
+	// The providers are enabled when they are configured, the <PROVIDER>_ENABLED envs are used to disable the
+	// configured ones with false, or to fail the startup with true when they are not configured
+	AWSEnabledEnv          = "AWS_ENABLED"
+	AlibabaCloudEnabledEnv = "ALIBABACLOUD_ENABLED"
+	GCPEnabledEnv          = "GCP_ENABLED"
+	AzureEnabledEnv        = "AZURE_ENABLED"
+	TencentCloudEnabledEnv = "TENCENTCLOUD_ENABLED"
+	HuaweiCloudEnabledEnv  = "HUAWEICLOUD_ENABLED"
+	OCIEnabledEnv          = "OCI_ENABLED"
+	VolcengineEnabledEnv   = "VOLCENGINE_ENABLED"
+	StaticEnabledEnv       = "STATIC_ENABLED"
+
+	// OfflineEnv enables the offline mode, the prices are served from the snapshots only and never refreshed
+	OfflineEnv = "OFFLINE"
+	// OfflineSnapshotDirEnv is optional, the snapshots in it take precedence over the builtin data
+	OfflineSnapshotDirEnv = "OFFLINE_SNAPSHOT_DIR"
+
+	// ConfigFileEnv is the path of the optional config file, it is reloaded on SIGHUP
+	ConfigFileEnv = "PRICESERVER_CONFIG_FILE"
+
+	// PodNameEnv identifies the replica in the leader election, the hostname is used when it is not set, and
+	// PodNamespaceEnv is the namespace of the lease and the snapshots when the config file does not set it
+	PodNameEnv      = "POD_NAME"
+	PodNamespaceEnv = "POD_NAMESPACE"
+
+	// PluginsEnv is like <name>/<service>=exec:<command> <args>;<name>/<service>=grpc:<address>
+	PluginsEnv = "PRICESERVER_PLUGINS"
 )
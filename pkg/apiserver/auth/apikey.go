@@ -0,0 +1,274 @@
+// Package auth contains the api key authentication of priceserver.
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+	"k8s.io/klog"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+	"github.com/cloudpilot-ai/priceserver/pkg/client"
+	"github.com/cloudpilot-ai/priceserver/pkg/config"
+)
+
+type apiKey struct {
+	name  string
+	admin bool
+	qps   float64
+	burst int
+	// limiter is nil when the key is not limited
+	limiter *rate.Limiter
+	usage   *apiKeyUsage
+}
+
+// apiKeyUsage is kept by the name of the key, so the counters survive reloading the keys.
+type apiKeyUsage struct {
+	requests  atomic.Int64
+	throttled atomic.Int64
+	// lastRequestTime is in unix nanoseconds, 0 means no request yet
+	lastRequestTime atomic.Int64
+}
+
+// Authenticator authenticates the requests with the api keys from the config file and the api keys file, every
+// key has its own token bucket and counters. All the requests except the admin ones are allowed when no key is
+// configured.
+type Authenticator struct {
+	// mutex serializes the updates, the requests only read keys
+	mutex       sync.Mutex
+	config      config.AuthConfig
+	configKeys  []config.APIKeyConfig
+	keysByName  map[string]*apiKey
+	fileUpdated chan struct{}
+
+	// keys are keyed by the sha256 of the key, so the lookup does not leak the key by timing
+	keys         atomic.Pointer[map[[sha256.Size]byte]*apiKey]
+	unauthorized atomic.Int64
+}
+
+// NewAuthenticator creates the authenticator, the config should have been validated.
+func NewAuthenticator(c config.AuthConfig) (*Authenticator, error) {
+	a := &Authenticator{keysByName: map[string]*apiKey{}}
+	if err := a.Update(c); err != nil {
+		return nil, err
+	}
+	// Created after the first update, so Watch starts with the file without being notified
+	a.fileUpdated = make(chan struct{}, 1)
+	return a, nil
+}
+
+// Update replaces the keys for the following requests, the previous keys are kept if the api keys file fails to
+// load. The limiters of the keys whose limits are not changed are kept.
+func (a *Authenticator) Update(c config.AuthConfig) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	var fileKeys []config.APIKeyConfig
+	if c.APIKeysFile != "" {
+		var err error
+		fileKeys, err = config.LoadAPIKeysFile(c.APIKeysFile)
+		if err != nil {
+			return err
+		}
+	}
+	if err := a.apply(c.APIKeys, fileKeys); err != nil {
+		return err
+	}
+
+	fileChanged := c.APIKeysFile != a.config.APIKeysFile
+	a.config = c
+	if fileChanged {
+		select {
+		case a.fileUpdated <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// reloadFile loads the api keys file again and keeps the keys from the config file.
+func (a *Authenticator) reloadFile() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.config.APIKeysFile == "" {
+		return nil
+	}
+	fileKeys, err := config.LoadAPIKeysFile(a.config.APIKeysFile)
+	if err != nil {
+		return err
+	}
+	return a.apply(a.configKeys, fileKeys)
+}
+
+func (a *Authenticator) apply(configKeys, fileKeys []config.APIKeyConfig) error {
+	all := append(append([]config.APIKeyConfig{}, configKeys...), fileKeys...)
+	if err := config.ValidateAPIKeys(all); err != nil {
+		return fmt.Errorf("invalid api keys: %w", err)
+	}
+
+	keys := map[[sha256.Size]byte]*apiKey{}
+	keysByName := map[string]*apiKey{}
+	for _, k := range all {
+		burst := k.Burst
+		if burst == 0 {
+			burst = int(math.Ceil(k.QPS))
+		}
+
+		key := &apiKey{name: k.Name, admin: k.Admin, qps: k.QPS, burst: burst, usage: &apiKeyUsage{}}
+		if old, ok := a.keysByName[k.Name]; ok {
+			key.usage = old.usage
+			if old.qps == key.qps && old.burst == key.burst {
+				key.limiter = old.limiter
+			}
+		}
+		if key.qps > 0 && key.limiter == nil {
+			key.limiter = rate.NewLimiter(rate.Limit(key.qps), key.burst)
+		}
+
+		keys[sha256.Sum256([]byte(k.Key))] = key
+		keysByName[k.Name] = key
+	}
+
+	a.configKeys = configKeys
+	a.keysByName = keysByName
+	a.keys.Store(&keys)
+	return nil
+}
+
+// Watch reloads the api keys file when it changes until the ctx is done, the file set by the following updates
+// is watched instead.
+func (a *Authenticator) Watch(ctx context.Context) {
+	for {
+		a.mutex.Lock()
+		file := a.config.APIKeysFile
+		a.mutex.Unlock()
+
+		watchCtx, cancel := context.WithCancel(ctx)
+		if file != "" {
+			klog.Infof("Watch the api keys file: %s", file)
+			go client.WatchSecretFiles(watchCtx, []string{file}, func() {
+				if err := a.reloadFile(); err != nil {
+					klog.Errorf("Failed to reload the api keys file, keep the previous keys:%v", err)
+					return
+				}
+				klog.Infof("The api keys file is reloaded")
+			})
+		}
+
+		select {
+		case <-a.fileUpdated:
+			cancel()
+		case <-ctx.Done():
+			cancel()
+			return
+		}
+	}
+}
+
+// Authenticate returns the middleware requiring an api key, and requiring an admin key if admin is true. The admin
+// requests are forbidden until an admin key is configured, the keys are checked by every request as they can be
+// reloaded.
+func (a *Authenticator) Authenticate(admin bool) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		keys := *a.keys.Load()
+		if admin && !hasAdminKey(keys) {
+			abort(ctx, http.StatusForbidden, "the admin apis are disabled, no admin api key is configured")
+			return
+		}
+		if len(keys) == 0 {
+			ctx.Next()
+			return
+		}
+
+		value := requestAPIKey(ctx.Request)
+		key, ok := keys[sha256.Sum256([]byte(value))]
+		if value == "" || !ok {
+			a.unauthorized.Add(1)
+			ctx.Header("WWW-Authenticate", "Bearer")
+			abort(ctx, http.StatusUnauthorized, "a valid api key is required")
+			return
+		}
+		if admin && !key.admin {
+			abort(ctx, http.StatusForbidden, fmt.Sprintf("api key %s is not allowed to call the admin apis", key.name))
+			return
+		}
+
+		key.usage.requests.Add(1)
+		key.usage.lastRequestTime.Store(time.Now().UnixNano())
+		if key.limiter != nil && !key.limiter.Allow() {
+			key.usage.throttled.Add(1)
+			ctx.Header("Retry-After", fmt.Sprint(int(math.Ceil(1/key.qps))))
+			abort(ctx, http.StatusTooManyRequests, fmt.Sprintf("api key %s is rate limited", key.name))
+			return
+		}
+
+		ctx.Set(apis.APIKeyNameContextKey, key.name)
+		ctx.Next()
+	}
+}
+
+func hasAdminKey(keys map[[sha256.Size]byte]*apiKey) bool {
+	for _, key := range keys {
+		if key.admin {
+			return true
+		}
+	}
+	return false
+}
+
+// Usage returns the counters of the keys sorted by the name, the keys themselves are not included.
+func (a *Authenticator) Usage() apis.APIKeysUsage {
+	a.mutex.Lock()
+	keys := make([]*apiKey, 0, len(a.keysByName))
+	for _, k := range a.keysByName {
+		keys = append(keys, k)
+	}
+	a.mutex.Unlock()
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].name < keys[j].name
+	})
+
+	ret := apis.APIKeysUsage{Keys: []apis.APIKeyUsage{}, Unauthorized: a.unauthorized.Load()}
+	for _, k := range keys {
+		usage := apis.APIKeyUsage{
+			Name:      k.name,
+			Admin:     k.admin,
+			QPS:       k.qps,
+			Burst:     k.burst,
+			Requests:  k.usage.requests.Load(),
+			Throttled: k.usage.throttled.Load(),
+		}
+		if last := k.usage.lastRequestTime.Load(); last != 0 {
+			t := time.Unix(0, last)
+			usage.LastRequestTime = &t
+		}
+		ret.Keys = append(ret.Keys, usage)
+	}
+	return ret
+}
+
+func requestAPIKey(r *http.Request) string {
+	if key := r.Header.Get(apis.APIKeyHeader); key != "" {
+		return key
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+func abort(ctx *gin.Context, code int, message string) {
+	ctx.AsciiJSON(code, message)
+	ctx.Abort()
+}
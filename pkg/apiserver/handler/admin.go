@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/klog"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+	"github.com/cloudpilot-ai/priceserver/pkg/apiserver/auth"
+	"github.com/cloudpilot-ai/priceserver/pkg/client"
+)
+
+// ListCredentialHealth returns the health of the credentials of the providers tracking them, keyed by the
+// provider name.
+func ListCredentialHealth(ctx *gin.Context) {
+	registry, err := getRegistry(ctx)
+	if err != nil {
+		klog.Errorf("failed to get provider registry: %v", err)
+		abortWithFormattedData(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	data := map[string][]apis.CredentialHealth{}
+	for _, p := range registry.List() {
+		if reporter, ok := p.Provider.(client.CredentialHealthReporter); ok {
+			data[p.Name] = reporter.CredentialHealth()
+		}
+	}
+	returnFormattedData(ctx, http.StatusOK, data)
+}
+
+// ListRefreshStatus returns the last refreshes of every source and region of the providers, keyed by the
+// provider name.
+func ListRefreshStatus(ctx *gin.Context) {
+	registry, err := getRegistry(ctx)
+	if err != nil {
+		klog.Errorf("failed to get provider registry: %v", err)
+		abortWithFormattedData(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	data := map[string]apis.ProviderRefreshStatus{}
+	for _, p := range registry.List() {
+		if reporter, ok := p.Provider.(client.RefreshStatusReporter); ok {
+			data[p.Name] = reporter.RefreshStatus()
+		}
+	}
+	returnFormattedData(ctx, http.StatusOK, data)
+}
+
+// ListAPIKeyUsage returns the requests made with the api keys, the keys themselves are never returned.
+func ListAPIKeyUsage(ctx *gin.Context) {
+	authenticatorUntyped, ok := ctx.Get(apis.AuthenticatorContextKey)
+	if !ok {
+		klog.Errorf("failed to get authenticator from context")
+		abortWithFormattedData(ctx, http.StatusInternalServerError, "failed to get authenticator from context")
+		return
+	}
+	authenticator, ok := authenticatorUntyped.(*auth.Authenticator)
+	if !ok {
+		klog.Errorf("failed to convert authenticator")
+		abortWithFormattedData(ctx, http.StatusInternalServerError, "failed to convert authenticator")
+		return
+	}
+	returnFormattedData(ctx, http.StatusOK, authenticator.Usage())
+}
+
+func getRegistry(ctx *gin.Context) (*client.ProviderRegistry, error) {
+	registryUntyped, ok := ctx.Get(apis.RegistryContextKey)
+	if !ok {
+		return nil, fmt.Errorf("failed to get registry from context")
+	}
+	registry, ok := registryUntyped.(*client.ProviderRegistry)
+	if !ok {
+		return nil, fmt.Errorf("failed to convert registry")
+	}
+	return registry, nil
+}
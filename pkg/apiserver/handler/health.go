@@ -1,11 +1,59 @@
 package handler
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"k8s.io/klog"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+	"github.com/cloudpilot-ai/priceserver/pkg/health"
 )
 
 func HealthCheck(ctx *gin.Context) {
 	returnFormattedData(ctx, http.StatusOK, "Price Server is healthy")
 }
+
+// LivenessCheck fails when the refresh loop of any provider has exited, the failing checks are returned.
+func LivenessCheck(ctx *gin.Context) {
+	checker, err := getHealthChecker(ctx)
+	if err != nil {
+		klog.Errorf("failed to get health checker: %v", err)
+		abortWithFormattedData(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+	returnHealthReport(ctx, checker.Liveness())
+}
+
+// ReadinessCheck fails until the data of the providers is loaded and refreshed, and when any source gets stale,
+// the failing checks are returned.
+func ReadinessCheck(ctx *gin.Context) {
+	checker, err := getHealthChecker(ctx)
+	if err != nil {
+		klog.Errorf("failed to get health checker: %v", err)
+		abortWithFormattedData(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+	returnHealthReport(ctx, checker.Readiness())
+}
+
+func returnHealthReport(ctx *gin.Context, report apis.HealthReport) {
+	if !report.Healthy {
+		returnFormattedData(ctx, http.StatusServiceUnavailable, report)
+		return
+	}
+	returnFormattedData(ctx, http.StatusOK, report)
+}
+
+func getHealthChecker(ctx *gin.Context) (*health.Checker, error) {
+	checkerUntyped, ok := ctx.Get(apis.HealthCheckerContextKey)
+	if !ok {
+		return nil, fmt.Errorf("failed to get health checker from context")
+	}
+	checker, ok := checkerUntyped.(*health.Checker)
+	if !ok {
+		return nil, fmt.Errorf("failed to convert health checker")
+	}
+	return checker, nil
+}
@@ -0,0 +1,16 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/metrics"
+)
+
+// The responses are compressed by the gzip middleware of the router already
+var metricsHandler = promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{DisableCompression: true})
+
+// Metrics serves the prometheus metrics.
+func Metrics(ctx *gin.Context) {
+	metricsHandler.ServeHTTP(ctx.Writer, ctx.Request)
+}
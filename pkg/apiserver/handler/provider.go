@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/klog"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+	"github.com/cloudpilot-ai/priceserver/pkg/client"
+)
+
+// ProviderNotEnabled serves all the routes of a known provider which is not enabled, 501 tells the clients the
+// provider is known but not served by this deployment, unlike the 404 of a typo in the path.
+func ProviderNotEnabled(ctx *gin.Context) {
+	p, err := getProvider(ctx)
+	if err != nil {
+		klog.Errorf("failed to get price provider: %v", err)
+		abortWithFormattedData(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+	abortWithFormattedData(ctx, http.StatusNotImplemented, fmt.Sprintf("provider %s is not enabled", p.Name))
+}
+
+func ListAllRegionsPrice(ctx *gin.Context) {
+	p, err := getProvider(ctx)
+	if err != nil {
+		klog.Errorf("failed to get price provider: %v", err)
+		abortWithFormattedData(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+	klog.V(4).Infof("Start to list %s all regions %s price...", p.Name, p.Service)
+	data := p.Provider.ListRegionsInstancesPrice()
+	returnFormattedData(ctx, http.StatusOK, data)
+}
+
+func ListInstanceTypes(ctx *gin.Context) {
+	p, err := getProvider(ctx)
+	if err != nil {
+		klog.Errorf("failed to get price provider: %v", err)
+		abortWithFormattedData(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+	klog.V(4).Infof("Start to list %s instance types...", p.Name)
+	returnFormattedData(ctx, http.StatusOK, p.Provider.ListInstanceTypes())
+}
+
+func GetInstanceInfo(ctx *gin.Context) {
+	p, err := getProvider(ctx)
+	if err != nil {
+		klog.Errorf("failed to get price provider: %v", err)
+		abortWithFormattedData(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+	klog.V(4).Infof("Start to get %s instance info...", p.Name)
+	instanceType := ctx.Param("instance_type")
+	returnFormattedData(ctx, http.StatusOK, p.Provider.GetInstanceInfo(instanceType))
+}
+
+func ListRegionPrice(ctx *gin.Context) {
+	p, err := getProvider(ctx)
+	if err != nil {
+		klog.Errorf("failed to get price provider: %v", err)
+		abortWithFormattedData(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+	klog.V(4).Infof("Start to list %s %s price...", p.Name, p.Service)
+	region := ctx.Param("region")
+	data := p.Provider.ListInstancesPrice(region)
+	returnFormattedData(ctx, http.StatusOK, data)
+}
+
+func GetInstancePrice(ctx *gin.Context) {
+	p, err := getProvider(ctx)
+	if err != nil {
+		klog.Errorf("failed to get price provider: %v", err)
+		abortWithFormattedData(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+	klog.V(4).Infof("Start to get %s %s price...", p.Name, p.Service)
+	region := ctx.Param("region")
+	instanceType := ctx.Param("instance_type")
+	data := p.Provider.GetInstancePrice(region, instanceType)
+
+	// The price of a custom configuration is requested like ?cpu=2&memory=16
+	cpuQuery, memoryQuery := ctx.Query("cpu"), ctx.Query("memory")
+	if data == nil || (cpuQuery == "" && memoryQuery == "") {
+		returnFormattedData(ctx, http.StatusOK, data)
+		return
+	}
+
+	data, err = priceForConfiguration(data, cpuQuery, memoryQuery)
+	if err != nil {
+		abortWithFormattedData(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+	returnFormattedData(ctx, http.StatusOK, data)
+}
+
+// priceForConfiguration returns a copy of the price data with the on-demand price and the metadata of the
+// configuration, only the instance types with the unit price support it.
+func priceForConfiguration(data *apis.InstanceTypePrice, cpuQuery, memoryQuery string) (*apis.InstanceTypePrice, error) {
+	if data.UnitPrice == nil {
+		return nil, fmt.Errorf("the instance type is not priced by the resources")
+	}
+	cpu, err := strconv.ParseFloat(cpuQuery, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cpu %q: %v", cpuQuery, err)
+	}
+	memory, err := strconv.ParseFloat(memoryQuery, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid memory %q: %v", memoryQuery, err)
+	}
+	price, err := data.UnitPrice.PriceFor(cpu, memory)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := data.DeepCopy()
+	ret.OnDemandPricePerHour = price
+	ret.VCPU = cpu * data.UnitPrice.VCPUPerCPUUnit
+	ret.Memory = memory
+	return ret, nil
+}
+
+func getProvider(ctx *gin.Context) (*client.RegisteredProvider, error) {
+	providerUntyped, ok := ctx.Get(apis.ProviderContextKey)
+	if !ok {
+		return nil, fmt.Errorf("failed to get providerUntyped from context")
+	}
+	providerTyped, ok := providerUntyped.(*client.RegisteredProvider)
+	if !ok {
+		return nil, fmt.Errorf("failed to convert provider")
+	}
+	return providerTyped, nil
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/klog"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+	"github.com/cloudpilot-ai/priceserver/pkg/refresh"
+)
+
+// CreateRefreshJob starts a job refreshing the scope in the body, it returns 202 with the new job, or 200 with
+// the pending or running job of the same scope.
+func CreateRefreshJob(ctx *gin.Context) {
+	manager, err := getRefreshManager(ctx)
+	if err != nil {
+		klog.Errorf("failed to get refresh manager: %v", err)
+		abortWithFormattedData(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var scope apis.RefreshScope
+	if err := ctx.ShouldBindJSON(&scope); err != nil {
+		abortWithFormattedData(ctx, http.StatusBadRequest, fmt.Sprintf("invalid refresh scope: %v", err))
+		return
+	}
+	if scope.Provider == "" || scope.Source == "" {
+		abortWithFormattedData(ctx, http.StatusBadRequest, "provider and source are required")
+		return
+	}
+
+	job, coalesced, err := manager.Submit(scope)
+	if errors.Is(err, refresh.ErrProviderNotFound) {
+		abortWithFormattedData(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+	if errors.Is(err, refresh.ErrNotLeader) {
+		abortWithFormattedData(ctx, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		abortWithFormattedData(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+	if coalesced {
+		returnFormattedData(ctx, http.StatusOK, job)
+		return
+	}
+	returnFormattedData(ctx, http.StatusAccepted, job)
+}
+
+// ListRefreshJobs returns the pending, the running and the recently finished jobs, the latest first.
+func ListRefreshJobs(ctx *gin.Context) {
+	manager, err := getRefreshManager(ctx)
+	if err != nil {
+		klog.Errorf("failed to get refresh manager: %v", err)
+		abortWithFormattedData(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+	returnFormattedData(ctx, http.StatusOK, manager.List())
+}
+
+func GetRefreshJob(ctx *gin.Context) {
+	manager, err := getRefreshManager(ctx)
+	if err != nil {
+		klog.Errorf("failed to get refresh manager: %v", err)
+		abortWithFormattedData(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	job, err := manager.Get(ctx.Param("id"))
+	if err != nil {
+		abortWithFormattedData(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+	returnFormattedData(ctx, http.StatusOK, job)
+}
+
+func getRefreshManager(ctx *gin.Context) (*refresh.Manager, error) {
+	managerUntyped, ok := ctx.Get(apis.RefreshManagerContextKey)
+	if !ok {
+		return nil, fmt.Errorf("failed to get refresh manager from context")
+	}
+	manager, ok := managerUntyped.(*refresh.Manager)
+	if !ok {
+		return nil, fmt.Errorf("failed to convert refresh manager")
+	}
+	return manager, nil
+}
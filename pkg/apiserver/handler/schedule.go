@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/klog"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+	"github.com/cloudpilot-ai/priceserver/pkg/scheduler"
+)
+
+// ListScheduledJobs returns the periodic refreshes of the sources run by the scheduler.
+func ListScheduledJobs(ctx *gin.Context) {
+	s, err := getScheduler(ctx)
+	if err != nil {
+		klog.Errorf("failed to get scheduler: %v", err)
+		abortWithFormattedData(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+	returnFormattedData(ctx, http.StatusOK, s.List())
+}
+
+// PauseScheduledJob skips the periodic refreshes of the source until it is resumed.
+func PauseScheduledJob(ctx *gin.Context) {
+	setScheduledJobPaused(ctx, true)
+}
+
+// ResumeScheduledJob schedules the periodic refreshes of the paused source again.
+func ResumeScheduledJob(ctx *gin.Context) {
+	setScheduledJobPaused(ctx, false)
+}
+
+func setScheduledJobPaused(ctx *gin.Context, paused bool) {
+	s, err := getScheduler(ctx)
+	if err != nil {
+		klog.Errorf("failed to get scheduler: %v", err)
+		abortWithFormattedData(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	provider, source := ctx.Param("provider"), ctx.Param("source")
+	if paused {
+		err = s.Pause(provider, source)
+	} else {
+		err = s.Resume(provider, source)
+	}
+	if errors.Is(err, scheduler.ErrJobNotFound) {
+		abortWithFormattedData(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		abortWithFormattedData(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	for _, job := range s.List() {
+		if job.Provider == provider && job.Source == source {
+			returnFormattedData(ctx, http.StatusOK, job)
+			return
+		}
+	}
+}
+
+func getScheduler(ctx *gin.Context) (*scheduler.Scheduler, error) {
+	schedulerUntyped, ok := ctx.Get(apis.SchedulerContextKey)
+	if !ok {
+		return nil, fmt.Errorf("failed to get scheduler from context")
+	}
+	s, ok := schedulerUntyped.(*scheduler.Scheduler)
+	if !ok {
+		return nil, fmt.Errorf("failed to convert scheduler")
+	}
+	return s, nil
+}
@@ -1,55 +1,168 @@
 package router
 
 import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
 	"github.com/gin-contrib/cors"
 	"github.com/gin-contrib/gzip"
 	"github.com/gin-gonic/gin"
 
 	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+	"github.com/cloudpilot-ai/priceserver/pkg/apiserver/auth"
 	"github.com/cloudpilot-ai/priceserver/pkg/apiserver/handler"
 	"github.com/cloudpilot-ai/priceserver/pkg/client"
+	"github.com/cloudpilot-ai/priceserver/pkg/config"
+	"github.com/cloudpilot-ai/priceserver/pkg/health"
+	"github.com/cloudpilot-ai/priceserver/pkg/metrics"
+	"github.com/cloudpilot-ai/priceserver/pkg/refresh"
+	"github.com/cloudpilot-ai/priceserver/pkg/scheduler"
 )
 
-func NewPriceServerRouter(awsPriceClient *client.AWSPriceClient, alibabaCloudClient *client.AlibabaCloudPriceClient) *gin.Engine {
+type Options struct {
+	// Offline marks the responses as static data
+	Offline bool
+	CORS    *CORS
+	// Auth authenticates the price and the admin apis, the health checks are always served without a key
+	Auth   *auth.Authenticator
+	Health *health.Checker
+	// Refresh runs the refreshes requested by the admin apis
+	Refresh *refresh.Manager
+	// Scheduler runs the periodic refreshes, they can be paused and resumed by the admin apis
+	Scheduler *scheduler.Scheduler
+}
+
+// CORS is the cors middleware whose config can be updated without restarting.
+type CORS struct {
+	handler atomic.Pointer[gin.HandlerFunc]
+}
+
+// NewCORS creates the cors middleware, the config should have been validated.
+func NewCORS(c config.CORSConfig) *CORS {
+	ret := &CORS{}
+	ret.Update(c)
+	return ret
+}
+
+// Update replaces the cors config for the following requests.
+func (c *CORS) Update(config config.CORSConfig) {
+	handler := cors.New(config.GinConfig())
+	c.handler.Store(&handler)
+}
+
+func (c *CORS) Handle(context *gin.Context) {
+	(*c.handler.Load())(context)
+}
+
+// NewPriceServerRouter mounts the providers of the registry.
+func NewPriceServerRouter(registry *client.ProviderRegistry, opts Options) *gin.Engine {
 	router := gin.Default()
 
-	config := cors.DefaultConfig()
-	config.AllowOrigins = []string{"*"}
-	config.AllowHeaders = []string{"*"}
-	corsHandler := cors.New(config)
-	router.Use(corsHandler)
+	router.Use(observeRequest)
+
+	router.Use(opts.CORS.Handle)
 
 	router.Use(gzip.Gzip(gzip.BestCompression))
 
-	router.Use(func(context *gin.Context) {
-		context.Set(apis.AWSPriceClientContextKey, awsPriceClient)
-		context.Set(apis.AlibabaCloudClientContextKey, alibabaCloudClient)
+	if opts.Offline {
+		router.Use(func(context *gin.Context) {
+			context.Header(apis.DataSourceHeader, apis.DataSourceStatic)
+			context.Next()
+		})
+	}
+
+	for _, p := range registry.List() {
+		initProviderRouter(router, p, opts.Auth)
+	}
+	for _, p := range registry.ListDisabled() {
+		initDisabledProviderRouter(router, p, opts.Auth)
+	}
+	initHealthRouter(router, opts.Health)
+	initMetricsRouter(router)
+	initAdminRouter(router, registry, opts)
+
+	return router
+}
+
+func initProviderRouter(router *gin.Engine, p *client.RegisteredProvider, authenticator *auth.Authenticator) {
+	group := router.Group(fmt.Sprintf("/api/v1/%s/%s", p.Name, p.Service))
+	group.Use(authenticator.Authenticate(false))
+	group.Use(func(context *gin.Context) {
+		context.Set(apis.ProviderContextKey, p)
+		context.Next()
+	})
+	group.GET("/price", handler.ListAllRegionsPrice)
+	group.GET("/types", handler.ListInstanceTypes)
+	group.GET("/types/:instance_type", handler.GetInstanceInfo)
+	group.GET("/regions/:region/price", handler.ListRegionPrice)
+	group.GET("/regions/:region/types/:instance_type/price", handler.GetInstancePrice)
+}
+
+func initDisabledProviderRouter(router *gin.Engine, p *client.RegisteredProvider,
+	authenticator *auth.Authenticator) {
+	group := router.Group(fmt.Sprintf("/api/v1/%s/%s", p.Name, p.Service))
+	group.Use(authenticator.Authenticate(false))
+	group.Use(func(context *gin.Context) {
+		context.Set(apis.ProviderContextKey, p)
 		context.Next()
 	})
-	initAWSPriceRouter(router)
-	initAlibabaCloudPriceRouter(router)
-	initHealthRouter(router)
+	group.Any("/*path", handler.ProviderNotEnabled)
+}
 
+// NewHealthRouter serves the health checks and the metrics only, it is served over plain http for the probes
+// and the scrapers.
+func NewHealthRouter(checker *health.Checker) *gin.Engine {
+	router := gin.New()
+	router.Use(gin.Recovery())
+	router.Use(observeRequest)
+	initHealthRouter(router, checker)
+	initMetricsRouter(router)
 	return router
 }
 
-func initAWSPriceRouter(router *gin.Engine) {
-	group := router.Group("/api/v1/aws")
-	group.GET("/ec2/price", handler.ListAWSAllRegionEC2Price)
-	group.GET("/ec2/types", handler.ListAWSInstanceTypes)
-	group.GET("/ec2/types/:instance_type", handler.GetAWSInstanceInfo)
-	group.GET("/ec2/regions/:region/price", handler.ListAWSEC2Price)
-	group.GET("/ec2/regions/:region/types/:instance_type/price", handler.GetAWSEC2Price)
+// observeRequest records the latency of the request by the route pattern, so the paths with parameters do not
+// blow up the label values.
+func observeRequest(context *gin.Context) {
+	startTime := time.Now()
+	context.Next()
+	metrics.HTTPRequestDuration.WithLabelValues(context.Request.Method, context.FullPath(),
+		strconv.Itoa(context.Writer.Status())).Observe(time.Since(startTime).Seconds())
 }
 
-func initAlibabaCloudPriceRouter(router *gin.Engine) {
-	group := router.Group("/api/v1/alibabacloud")
-	group.GET("/ecs/price", handler.ListAlibabaCloudAllRegionECSPrice)
-	group.GET("/ecs/regions/:region/price", handler.ListAlibabaCloudECSPrice)
-	group.GET("/ecs/regions/:region/types/:instance_type/price", handler.GetAlibabaCloudECSPrice)
+func initMetricsRouter(router *gin.Engine) {
+	router.GET("/metrics", handler.Metrics)
 }
 
-func initHealthRouter(router *gin.Engine) {
+func initHealthRouter(router *gin.Engine, checker *health.Checker) {
 	group := router.Group("/")
+	group.Use(func(context *gin.Context) {
+		context.Set(apis.HealthCheckerContextKey, checker)
+		context.Next()
+	})
 	group.GET("/healthz", handler.HealthCheck)
+	group.GET("/livez", handler.LivenessCheck)
+	group.GET("/readyz", handler.ReadinessCheck)
+}
+
+func initAdminRouter(router *gin.Engine, registry *client.ProviderRegistry, opts Options) {
+	group := router.Group("/admin/v1")
+	group.Use(opts.Auth.Authenticate(true))
+	group.Use(func(context *gin.Context) {
+		context.Set(apis.RegistryContextKey, registry)
+		context.Set(apis.AuthenticatorContextKey, opts.Auth)
+		context.Set(apis.RefreshManagerContextKey, opts.Refresh)
+		context.Set(apis.SchedulerContextKey, opts.Scheduler)
+		context.Next()
+	})
+	group.GET("/credentials", handler.ListCredentialHealth)
+	group.GET("/status", handler.ListRefreshStatus)
+	group.GET("/apikeys", handler.ListAPIKeyUsage)
+	group.POST("/refreshes", handler.CreateRefreshJob)
+	group.GET("/refreshes", handler.ListRefreshJobs)
+	group.GET("/refreshes/:id", handler.GetRefreshJob)
+	group.GET("/schedules", handler.ListScheduledJobs)
+	group.POST("/schedules/:provider/:source/pause", handler.PauseScheduledJob)
+	group.POST("/schedules/:provider/:source/resume", handler.ResumeScheduledJob)
 }
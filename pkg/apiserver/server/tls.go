@@ -0,0 +1,98 @@
+// Package server contains the helpers to serve the routers of priceserver.
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"k8s.io/klog"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/client"
+	"github.com/cloudpilot-ai/priceserver/pkg/config"
+)
+
+// CertificateReloader serves the certificate and the client CA bundle loaded from the files, they are reloaded
+// when the files change, so the rotated certificates are served without restarting.
+type CertificateReloader struct {
+	config config.TLSConfig
+
+	certificate atomic.Pointer[tls.Certificate]
+	// clientCAs is nil when the client certificates are not verified
+	clientCAs atomic.Pointer[x509.CertPool]
+}
+
+func NewCertificateReloader(c config.TLSConfig) (*CertificateReloader, error) {
+	r := &CertificateReloader{config: c}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload loads the files again, the previous certificate is kept serving if any of them fails to load.
+func (r *CertificateReloader) Reload() error {
+	certificate, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load the tls certificate: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if r.config.ClientCAFile != "" {
+		data, err := os.ReadFile(r.config.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read the client CA bundle: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no certificate is found in the client CA bundle %s", r.config.ClientCAFile)
+		}
+	}
+
+	r.certificate.Store(&certificate)
+	r.clientCAs.Store(clientCAs)
+	return nil
+}
+
+// Watch reloads the files when they change until the ctx is done.
+func (r *CertificateReloader) Watch(ctx context.Context) {
+	files := []string{r.config.CertFile, r.config.KeyFile}
+	if r.config.ClientCAFile != "" {
+		files = append(files, r.config.ClientCAFile)
+	}
+
+	klog.Infof("Watch the tls files: %v", files)
+	client.WatchSecretFiles(ctx, files, func() {
+		if err := r.Reload(); err != nil {
+			klog.Errorf("Failed to reload the tls files, keep serving the previous ones:%v", err)
+			return
+		}
+		klog.Infof("The tls files are reloaded")
+	})
+}
+
+// TLSConfig returns the config of the server, every handshake takes the latest certificate and client CAs.
+func (r *CertificateReloader) TLSConfig() *tls.Config {
+	getCertificate := func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return r.certificate.Load(), nil
+	}
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: getCertificate,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			c := &tls.Config{
+				MinVersion:     tls.VersionTLS12,
+				NextProtos:     []string{"h2", "http/1.1"},
+				GetCertificate: getCertificate,
+			}
+			if clientCAs := r.clientCAs.Load(); clientCAs != nil {
+				c.ClientAuth = tls.RequireAndVerifyClientCert
+				c.ClientCAs = clientCAs
+			}
+			return c, nil
+		},
+	}
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"strings"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+)
+
+type AKSKPair struct {
+	AK string
+	SK string
+}
+
+func ExtractAlibabaCloudAKSKPool() []AKSKPair {
+	return extractAKSKPool(apis.AlibabaCloudAKSKPoolEnv)
+}
+
+func ExtractTencentCloudAKSKPool() []AKSKPair {
+	return extractAKSKPool(apis.TencentCloudAKSKPoolEnv)
+}
+
+func ExtractHuaweiCloudAKSKPool() []AKSKPair {
+	return extractAKSKPool(apis.HuaweiCloudAKSKPoolEnv)
+}
+
+func ExtractVolcengineAKSKPool() []AKSKPair {
+	return extractAKSKPool(apis.VolcengineAKSKPoolEnv)
+}
+
+// extractAKSKPool parses the pool from the env or the secret file of it, the format should be like
+// <ak1>:<sk1>,<ak2>:<sk2>, the pairs can also be separated by new lines in the file
+func extractAKSKPool(env string) []AKSKPair {
+	akskPool := strings.ReplaceAll(getSecretEnv(env), "\n", ",")
+	if akskPool == "" {
+		return nil
+	}
+
+	var akskPair []AKSKPair
+	for _, aksk := range strings.Split(akskPool, ",") {
+		aksk = strings.TrimSpace(aksk)
+		if aksk == "" {
+			continue
+		}
+		akskArray := strings.Split(aksk, ":")
+		if len(akskArray) != 2 {
+			continue
+		}
+
+		akskPair = append(akskPair, AKSKPair{AK: akskArray[0], SK: akskArray[1]})
+	}
+
+	return akskPair
+}
@@ -2,115 +2,97 @@ package client
 
 import (
 	"context"
-	"embed"
 	"encoding/json"
 	"fmt"
 	"io"
-	"math/rand"
 	"net/http"
 	"net/url"
-	"os"
 	"regexp"
 	"strconv"
 	"strings"
-	"sync"
+	"sync/atomic"
 	"time"
 
 	openapi "github.com/alibabacloud-go/darabonba-openapi/v2/client"
 	ecsclient "github.com/alibabacloud-go/ecs-20140526/v4/client"
 	util "github.com/alibabacloud-go/tea-utils/v2/service"
 	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/credentials-go/credentials"
+	"github.com/samber/lo"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog"
 
 	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+	"github.com/cloudpilot-ai/priceserver/pkg/scheduler"
 	"github.com/cloudpilot-ai/priceserver/pkg/tools"
 )
 
-//go:embed builtin-data/*.json
-var file embed.FS
-
-type AKSKPair struct {
-	AK string
-	SK string
-}
-
-func ExtractAlibabaCloudAKSKPool() []AKSKPair {
-	akskPool := os.Getenv(apis.AlibabaCloudAKSKPoolEnv)
-	if akskPool == "" {
-		return nil
-	}
-
-	var akskPair []AKSKPair
-	for _, aksk := range strings.Split(akskPool, ",") {
-		aksk = strings.TrimSpace(aksk)
-		if aksk == "" {
-			continue
-		}
-		akskArray := strings.Split(aksk, ":")
-		if len(akskArray) != 2 {
-			continue
-		}
-
-		akskPair = append(akskPair, AKSKPair{AK: akskArray[0], SK: akskArray[1]})
-	}
-
-	return akskPair
-}
-
 type AlibabaCloudPriceClient struct {
-	akskPool []AKSKPair
+	// credentials is swapped when the credentials are reloaded
+	credentials atomic.Pointer[credentialPool[credentials.Credential]]
 
-	regionList []string
+	// regions are discovered by the first refresh
+	regions *lazyRegions
 
-	dataMutex sync.RWMutex
-	priceData map[string]*apis.RegionalInstancePrice
+	priceStore
 }
 
-func NewAlibabaCloudPriceClient(akskPool []AKSKPair, initialSpotUpdate bool) (*AlibabaCloudPriceClient, error) {
-	data, err := file.ReadFile("builtin-data/alibabacloud_price.json")
+var _ Provider = &AlibabaCloudPriceClient{}
+var _ CredentialHealthReporter = &AlibabaCloudPriceClient{}
+var _ CredentialReloader = &AlibabaCloudPriceClient{}
+
+func NewAlibabaCloudPriceClient(ctx context.Context, credentialConfig AlibabaCloudCredentialConfig,
+	initialSpotUpdate bool) (*AlibabaCloudPriceClient, error) {
+	creds, err := newAlibabaCloudCredentialPool(credentialConfig)
 	if err != nil {
 		return nil, err
 	}
 
 	client := &AlibabaCloudPriceClient{
-		akskPool:   akskPool,
-		regionList: []string{},
-		priceData:  map[string]*apis.RegionalInstancePrice{},
+		priceStore: newPriceStore(apis.AlibabaCloudProviderName),
 	}
-	if err := json.Unmarshal(data, &client.priceData); err != nil {
+	client.credentials.Store(creds)
+	client.defaultDenyRegions = alibabaCloudDefaultDenyRegions
+	if err := client.loadBuiltinData("alibabacloud_price.json"); err != nil {
 		return nil, err
 	}
 
-	if err := client.initialRegions(); err != nil {
-		return nil, err
+	client.regions = newLazyRegions(client.listRegions)
+	// The followers never refresh, so they never discover the regions, the others fail fast on the invalid
+	// credentials
+	if initialSpotUpdate {
+		if _, err := client.regions.get(ctx); err != nil {
+			return nil, err
+		}
 	}
 
+	client.setRefreshers(map[string]func(ctx context.Context){
+		apis.SourceOnDemand: client.RefreshOnDemandPrice,
+		apis.SourceSpot:     client.refreshSpotPrice,
+		apis.SourceMetadata: client.refreshMetadata,
+	})
+
+	client.setInitialSources(apis.SourceSpot)
 	if initialSpotUpdate {
-		client.refreshSpotPrice()
+		client.RefreshInitialSources(ctx)
 	}
 
+	client.refreshInstanceTypeMetadataAndAvailableRegion()
 	return client, nil
 }
 
+// RegisterJobs registers the periodic refreshes of the on-demand and the spot prices.
+func (a *AlibabaCloudPriceClient) RegisterJobs(s *scheduler.Scheduler) error {
+	return registerJobs(s,
+		a.newJob(apis.SourceOnDemand, a.onDemandInterval, a.RefreshOnDemandPrice),
+		a.newJob(apis.SourceSpot, a.spotInterval, a.refreshSpotPrice),
+	)
+}
+
+// Run waits for the ctx only, the periodic refreshes are the jobs registered by RegisterJobs.
 func (a *AlibabaCloudPriceClient) Run(ctx context.Context) {
-	odTicker := time.NewTicker(time.Hour * 24 * 7)
-	defer odTicker.Stop()
-
-	spotTicker := time.NewTicker(time.Minute * 30)
-	defer spotTicker.Stop()
-
-	for {
-		select {
-		case <-odTicker.C:
-			a.RefreshOnDemandPrice()
-		case <-spotTicker.C:
-			a.refreshSpotPrice()
-		case <-ctx.Done():
-			return
-		}
-	}
+	<-ctx.Done()
 }
 
 func getTargetFormatDate() string {
@@ -161,18 +143,26 @@ type RegionalSpotInstancePriceSplit struct {
 	Info         *apis.InstanceTypePrice `json:"info"`
 }
 
-func (a *AlibabaCloudPriceClient) refreshSpotPrice() {
-	rsiPrices := make([]*RegionalSpotInstancePrice, len(a.regionList))
+func (a *AlibabaCloudPriceClient) refreshSpotPrice(ctx context.Context) {
+	startTime := time.Now()
+	regions, ok := a.allowedRegionsOf(ctx, a.regions, apis.SourceSpot)
+	if !ok {
+		return
+	}
+	rsiPrices := make([]*RegionalSpotInstancePrice, len(regions))
+	// regionErrs are the errors of listing the instance types, a region fails when any of its spot prices fails
+	regionErrs := make([]error, len(regions))
 
-	workqueue.ParallelizeUntil(context.Background(), 50, len(a.regionList), func(i int) {
-		instanceTypes, err := a.listInstanceTypes(a.regionList[i])
+	workqueue.ParallelizeUntil(ctx, a.concurrency(50), len(regions), func(i int) {
+		instanceTypes, err := a.listInstanceTypes(ctx, regions[i])
 		if err != nil {
-			klog.Errorf("Failed to list instance types in region %s:%v", a.regionList[i], err)
+			klog.Errorf("Failed to list instance types in region %s:%v", regions[i], err)
+			regionErrs[i] = err
 			return
 		}
 
 		rsiPrices[i] = &RegionalSpotInstancePrice{
-			Region:        a.regionList[i],
+			Region:        regions[i],
 			InstanceTypes: &instanceTypes,
 		}
 	})
@@ -197,24 +187,28 @@ func (a *AlibabaCloudPriceClient) refreshSpotPrice() {
 		}
 	}
 
-	workqueue.ParallelizeUntil(context.Background(), 50, n, func(i int) {
-		client, err := a.createECSClient(rsiPricess[i].Region)
-		if err != nil {
-			klog.Errorf("Failed to create ECS client in region %s:%v", rsiPricess[i].Region, err)
-			return
-		}
-
-		spotPrice, err := getSpotPrice(client, rsiPricess[i].Region, rsiPricess[i].InstanceType)
+	spotErrs := make([]error, n)
+	workqueue.ParallelizeUntil(ctx, a.concurrency(50), n, func(i int) {
+		var spotPrice map[string]float64
+		err := a.callECS(ctx, rsiPricess[i].Region, "DescribeSpotPriceHistory", func(client *ecsclient.Client) (err error) {
+			spotPrice, err = getSpotPrice(client, rsiPricess[i].Region, rsiPricess[i].InstanceType)
+			return err
+		})
 		if err != nil {
 			klog.Errorf("Failed to get spot price in region %s:%v", rsiPricess[i].Region, err)
+			spotErrs[i] = err
 			return
 		}
 
 		rsiPricess[i].Info.SpotPricePerHour = spotPrice
 	})
+	// The spot prices not fetched yet would overwrite the previous ones with nothing
+	if ctx.Err() != nil {
+		klog.Warningf("Refreshing spot prices for AlibabaCloud is canceled:%v", ctx.Err())
+		return
+	}
 
 	a.dataMutex.Lock()
-	defer a.dataMutex.Unlock()
 	for i := range rsiPricess {
 		if _, ok := a.priceData[rsiPricess[i].Region]; !ok {
 			a.priceData[rsiPricess[i].Region] = &apis.RegionalInstancePrice{
@@ -226,7 +220,18 @@ func (a *AlibabaCloudPriceClient) refreshSpotPrice() {
 		}
 		a.priceData[rsiPricess[i].Region].InstanceTypePrices[rsiPricess[i].InstanceType] = rsiPricess[i].Info
 	}
+	a.dataMutex.Unlock()
+
+	for i := range rsiPricess {
+		if spotErrs[i] != nil {
+			regionErrs[lo.IndexOf(regions, rsiPricess[i].Region)] = spotErrs[i]
+		}
+	}
+	for i, region := range regions {
+		a.observeRefresh(region, apis.SourceSpot, startTime, regionErrs[i])
+	}
 
+	a.refreshInstanceTypeMetadataAndAvailableRegion()
 	klog.Infof("All spot prices are refreshed for AlibabaCloud")
 }
 
@@ -242,9 +247,18 @@ type ECSHoursPrice struct {
 	Price string `json:"price"`
 }
 
-func getECSPrice() (map[string]map[string]float64, error) {
+// getWithContext gets the url with the default client, the request is aborted when the ctx is done.
+func getWithContext(ctx context.Context, reqUrl string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(req)
+}
+
+func getECSPrice(ctx context.Context) (map[string]map[string]float64, error) {
 	baseUrl := "https://www.aliyun.com/price/ecs/ecs-pricing/zh"
-	baseResp, err := http.Get(baseUrl)
+	baseResp, err := getWithContext(ctx, baseUrl)
 	if err != nil {
 		klog.Errorf("Get ecs price failed: %v", err)
 		return nil, err
@@ -272,7 +286,7 @@ func getECSPrice() (map[string]map[string]float64, error) {
 		klog.Errorf("Failed to get price request url: %v", err)
 		return nil, err
 	}
-	resp, err := http.Get(reqUrl)
+	resp, err := getWithContext(ctx, reqUrl)
 	if err != nil {
 		klog.Errorf("Get ecs price failed: %v", err)
 		return nil, err
@@ -314,16 +328,25 @@ func getECSPrice() (map[string]map[string]float64, error) {
 	return ret, nil
 }
 
-func (a *AlibabaCloudPriceClient) RefreshOnDemandPrice() {
-	priceInfo, err := getECSPrice()
+func (a *AlibabaCloudPriceClient) RefreshOnDemandPrice(ctx context.Context) {
+	regions, ok := a.allowedRegionsOf(ctx, a.regions, apis.SourceOnDemand)
+	if !ok {
+		return
+	}
+	startTime := time.Now()
+	priceInfo, err := getECSPrice(ctx)
+	a.observeAPICall("GetECSPriceList", err)
 	if err != nil {
+		a.observeRefreshAll(apis.SourceOnDemand, startTime, err)
 		return
 	}
 
 	handleFunc := func(paras ...interface{}) {
 		region := paras[0].(string)
-		instanceTypes, err := a.listInstanceTypes(region)
+		regionStartTime := time.Now()
+		instanceTypes, err := a.listInstanceTypes(ctx, region)
 		if err != nil {
+			a.observeRefresh(region, apis.SourceOnDemand, regionStartTime, err)
 			return
 		}
 
@@ -340,38 +363,43 @@ func (a *AlibabaCloudPriceClient) RefreshOnDemandPrice() {
 		a.dataMutex.Lock()
 		a.priceData[region] = &apis.RegionalInstancePrice{InstanceTypePrices: instanceTypes}
 		a.dataMutex.Unlock()
+		a.observeRefresh(region, apis.SourceOnDemand, regionStartTime, nil)
 	}
 
 	priceTask := tools.NewParallelTask(handleFunc)
-	for _, region := range a.regionList {
+	for _, region := range regions {
 		klog.Infof("Start to handle region %s for on-demand", region)
 
 		priceTask.Add([]interface{}{region})
 	}
-	priceTask.Process()
+	priceTask.Process(ctx)
 
+	a.refreshInstanceTypeMetadataAndAvailableRegion()
 	klog.Infof("All on-demand prices are refreshed for AlibabaCloud")
 }
 
-func (a *AlibabaCloudPriceClient) listInstanceTypes(region string) (map[string]*apis.InstanceTypePrice, error) {
-	client, err := a.createECSClient(region)
-	if err != nil {
-		return nil, err
-	}
-
-	typesResp, err := client.DescribeInstanceTypesWithOptions(&ecsclient.DescribeInstanceTypesRequest{},
-		&util.RuntimeOptions{})
+func (a *AlibabaCloudPriceClient) listInstanceTypes(ctx context.Context, region string) (map[string]*apis.InstanceTypePrice, error) {
+	var typesResp *ecsclient.DescribeInstanceTypesResponse
+	err := a.callECS(ctx, region, "DescribeInstanceTypes", func(client *ecsclient.Client) (err error) {
+		typesResp, err = client.DescribeInstanceTypesWithOptions(&ecsclient.DescribeInstanceTypesRequest{},
+			&util.RuntimeOptions{})
+		return err
+	})
 	if err != nil {
 		klog.Errorf("Failed to list instance types in region %s:%v", region, err)
 		return nil, err
 	}
 
-	availableTypesResp, err := client.DescribeAvailableResource(
-		&ecsclient.DescribeAvailableResourceRequest{
-			RegionId:            tea.String(region),
-			DestinationResource: tea.String("InstanceType"),
-			InstanceChargeType:  tea.String("PostPaid"),
-		})
+	var availableTypesResp *ecsclient.DescribeAvailableResourceResponse
+	err = a.callECS(ctx, region, "DescribeAvailableResource", func(client *ecsclient.Client) (err error) {
+		availableTypesResp, err = client.DescribeAvailableResource(
+			&ecsclient.DescribeAvailableResourceRequest{
+				RegionId:            tea.String(region),
+				DestinationResource: tea.String("InstanceType"),
+				InstanceChargeType:  tea.String("PostPaid"),
+			})
+		return err
+	})
 	if err != nil {
 		klog.Errorf("Failed to list available instance types in region %s:%v", region, err)
 		return nil, err
@@ -436,88 +464,87 @@ func extractECSArch(unFormatedArch string) string {
 	}
 }
 
-var (
-	ignoreRegions = map[string]struct{}{
-		"ap-southeast-2": {}, // ap-southeast-2(Sydney) is shutdown
-	}
-)
+// alibabaCloudDefaultDenyRegions are not refreshed unless the regions are configured
+var alibabaCloudDefaultDenyRegions = []string{
+	"ap-southeast-2", // ap-southeast-2(Sydney) is shutdown
+}
 
-func (a *AlibabaCloudPriceClient) initialRegions() error {
+func (a *AlibabaCloudPriceClient) listRegions(ctx context.Context) ([]string, error) {
 	// We use cn-hangzhou as the default region to list regions
-	client, err := a.createECSClient("cn-hangzhou")
-	if err != nil {
+	var resp *ecsclient.DescribeRegionsResponse
+	err := a.callECS(ctx, "cn-hangzhou", "DescribeRegions", func(client *ecsclient.Client) (err error) {
+		resp, err = client.DescribeRegionsWithOptions(&ecsclient.DescribeRegionsRequest{}, &util.RuntimeOptions{})
 		return err
-	}
-
-	resp, err := client.DescribeRegionsWithOptions(&ecsclient.DescribeRegionsRequest{}, &util.RuntimeOptions{})
+	})
 	if err != nil {
 		klog.Errorf("Failed to list regions:%v", err)
-		return err
+		return nil, err
 	}
 
+	var regions []string
 	for _, regionData := range resp.Body.Regions.Region {
-		if _, ok := ignoreRegions[tea.StringValue(regionData.RegionId)]; ok {
-			continue
-		}
-		a.regionList = append(a.regionList, tea.StringValue(regionData.RegionId))
+		regions = append(regions, tea.StringValue(regionData.RegionId))
 	}
 
-	return nil
+	return regions, nil
 }
 
-func (a *AlibabaCloudPriceClient) createECSClient(region string) (*ecsclient.Client, error) {
-	// Take one ak/sk from pool
-	pick := rand.Intn(len(a.akskPool))
-	ak, sk := a.akskPool[pick].AK, a.akskPool[pick].SK
-	config := &openapi.Config{
-		AccessKeyId:     tea.String(ak),
-		AccessKeySecret: tea.String(sk),
-		RegionId:        tea.String(region),
+// callECS calls the ecs api with one credential from the pool, the result is reported to the pool so that the
+// failing credentials are quarantined. The ecs sdk does not take a ctx, so the ctx is checked before the call
+// only, the calls in flight are bounded by the timeouts of the sdk. api names the call in the metrics.
+func (a *AlibabaCloudPriceClient) callECS(ctx context.Context, region, api string,
+	call func(client *ecsclient.Client) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
-	client, err := ecsclient.NewClient(config)
+
+	// The call reports to the pool it takes the credential from, even if the pool is swapped in the meantime
+	pool := a.credentials.Load()
+	credential, err := pool.get(ctx)
 	if err != nil {
-		klog.Errorf("Failed to create ecs client:%v", err)
-		return nil, err
+		return err
 	}
-	return client, nil
-}
-
-func (a *AlibabaCloudPriceClient) ListRegionsInstancesPrice() map[string]*apis.RegionalInstancePrice {
-	a.dataMutex.RLock()
-	defer a.dataMutex.RUnlock()
 
-	ret := make(map[string]*apis.RegionalInstancePrice)
-	for k, v := range a.priceData {
-		ret[k] = v.DeepCopy()
+	client, err := ecsclient.NewClient(&openapi.Config{
+		Credential: credential.credential,
+		RegionId:   tea.String(region),
+	})
+	if err != nil {
+		klog.Errorf("Failed to create ecs client:%v", err)
+		return err
 	}
-	return ret
-}
 
-func (a *AlibabaCloudPriceClient) ListInstancesPrice(region string) *map[string]apis.RegionalInstancePrice {
-	a.dataMutex.RLock()
-	defer a.dataMutex.RUnlock()
+	err = call(client)
+	a.observeAPICall(api, err)
+	pool.report(credential, classifyAlibabaCloudError(err), err)
+	return err
+}
 
-	d, ok := a.priceData[region]
-	if !ok {
-		return nil
-	}
-	return &map[string]apis.RegionalInstancePrice{
-		region: *d.DeepCopy(),
-	}
+// CredentialHealth returns the health of the credentials in the pool.
+func (a *AlibabaCloudPriceClient) CredentialHealth() []apis.CredentialHealth {
+	return a.credentials.Load().health()
 }
 
-func (a *AlibabaCloudPriceClient) GetInstancePrice(region, instanceType string) *apis.InstanceTypePrice {
-	a.dataMutex.RLock()
-	defer a.dataMutex.RUnlock()
+// SecretFiles returns the secret files of the alibaba cloud credentials.
+func (a *AlibabaCloudPriceClient) SecretFiles() []string {
+	return SecretFiles(alibabaCloudSecretEnvs...)
+}
 
-	regionData, ok := a.priceData[region]
-	if !ok {
-		return nil
+// ReloadCredentials rebuilds the credential pool from the env and the secret files, the health of the
+// credentials starts over.
+func (a *AlibabaCloudPriceClient) ReloadCredentials() error {
+	credentialConfig, err := ExtractAlibabaCloudCredentialConfig()
+	if err != nil {
+		return err
 	}
-	d, ok := regionData.InstanceTypePrices[instanceType]
-	if !ok {
-		return nil
+	if !credentialConfig.Enabled() {
+		return fmt.Errorf("no alibaba cloud credential is set")
+	}
+	pool, err := newAlibabaCloudCredentialPool(credentialConfig)
+	if err != nil {
+		return err
 	}
 
-	return d
+	a.credentials.Store(pool)
+	return nil
 }
@@ -0,0 +1,189 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/credentials-go/credentials"
+	"k8s.io/klog"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+)
+
+const (
+	// AlibabaCloudCredentialRAMRoleARN assumes the role with each key of the pool by STS
+	AlibabaCloudCredentialRAMRoleARN = "ram_role_arn"
+	// AlibabaCloudCredentialECSRAMRole uses the RAM role attached to the ECS instance
+	AlibabaCloudCredentialECSRAMRole = "ecs_ram_role"
+	// AlibabaCloudCredentialOIDCRoleARN assumes the role with the OIDC token, like RRSA on ACK
+	AlibabaCloudCredentialOIDCRoleARN = "oidc_role_arn"
+
+	alibabaCloudRoleSessionName = "priceserver"
+
+	// defaultAlibabaCloudKeyQPS is well below the ECS api limits, so one key can not use up the quota of the pool
+	defaultAlibabaCloudKeyQPS = 10
+
+	// The envs injected by ACK for the pods using RRSA
+	alibabaCloudRRSARoleARNEnv         = "ALIBABA_CLOUD_ROLE_ARN"
+	alibabaCloudRRSAOIDCProviderARNEnv = "ALIBABA_CLOUD_OIDC_PROVIDER_ARN"
+	alibabaCloudRRSAOIDCTokenFileEnv   = "ALIBABA_CLOUD_OIDC_TOKEN_FILE"
+)
+
+// alibabaCloudSecretEnvs can be read from the secret files, the credentials are reloaded when the files change
+var alibabaCloudSecretEnvs = []string{apis.AlibabaCloudAKSKPoolEnv, apis.AlibabaCloudExternalIDEnv}
+
+// AlibabaCloudCredentialConfig describes the credentials used in turn to call alibaba cloud. The keys of the pool
+// are used directly, unless the type is ram_role_arn, then they are used to assume the role. The ecs_ram_role
+// and oidc_role_arn types add one more credential besides the pool. The STS tokens are refreshed before expiring.
+type AlibabaCloudCredentialConfig struct {
+	AKSKPool []AKSKPair
+
+	Type            string
+	RoleARN         string
+	ExternalID      string
+	ECSRAMRole      string
+	OIDCProviderARN string
+	OIDCTokenFile   string
+
+	// KeyQPS is the rate limit of each credential
+	KeyQPS float64
+}
+
+// Enabled returns whether any credential is configured.
+func (c AlibabaCloudCredentialConfig) Enabled() bool {
+	return len(c.AKSKPool) != 0 || c.Type != ""
+}
+
+// ExtractAlibabaCloudCredentialConfig reads the credential config from the env, the RRSA envs injected by ACK are
+// used when the oidc ones are not set.
+func ExtractAlibabaCloudCredentialConfig() (AlibabaCloudCredentialConfig, error) {
+	c := AlibabaCloudCredentialConfig{
+		AKSKPool:        ExtractAlibabaCloudAKSKPool(),
+		Type:            os.Getenv(apis.AlibabaCloudCredentialTypeEnv),
+		RoleARN:         os.Getenv(apis.AlibabaCloudRoleARNEnv),
+		ExternalID:      getSecretEnv(apis.AlibabaCloudExternalIDEnv),
+		ECSRAMRole:      os.Getenv(apis.AlibabaCloudECSRAMRoleEnv),
+		OIDCProviderARN: os.Getenv(apis.AlibabaCloudOIDCProviderARNEnv),
+		OIDCTokenFile:   os.Getenv(apis.AlibabaCloudOIDCTokenFileEnv),
+		KeyQPS:          defaultAlibabaCloudKeyQPS,
+	}
+	if value := os.Getenv(apis.AlibabaCloudKeyQPSEnv); value != "" {
+		qps, err := strconv.ParseFloat(value, 64)
+		if err != nil || qps <= 0 {
+			return c, fmt.Errorf("invalid %s %q, should be a positive number", apis.AlibabaCloudKeyQPSEnv, value)
+		}
+		c.KeyQPS = qps
+	}
+
+	switch c.Type {
+	case "", AlibabaCloudCredentialECSRAMRole:
+	case AlibabaCloudCredentialRAMRoleARN:
+		if c.RoleARN == "" || len(c.AKSKPool) == 0 {
+			return c, fmt.Errorf("both %s and %s are required by the alibaba cloud %s credential",
+				apis.AlibabaCloudRoleARNEnv, apis.AlibabaCloudAKSKPoolEnv, c.Type)
+		}
+	case AlibabaCloudCredentialOIDCRoleARN:
+		if c.RoleARN == "" {
+			c.RoleARN = os.Getenv(alibabaCloudRRSARoleARNEnv)
+		}
+		if c.OIDCProviderARN == "" {
+			c.OIDCProviderARN = os.Getenv(alibabaCloudRRSAOIDCProviderARNEnv)
+		}
+		if c.OIDCTokenFile == "" {
+			c.OIDCTokenFile = os.Getenv(alibabaCloudRRSAOIDCTokenFileEnv)
+		}
+		if c.RoleARN == "" || c.OIDCProviderARN == "" || c.OIDCTokenFile == "" {
+			return c, fmt.Errorf("the role arn, the oidc provider arn and the oidc token file are required by "+
+				"the alibaba cloud %s credential", c.Type)
+		}
+	default:
+		return c, fmt.Errorf("invalid %s %q, should be %s, %s or %s", apis.AlibabaCloudCredentialTypeEnv, c.Type,
+			AlibabaCloudCredentialRAMRoleARN, AlibabaCloudCredentialECSRAMRole, AlibabaCloudCredentialOIDCRoleARN)
+	}
+	return c, nil
+}
+
+// newAlibabaCloudCredentialPool builds the credentials once, the ones backed by STS cache the tokens and refresh
+// them by themselves, so they are shared by all the clients.
+func newAlibabaCloudCredentialPool(c AlibabaCloudCredentialConfig) (*credentialPool[credentials.Credential], error) {
+	var (
+		ids     []string
+		configs []*credentials.Config
+	)
+	for _, aksk := range c.AKSKPool {
+		ids = append(ids, maskAccessKey(aksk.AK))
+		config := &credentials.Config{
+			Type:            tea.String("access_key"),
+			AccessKeyId:     tea.String(aksk.AK),
+			AccessKeySecret: tea.String(aksk.SK),
+		}
+		if c.Type == AlibabaCloudCredentialRAMRoleARN {
+			config.Type = tea.String(AlibabaCloudCredentialRAMRoleARN)
+			config.RoleArn = tea.String(c.RoleARN)
+			config.RoleSessionName = tea.String(alibabaCloudRoleSessionName)
+			if c.ExternalID != "" {
+				config.ExternalId = tea.String(c.ExternalID)
+			}
+		}
+		configs = append(configs, config)
+	}
+
+	switch c.Type {
+	case AlibabaCloudCredentialECSRAMRole:
+		ids = append(ids, AlibabaCloudCredentialECSRAMRole)
+		configs = append(configs, &credentials.Config{
+			Type:     tea.String(AlibabaCloudCredentialECSRAMRole),
+			RoleName: tea.String(c.ECSRAMRole),
+		})
+	case AlibabaCloudCredentialOIDCRoleARN:
+		ids = append(ids, AlibabaCloudCredentialOIDCRoleARN)
+		configs = append(configs, &credentials.Config{
+			Type:              tea.String(AlibabaCloudCredentialOIDCRoleARN),
+			RoleArn:           tea.String(c.RoleARN),
+			OIDCProviderArn:   tea.String(c.OIDCProviderARN),
+			OIDCTokenFilePath: tea.String(c.OIDCTokenFile),
+			RoleSessionName:   tea.String(alibabaCloudRoleSessionName),
+		})
+	}
+
+	ret := make([]credentials.Credential, 0, len(configs))
+	for _, config := range configs {
+		credential, err := credentials.NewCredential(config)
+		if err != nil {
+			klog.Errorf("Failed to create alibaba cloud %s credential:%v", tea.StringValue(config.Type), err)
+			return nil, err
+		}
+		ret = append(ret, credential)
+	}
+	return newCredentialPool(ids, ret, c.KeyQPS), nil
+}
+
+// classifyAlibabaCloudError tells whether the error is caused by the credential, only the errors returned by the
+// api are classified, the others are likely network errors.
+func classifyAlibabaCloudError(err error) credentialErrorKind {
+	if err == nil {
+		return credentialErrorNone
+	}
+	var sdkErr *tea.SDKError
+	if !errors.As(err, &sdkErr) {
+		return credentialErrorOther
+	}
+
+	code, statusCode := tea.StringValue(sdkErr.Code), tea.IntValue(sdkErr.StatusCode)
+	switch {
+	case strings.HasPrefix(code, "Throttling") || statusCode == http.StatusTooManyRequests:
+		return credentialErrorThrottled
+	case strings.HasPrefix(code, "InvalidAccessKeyId"), strings.HasPrefix(code, "InvalidSecurityToken"),
+		strings.HasPrefix(code, "Forbidden"), strings.Contains(code, "QuotaExceed"),
+		code == "SignatureDoesNotMatch", code == "IncompleteSignature",
+		statusCode == http.StatusUnauthorized, statusCode == http.StatusForbidden:
+		return credentialErrorFailed
+	default:
+		return credentialErrorOther
+	}
+}
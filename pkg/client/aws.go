@@ -10,8 +10,6 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/aws/aws-sdk-go-v2/service/pricing"
@@ -19,10 +17,10 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/savingsplans"
 	savingsplanstypes "github.com/aws/aws-sdk-go-v2/service/savingsplans/types"
 	"github.com/samber/lo"
-	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/klog"
 
 	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+	"github.com/cloudpilot-ai/priceserver/pkg/scheduler"
 )
 
 type PriceItem struct {
@@ -44,72 +42,103 @@ type PriceItem struct {
 }
 
 type AWSPriceClient struct {
-	globalAK string
-	globalSK string
-	cnAK     string
-	cnSK     string
+	// configs are the configs of the enabled partitions, they are swapped when the credentials are reloaded
+	configsMutex sync.RWMutex
+	configs      map[string]aws.Config
 
 	triggerChannel chan apis.RegionTypeKey
 
-	dataMutex sync.Mutex
-	priceData map[string]*apis.RegionalInstancePrice
-	// instanceTypeName -> instanceInfo
-	instanceInfos map[string]*apis.InstanceInfo
-	instanceTypes []string
+	priceStore
 }
 
-func NewAWSPriceClient(globalAK, globalSK, cnAK, cnSK string, initialSpotUpdate bool) (*AWSPriceClient, error) {
-	data, err := file.ReadFile("builtin-data/aws_price.json")
+var _ Provider = &AWSPriceClient{}
+var _ CredentialReloader = &AWSPriceClient{}
+
+func NewAWSPriceClient(ctx context.Context, credentialConfig AWSCredentialConfig,
+	initialSpotUpdate bool) (*AWSPriceClient, error) {
+	configs, err := loadAWSConfigs(credentialConfig)
 	if err != nil {
 		return nil, err
 	}
 
 	client := &AWSPriceClient{
-		globalAK:       globalAK,
-		globalSK:       globalSK,
-		cnAK:           cnAK,
-		cnSK:           cnSK,
+		configs:        configs,
 		triggerChannel: make(chan apis.RegionTypeKey, 100),
-		priceData:      map[string]*apis.RegionalInstancePrice{},
+		priceStore:     newPriceStore(apis.AWSProviderName),
 	}
-	if err := json.Unmarshal(data, &client.priceData); err != nil {
+	if err := client.loadBuiltinData("aws_price.json"); err != nil {
 		return nil, err
 	}
 
+	client.setRefreshers(map[string]func(ctx context.Context){
+		apis.SourceOnDemand:    func(ctx context.Context) { client.RefreshOnDemandPrice(ctx, "", "") },
+		apis.SourceSpot:        func(ctx context.Context) { client.refreshSpotPrices(ctx, "", "") },
+		apis.SourceSavingsPlan: func(ctx context.Context) { client.RefreshSavingsPlanPrice(ctx, "", "") },
+		apis.SourceMetadata:    client.refreshMetadata,
+	})
+
+	client.setInitialSources(apis.SourceSpot)
 	if initialSpotUpdate {
-		client.refreshSpotPrices("", "")
+		client.RefreshInitialSources(ctx)
 	}
 
 	client.refreshInstanceTypeMetadataAndAvailableRegion()
 	return client, nil
 }
 
-func (a *AWSPriceClient) Run(ctx context.Context) {
-	odTicker := time.NewTicker(time.Hour * 24 * 7)
-	defer odTicker.Stop()
-
-	spotTicker := time.NewTicker(time.Minute * 30)
-	defer spotTicker.Stop()
-
-	metaTicker := time.NewTicker(time.Hour * 20)
-	defer metaTicker.Stop()
+// RegisterJobs registers the periodic refreshes of every source, the savings plans follow the on-demand interval.
+func (a *AWSPriceClient) RegisterJobs(s *scheduler.Scheduler) error {
+	return registerJobs(s,
+		a.newJob(apis.SourceOnDemand, a.onDemandInterval, func(ctx context.Context) {
+			a.RefreshOnDemandPrice(ctx, "", "")
+		}),
+		a.newJob(apis.SourceSavingsPlan, a.onDemandInterval, func(ctx context.Context) {
+			a.RefreshSavingsPlanPrice(ctx, "", "")
+		}),
+		a.newJob(apis.SourceSpot, a.spotInterval, func(ctx context.Context) {
+			a.refreshSpotPrices(ctx, "", "")
+		}),
+		a.newJob(apis.SourceMetadata, a.metadataInterval, a.refreshMetadata),
+	)
+}
 
+// Run refreshes the instance types missed by GetInstancePrice, the periodic refreshes are the jobs registered by
+// RegisterJobs.
+func (a *AWSPriceClient) Run(ctx context.Context) {
 	for {
 		select {
-		case <-odTicker.C:
-			a.RefreshOnDemandPrice("", "")
-			a.RefreshSavingsPlanPrice("", "")
-		case <-spotTicker.C:
-			a.refreshSpotPrices("", "")
-		case <-metaTicker.C:
-			a.refreshInstanceTypeMetadataAndAvailableRegion()
 		case <-ctx.Done():
 			return
 		case k := <-a.triggerChannel:
-			a.RefreshOnDemandPrice(k.Region, k.InstanceType)
-			a.RefreshSavingsPlanPrice(k.Region, k.InstanceType)
-			a.refreshSpotPrices(k.Region, k.InstanceType)
+			a.RefreshOnDemandPrice(ctx, k.Region, k.InstanceType)
+			a.RefreshSavingsPlanPrice(ctx, k.Region, k.InstanceType)
+			a.refreshSpotPrices(ctx, k.Region, k.InstanceType)
+		}
+	}
+}
+
+// ValidateRefresh accepts the region and the instance type for the prices, the metadata is refreshed as a whole.
+func (a *AWSPriceClient) ValidateRefresh(scope apis.RefreshScope) error {
+	switch scope.Source {
+	case apis.SourceOnDemand, apis.SourceSpot, apis.SourceSavingsPlan:
+		if scope.Region != "" && !a.regionAllowed(scope.Region) {
+			return fmt.Errorf("region %s is not allowed by the settings of the provider", scope.Region)
 		}
+		return nil
+	}
+	return a.priceStore.ValidateRefresh(scope)
+}
+
+func (a *AWSPriceClient) Refresh(ctx context.Context, scope apis.RefreshScope) {
+	switch scope.Source {
+	case apis.SourceOnDemand:
+		a.RefreshOnDemandPrice(ctx, scope.Region, scope.InstanceType)
+	case apis.SourceSpot:
+		a.refreshSpotPrices(ctx, scope.Region, scope.InstanceType)
+	case apis.SourceSavingsPlan:
+		a.RefreshSavingsPlanPrice(ctx, scope.Region, scope.InstanceType)
+	default:
+		a.priceStore.Refresh(ctx, scope)
 	}
 }
 
@@ -145,57 +174,67 @@ func (a *AWSPriceClient) putSpotPriceData(region string, priceData []types.SpotP
 }
 
 func (a *AWSPriceClient) newEC2Client(region string) (*ec2.Client, error) {
-	ak := a.globalAK
-	sk := a.globalSK
-	if strings.HasPrefix(region, "cn-") {
-		ak = a.cnAK
-		sk = a.cnSK
-	}
-	cfg, err := config.LoadDefaultConfig(context.Background(),
-		config.WithRegion(region),
-		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(ak, sk, "")),
-	)
+	cfg, err := a.configOf(region)
 	if err != nil {
-		klog.Errorf("failed to load config, %v", err)
 		return nil, err
 	}
-	return ec2.NewFromConfig(cfg), nil
+	return ec2.NewFromConfig(cfg, func(o *ec2.Options) {
+		o.Region = region
+	}), nil
 }
 
-var spotBaseFilter = []types.Filter{
-	{Name: aws.String("product-description"), Values: []string{"Linux/UNIX"}},
+func (a *AWSPriceClient) partitionEnabled(partition string) bool {
+	a.configsMutex.RLock()
+	defer a.configsMutex.RUnlock()
+
+	_, ok := a.configs[partition]
+	return ok
 }
 
-// refresh the instanceTypeMetadata and instanceTypeAvailableRegion by priceData.
-func (a *AWSPriceClient) refreshInstanceTypeMetadataAndAvailableRegion() {
-	a.dataMutex.Lock()
-	defer a.dataMutex.Unlock()
-	a.instanceInfos = map[string]*apis.InstanceInfo{}
-	a.instanceTypes = []string{}
-
-	for region, data := range a.priceData {
-		for instanceType, priceData := range data.InstanceTypePrices {
-			if _, ok := a.instanceInfos[instanceType]; !ok {
-				a.instanceInfos[instanceType] = &apis.InstanceInfo{
-					InstanceTypeMetadata: priceData.InstanceTypeMetadata,
-					RegionsSet:           sets.Set[string]{},
-				}
-				a.instanceTypes = append(a.instanceTypes, instanceType)
-			}
+// SecretFiles returns the secret files of the aws credentials.
+func (a *AWSPriceClient) SecretFiles() []string {
+	return SecretFiles(awsSecretEnvs...)
+}
 
-			a.instanceInfos[instanceType].RegionsSet.Insert(region)
-		}
+// ReloadCredentials rebuilds the configs from the env and the secret files, the calls in flight keep using
+// the previous configs.
+func (a *AWSPriceClient) ReloadCredentials() error {
+	credentialConfig, err := ExtractAWSCredentialConfig()
+	if err != nil {
+		return err
 	}
+	configs, err := loadAWSConfigs(credentialConfig)
+	if err != nil {
+		return err
+	}
+
+	a.configsMutex.Lock()
+	a.configs = configs
+	a.configsMutex.Unlock()
+	return nil
+}
 
-	for _, info := range a.instanceInfos {
-		info.Regions = info.RegionsSet.UnsortedList()
+// configOf returns the cached config of the partition of the region.
+func (a *AWSPriceClient) configOf(region string) (aws.Config, error) {
+	a.configsMutex.RLock()
+	defer a.configsMutex.RUnlock()
+
+	cfg, ok := a.configs[awsPartition(region)]
+	if !ok {
+		return aws.Config{}, fmt.Errorf("the aws %s partition of region %s is not enabled", awsPartition(region), region)
 	}
+	return cfg, nil
+}
+
+var spotBaseFilter = []types.Filter{
+	{Name: aws.String("product-description"), Values: []string{"Linux/UNIX"}},
 }
 
-func (a *AWSPriceClient) handleSpotPrice(region string, filters []types.Filter) {
+func (a *AWSPriceClient) handleSpotPrice(ctx context.Context, region string, filters []types.Filter) error {
 	client, err := a.newEC2Client(region)
 	if err != nil {
-		return
+		klog.Errorf("failed to create ec2 client, %v", err)
+		return err
 	}
 	startTime := aws.Time(time.Now())
 	token := ""
@@ -209,10 +248,11 @@ func (a *AWSPriceClient) handleSpotPrice(region string, filters []types.Filter)
 			input.NextToken = aws.String(token)
 		}
 
-		data, err := client.DescribeSpotPriceHistory(context.Background(), input)
+		data, err := client.DescribeSpotPriceHistory(ctx, input)
+		a.observeAPICall("DescribeSpotPriceHistory", err)
 		if err != nil {
 			klog.Errorf("failed to get spot price(%s), %v", region, err)
-			return
+			return err
 		}
 
 		if data.NextToken != nil {
@@ -225,11 +265,12 @@ func (a *AWSPriceClient) handleSpotPrice(region string, filters []types.Filter)
 			break
 		}
 	}
+	return nil
 }
 
-func (a *AWSPriceClient) refreshSpotPrices(region, instanceType string) {
+func (a *AWSPriceClient) refreshSpotPrices(ctx context.Context, region, instanceType string) {
 	var wg sync.WaitGroup
-	sem := make(chan struct{}, 10)
+	sem := make(chan struct{}, a.concurrency(10))
 
 	filters := spotBaseFilter
 	if instanceType != "" {
@@ -239,7 +280,7 @@ func (a *AWSPriceClient) refreshSpotPrices(region, instanceType string) {
 		})
 	}
 
-	list, err := a.listRegions()
+	list, err := a.listRegions(ctx)
 	if err != nil {
 		return
 	}
@@ -254,8 +295,14 @@ func (a *AWSPriceClient) refreshSpotPrices(region, instanceType string) {
 		defer func() {
 			<-sem
 		}()
+		// The regions waiting for the semaphore are skipped once the refresh is canceled
+		if ctx.Err() != nil {
+			return
+		}
 
-		a.handleSpotPrice(region, filters)
+		startTime := time.Now()
+		err := a.handleSpotPrice(ctx, region, filters)
+		a.observeRefresh(region, apis.SourceSpot, startTime, err)
 	}
 
 	for _, region := range list {
@@ -282,41 +329,23 @@ func resolvePricingEndpointRegion(region string) string {
 }
 
 func (a *AWSPriceClient) newPriceClient(region string) (*pricing.Client, error) {
-	ak := a.globalAK
-	sk := a.globalSK
-	if strings.HasPrefix(region, "cn-") {
-		ak = a.cnAK
-		sk = a.cnSK
-	}
-	cfg, err := config.LoadDefaultConfig(context.Background(),
-		config.WithRegion(region),
-		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(ak, sk, "")),
-	)
+	cfg, err := a.configOf(region)
 	if err != nil {
-		klog.Errorf("failed to load config, %v", err)
 		return nil, err
 	}
-
-	return pricing.NewFromConfig(cfg), nil
+	return pricing.NewFromConfig(cfg, func(o *pricing.Options) {
+		o.Region = region
+	}), nil
 }
 
 func (a *AWSPriceClient) newSavingsPlanClient(region string) (*savingsplans.Client, error) {
-	ak := a.globalAK
-	sk := a.globalSK
-	if strings.HasPrefix(region, "cn-") {
-		ak = a.cnAK
-		sk = a.cnSK
-	}
-
-	cfg, err := config.LoadDefaultConfig(context.Background(),
-		config.WithRegion(region),
-		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(ak, sk, "")))
+	cfg, err := a.configOf(region)
 	if err != nil {
-		klog.Errorf("failed to load config, %v", err)
 		return nil, err
 	}
-
-	return savingsplans.NewFromConfig(cfg), nil
+	return savingsplans.NewFromConfig(cfg, func(o *savingsplans.Options) {
+		o.Region = region
+	}), nil
 }
 
 var onDemandBaseFilters = []pricingtypes.Filter{
@@ -357,16 +386,18 @@ var onDemandBaseFilters = []pricingtypes.Filter{
 	},
 }
 
-func (a *AWSPriceClient) handleOnDemandPrice(region string, filters []pricingtypes.Filter) {
-	zones, err := a.getAvailableZones(region)
+func (a *AWSPriceClient) handleOnDemandPrice(ctx context.Context, region string,
+	filters []pricingtypes.Filter) error {
+	zones, err := a.getAvailableZones(ctx, region)
 	if err != nil {
 		klog.Errorf("failed to get available zones, %v", err)
-		return
+		return err
 	}
 
 	client, err := a.newPriceClient(resolvePricingEndpointRegion(region))
 	if err != nil {
-		return
+		klog.Errorf("failed to create pricing client, %v", err)
+		return err
 	}
 
 	currentFilter := []pricingtypes.Filter{
@@ -389,10 +420,11 @@ func (a *AWSPriceClient) handleOnDemandPrice(region string, filters []pricingtyp
 			input.NextToken = aws.String(token)
 		}
 
-		data, err := client.GetProducts(context.Background(), input)
+		data, err := client.GetProducts(ctx, input)
+		a.observeAPICall("GetProducts", err)
 		if err != nil {
 			klog.Errorf("failed to get ondemand price, %v", err)
-			return
+			return err
 		}
 
 		if data.NextToken != nil {
@@ -405,9 +437,10 @@ func (a *AWSPriceClient) handleOnDemandPrice(region string, filters []pricingtyp
 			break
 		}
 	}
+	return nil
 }
 
-func (a *AWSPriceClient) RefreshOnDemandPrice(region, instanceType string) {
+func (a *AWSPriceClient) RefreshOnDemandPrice(ctx context.Context, region, instanceType string) {
 	filters := onDemandBaseFilters
 	if instanceType != "" {
 		filters = append(filters, pricingtypes.Filter{
@@ -417,7 +450,7 @@ func (a *AWSPriceClient) RefreshOnDemandPrice(region, instanceType string) {
 		})
 	}
 
-	list, err := a.listRegions()
+	list, err := a.listRegions(ctx)
 	if err != nil {
 		return
 	}
@@ -426,7 +459,7 @@ func (a *AWSPriceClient) RefreshOnDemandPrice(region, instanceType string) {
 	}
 
 	var wg sync.WaitGroup
-	sem := make(chan struct{}, 10)
+	sem := make(chan struct{}, a.concurrency(10))
 
 	handleFunc := func(region string) {
 		defer wg.Done()
@@ -434,8 +467,14 @@ func (a *AWSPriceClient) RefreshOnDemandPrice(region, instanceType string) {
 		defer func() {
 			<-sem
 		}()
+		// The regions waiting for the semaphore are skipped once the refresh is canceled
+		if ctx.Err() != nil {
+			return
+		}
 
-		a.handleOnDemandPrice(region, filters)
+		startTime := time.Now()
+		err := a.handleOnDemandPrice(ctx, region, filters)
+		a.observeRefresh(region, apis.SourceOnDemand, startTime, err)
 	}
 
 	for _, region := range list {
@@ -448,42 +487,50 @@ func (a *AWSPriceClient) RefreshOnDemandPrice(region, instanceType string) {
 	klog.Infof("All ondemand prices are refreshed")
 }
 
-func (a *AWSPriceClient) listRegions() ([]string, error) {
-	globalEC2Client, err := a.newEC2Client("us-east-2")
-	if err != nil {
-		return nil, err
-	}
+// listRegions lists the regions of the enabled partitions, so either partition can be served alone.
+func (a *AWSPriceClient) listRegions(ctx context.Context) ([]string, error) {
+	var ret []string
+	if a.partitionEnabled(AWSGlobalPartition) {
+		globalEC2Client, err := a.newEC2Client("us-east-2")
+		if err != nil {
+			return nil, err
+		}
 
-	globalOutput, err := globalEC2Client.DescribeRegions(context.Background(), &ec2.DescribeRegionsInput{AllRegions: aws.Bool(true)})
-	if err != nil {
-		klog.Errorf("Failed to list all global regions:%v", err)
-		return nil, err
+		globalOutput, err := globalEC2Client.DescribeRegions(ctx, &ec2.DescribeRegionsInput{AllRegions: aws.Bool(true)})
+		a.observeAPICall("DescribeRegions", err)
+		if err != nil {
+			klog.Errorf("Failed to list all global regions:%v", err)
+			return nil, err
+		}
+
+		ret = append(ret, lo.Map(globalOutput.Regions, func(item types.Region, index int) string {
+			return aws.ToString(item.RegionName)
+		})...)
 	}
 
-	ret := lo.Map(globalOutput.Regions, func(item types.Region, index int) string {
-		return aws.ToString(item.RegionName)
-	})
+	if a.partitionEnabled(AWSCNPartition) {
+		cnEC2Client, err := a.newEC2Client("cn-north-1")
+		if err != nil {
+			return nil, err
+		}
 
-	cnEC2Client, err := a.newEC2Client("cn-north-1")
-	if err != nil {
-		return nil, err
-	}
+		cnOutput, err := cnEC2Client.DescribeRegions(ctx, &ec2.DescribeRegionsInput{AllRegions: aws.Bool(true)})
+		a.observeAPICall("DescribeRegions", err)
+		if err != nil {
+			klog.Errorf("Failed to list all cn regions:%v", err)
+			return nil, err
+		}
 
-	cnOutput, err := cnEC2Client.DescribeRegions(context.Background(), &ec2.DescribeRegionsInput{AllRegions: aws.Bool(true)})
-	if err != nil {
-		klog.Errorf("Failed to list all cn regions:%v", err)
-		return nil, err
+		ret = append(ret, lo.Map(cnOutput.Regions, func(item types.Region, index int) string {
+			return aws.ToString(item.RegionName)
+		})...)
 	}
 
-	ret = append(ret, lo.Map(cnOutput.Regions, func(item types.Region, index int) string {
-		return aws.ToString(item.RegionName)
-	})...)
-
-	return ret, nil
+	return a.allowedRegions(ret), nil
 }
 
-func (a *AWSPriceClient) handleSavingsPlanPrice(region string,
-	baseFilters []savingsplanstypes.SavingsPlanOfferingRateFilterElement) {
+func (a *AWSPriceClient) handleSavingsPlanPrice(ctx context.Context, region string,
+	baseFilters []savingsplanstypes.SavingsPlanOfferingRateFilterElement) error {
 	filters := append(baseFilters, savingsplanstypes.SavingsPlanOfferingRateFilterElement{
 		Name: savingsplanstypes.SavingsPlanRateFilterAttributeRegion,
 		Values: []string{
@@ -505,7 +552,7 @@ func (a *AWSPriceClient) handleSavingsPlanPrice(region string,
 	client, err := a.newSavingsPlanClient(region)
 	if err != nil {
 		klog.Errorf("failed to create savings plan client, %v", err)
-		return
+		return err
 	}
 
 	token := ""
@@ -515,10 +562,11 @@ func (a *AWSPriceClient) handleSavingsPlanPrice(region string,
 			input.NextToken = aws.String(token)
 		}
 
-		data, err := client.DescribeSavingsPlansOfferingRates(context.Background(), input)
+		data, err := client.DescribeSavingsPlansOfferingRates(ctx, input)
+		a.observeAPICall("DescribeSavingsPlansOfferingRates", err)
 		if err != nil {
 			klog.Errorf("failed to get savings plan price, %v", err)
-			return
+			return err
 		}
 
 		if aws.ToString(data.NextToken) != "" {
@@ -531,9 +579,10 @@ func (a *AWSPriceClient) handleSavingsPlanPrice(region string,
 			break
 		}
 	}
+	return nil
 }
 
-func (a *AWSPriceClient) RefreshSavingsPlanPrice(region, instanceType string) {
+func (a *AWSPriceClient) RefreshSavingsPlanPrice(ctx context.Context, region, instanceType string) {
 	baseFilters := []savingsplanstypes.SavingsPlanOfferingRateFilterElement{
 		{
 			Name: savingsplanstypes.SavingsPlanRateFilterAttributeProductDescription,
@@ -558,7 +607,7 @@ func (a *AWSPriceClient) RefreshSavingsPlanPrice(region, instanceType string) {
 	}
 
 	var wg sync.WaitGroup
-	sem := make(chan struct{}, 10)
+	sem := make(chan struct{}, a.concurrency(10))
 
 	handleFunc := func(region string) {
 		defer wg.Done()
@@ -567,11 +616,17 @@ func (a *AWSPriceClient) RefreshSavingsPlanPrice(region, instanceType string) {
 		defer func() {
 			<-sem
 		}()
+		// The regions waiting for the semaphore are skipped once the refresh is canceled
+		if ctx.Err() != nil {
+			return
+		}
 
-		a.handleSavingsPlanPrice(region, baseFilters)
+		startTime := time.Now()
+		err := a.handleSavingsPlanPrice(ctx, region, baseFilters)
+		a.observeRefresh(region, apis.SourceSavingsPlan, startTime, err)
 	}
 
-	list, err := a.listRegions()
+	list, err := a.listRegions(ctx)
 	if err != nil {
 		return
 	}
@@ -590,7 +645,7 @@ func (a *AWSPriceClient) RefreshSavingsPlanPrice(region, instanceType string) {
 	klog.Infof("All savings plan prices are refreshed")
 }
 
-func (a *AWSPriceClient) getAvailableZones(region string) ([]string, error) {
+func (a *AWSPriceClient) getAvailableZones(ctx context.Context, region string) ([]string, error) {
 	client, err := a.newEC2Client(region)
 	if err != nil {
 		klog.Errorf("failed to create ec2 client, %v", err)
@@ -605,7 +660,8 @@ func (a *AWSPriceClient) getAvailableZones(region string) ([]string, error) {
 		},
 	}
 
-	out, err := client.DescribeAvailabilityZones(context.Background(), &in)
+	out, err := client.DescribeAvailabilityZones(ctx, &in)
+	a.observeAPICall("DescribeAvailabilityZones", err)
 	if err != nil {
 		klog.Errorf("failed to get available zones for %s, %v", region, err)
 		return nil, err
@@ -745,7 +801,7 @@ func (a *AWSPriceClient) putOnDemandPriceData(region string, zones []string, pri
 		}
 
 		currency := "USD"
-		if strings.HasPrefix(region, "cn-") {
+		if awsPartition(region) == AWSCNPartition {
 			currency = "CNY"
 		}
 		for _, term := range item.Terms.OnDemand {
@@ -774,11 +830,14 @@ func (a *AWSPriceClient) putOnDemandPriceData(region string, zones []string, pri
 }
 
 func (a *AWSPriceClient) ListRegionsInstancesPrice() map[string]*apis.RegionalInstancePrice {
-	a.dataMutex.Lock()
-	defer a.dataMutex.Unlock()
+	a.dataMutex.RLock()
+	defer a.dataMutex.RUnlock()
 
 	ret := make(map[string]*apis.RegionalInstancePrice)
 	for k, v := range a.priceData {
+		if !a.regionAllowed(k) {
+			continue
+		}
 		ret[k] = v.DeepCopy()
 		// TODO: this line is used to ensure the api compatibility, we should remove this line in the future
 		ret[k].InstanceTypeEC2Price = ret[k].InstanceTypePrices
@@ -787,11 +846,11 @@ func (a *AWSPriceClient) ListRegionsInstancesPrice() map[string]*apis.RegionalIn
 }
 
 func (a *AWSPriceClient) ListInstancesPrice(region string) *map[string]apis.RegionalInstancePrice {
-	a.dataMutex.Lock()
-	defer a.dataMutex.Unlock()
+	a.dataMutex.RLock()
+	defer a.dataMutex.RUnlock()
 
 	d, ok := a.priceData[region]
-	if !ok {
+	if !ok || !a.regionAllowed(region) {
 		return nil
 	}
 
@@ -811,28 +870,18 @@ func (a *AWSPriceClient) GetInstancePrice(region, instanceType string) *apis.Ins
 	defer a.dataMutex.Unlock()
 
 	regionData, ok := a.priceData[region]
-	if !ok {
+	if !ok || !a.regionAllowed(region) {
 		return nil
 	}
 	d, ok := regionData.InstanceTypePrices[instanceType]
 	if !ok {
-		a.triggerChannel <- apis.RegionTypeKey{Region: region, InstanceType: instanceType}
+		// The trigger is dropped when the channel is full, like on the followers not running the Run loop
+		select {
+		case a.triggerChannel <- apis.RegionTypeKey{Region: region, InstanceType: instanceType}:
+		default:
+		}
 		return nil
 	}
 
 	return d
 }
-
-func (a *AWSPriceClient) ListInstanceTypes() []string {
-	a.dataMutex.Lock()
-	defer a.dataMutex.Unlock()
-
-	return a.instanceTypes
-}
-
-func (a *AWSPriceClient) GetInstanceInfo(instanceType string) *apis.InstanceInfo {
-	a.dataMutex.Lock()
-	defer a.dataMutex.Unlock()
-
-	return a.instanceInfos[instanceType]
-}
@@ -0,0 +1,149 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"k8s.io/klog"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+)
+
+const (
+	AWSGlobalPartition = "global"
+	AWSCNPartition     = "cn"
+
+	awsRoleSessionName = "priceserver"
+)
+
+// awsSecretEnvs can be read from the secret files, the credentials are reloaded when the files change
+var awsSecretEnvs = []string{
+	apis.AWSGlobalAKEnv, apis.AWSGlobalSKEnv, apis.AWSGlobalExternalIDEnv,
+	apis.AWSCNAKEnv, apis.AWSCNSKEnv, apis.AWSCNExternalIDEnv,
+}
+
+// awsPartitionDefaultRegions are used to load the config and to call sts in each partition
+var awsPartitionDefaultRegions = map[string]string{
+	AWSGlobalPartition: "us-east-1",
+	AWSCNPartition:     "cn-north-1",
+}
+
+// AWSPartitionCredential is the credential of one aws partition. The static keys are used when they are set,
+// otherwise the default credential chain is used, which covers the env, the shared config, IRSA and the
+// instance profile. The role is assumed on top of either of them when RoleARN is set.
+type AWSPartitionCredential struct {
+	Enabled    bool
+	AccessKey  string
+	SecretKey  string
+	RoleARN    string
+	ExternalID string
+}
+
+type AWSCredentialConfig struct {
+	Global AWSPartitionCredential
+	CN     AWSPartitionCredential
+}
+
+// Enabled returns whether any partition is enabled.
+func (c AWSCredentialConfig) Enabled() bool {
+	return c.Global.Enabled || c.CN.Enabled
+}
+
+func (c AWSCredentialConfig) partitions() map[string]AWSPartitionCredential {
+	return map[string]AWSPartitionCredential{
+		AWSGlobalPartition: c.Global,
+		AWSCNPartition:     c.CN,
+	}
+}
+
+// ExtractAWSCredentialConfig reads the credentials of the partitions from the env. The partitions listed in
+// AWS_PARTITIONS are enabled, and a partition is enabled by default when its keys or its role are set.
+func ExtractAWSCredentialConfig() (AWSCredentialConfig, error) {
+	c := AWSCredentialConfig{
+		Global: AWSPartitionCredential{
+			AccessKey:  getSecretEnv(apis.AWSGlobalAKEnv),
+			SecretKey:  getSecretEnv(apis.AWSGlobalSKEnv),
+			RoleARN:    os.Getenv(apis.AWSGlobalRoleARNEnv),
+			ExternalID: getSecretEnv(apis.AWSGlobalExternalIDEnv),
+		},
+		CN: AWSPartitionCredential{
+			AccessKey:  getSecretEnv(apis.AWSCNAKEnv),
+			SecretKey:  getSecretEnv(apis.AWSCNSKEnv),
+			RoleARN:    os.Getenv(apis.AWSCNRoleARNEnv),
+			ExternalID: getSecretEnv(apis.AWSCNExternalIDEnv),
+		},
+	}
+
+	for name, cred := range map[string]*AWSPartitionCredential{AWSGlobalPartition: &c.Global, AWSCNPartition: &c.CN} {
+		if (cred.AccessKey == "") != (cred.SecretKey == "") {
+			return c, fmt.Errorf("both access key and secret key of the aws %s partition should be set", name)
+		}
+		cred.Enabled = cred.AccessKey != "" || cred.RoleARN != ""
+	}
+
+	if partitions := os.Getenv(apis.AWSPartitionsEnv); partitions != "" {
+		c.Global.Enabled, c.CN.Enabled = false, false
+		for _, partition := range strings.Split(partitions, ",") {
+			switch strings.TrimSpace(partition) {
+			case AWSGlobalPartition:
+				c.Global.Enabled = true
+			case AWSCNPartition:
+				c.CN.Enabled = true
+			default:
+				return c, fmt.Errorf("invalid aws partition %q in %s, should be %s or %s", partition,
+					apis.AWSPartitionsEnv, AWSGlobalPartition, AWSCNPartition)
+			}
+		}
+	}
+	return c, nil
+}
+
+// awsPartition returns the partition of the region.
+func awsPartition(region string) string {
+	if strings.HasPrefix(region, "cn-") {
+		return AWSCNPartition
+	}
+	return AWSGlobalPartition
+}
+
+// loadAWSConfigs builds the config of each enabled partition once, the credentials in them are cached and
+// refreshed before expiring by the sdk.
+func loadAWSConfigs(c AWSCredentialConfig) (map[string]aws.Config, error) {
+	configs := map[string]aws.Config{}
+	for partition, cred := range c.partitions() {
+		if !cred.Enabled {
+			continue
+		}
+
+		opts := []func(*config.LoadOptions) error{config.WithRegion(awsPartitionDefaultRegions[partition])}
+		if cred.AccessKey != "" {
+			opts = append(opts, config.WithCredentialsProvider(
+				credentials.NewStaticCredentialsProvider(cred.AccessKey, cred.SecretKey, "")))
+		}
+		cfg, err := config.LoadDefaultConfig(context.Background(), opts...)
+		if err != nil {
+			klog.Errorf("Failed to load aws config of the %s partition:%v", partition, err)
+			return nil, err
+		}
+
+		if cred.RoleARN != "" {
+			provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), cred.RoleARN,
+				func(o *stscreds.AssumeRoleOptions) {
+					o.RoleSessionName = awsRoleSessionName
+					if cred.ExternalID != "" {
+						o.ExternalID = aws.String(cred.ExternalID)
+					}
+				})
+			cfg.Credentials = aws.NewCredentialsCache(provider)
+		}
+		configs[partition] = cfg
+	}
+	return configs, nil
+}
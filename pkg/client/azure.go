@@ -0,0 +1,468 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+)
+
+const (
+	DefaultAzureRetailPricesEndpoint = "https://prices.azure.com/api/retail/prices"
+	DefaultAzureManagementEndpoint   = "https://management.azure.com"
+	DefaultAzureLoginEndpoint        = "https://login.microsoftonline.com"
+
+	azureRetailPricesAPIVersion = "2023-01-01-preview"
+	azureResourceSKUsAPIVersion = "2021-07-01"
+)
+
+type AzureConfig struct {
+	TenantID       string
+	ClientID       string
+	ClientSecret   string
+	SubscriptionID string
+
+	// The endpoints can be overridden to work with recorded or mocked responses
+	RetailPricesEndpoint string
+	ManagementEndpoint   string
+	LoginEndpoint        string
+}
+
+func ExtractAzureConfig() AzureConfig {
+	return AzureConfig{
+		TenantID:       os.Getenv(apis.AzureTenantIDEnv),
+		ClientID:       os.Getenv(apis.AzureClientIDEnv),
+		ClientSecret:   getSecretEnv(apis.AzureClientSecretEnv),
+		SubscriptionID: os.Getenv(apis.AzureSubscriptionIDEnv),
+
+		RetailPricesEndpoint: os.Getenv(apis.AzureRetailPricesEndpointEnv),
+		ManagementEndpoint:   os.Getenv(apis.AzureManagementEndpointEnv),
+		LoginEndpoint:        os.Getenv(apis.AzureLoginEndpointEnv),
+	}
+}
+
+type AzurePriceClient struct {
+	config AzureConfig
+	// credConfig authorizes the calls to the azure resource manager, the token is cached and fetched with the ctx
+	// of the refresh, so a stuck token request is canceled with the refresh as well
+	credConfig *clientcredentials.Config
+	tokenMutex sync.Mutex
+	token      *oauth2.Token
+
+	priceStore
+}
+
+var _ Provider = &AzurePriceClient{}
+
+func NewAzurePriceClient(ctx context.Context, config AzureConfig, initialSpotUpdate bool) (*AzurePriceClient, error) {
+	if config.RetailPricesEndpoint == "" {
+		config.RetailPricesEndpoint = DefaultAzureRetailPricesEndpoint
+	}
+	if config.ManagementEndpoint == "" {
+		config.ManagementEndpoint = DefaultAzureManagementEndpoint
+	}
+	if config.LoginEndpoint == "" {
+		config.LoginEndpoint = DefaultAzureLoginEndpoint
+	}
+
+	credConfig := &clientcredentials.Config{
+		ClientID:     config.ClientID,
+		ClientSecret: config.ClientSecret,
+		TokenURL:     fmt.Sprintf("%s/%s/oauth2/v2.0/token", config.LoginEndpoint, config.TenantID),
+		Scopes:       []string{config.ManagementEndpoint + "/.default"},
+	}
+
+	client := &AzurePriceClient{
+		config:     config,
+		credConfig: credConfig,
+		priceStore: newPriceStore(apis.AzureProviderName),
+	}
+	if err := client.loadBuiltinData("azure_price.json"); err != nil {
+		return nil, err
+	}
+
+	client.setRefreshers(map[string]func(ctx context.Context){
+		apis.SourceOnDemand: client.RefreshOnDemandPrice,
+		apis.SourceSpot:     client.refreshSpotPrices,
+		apis.SourceMetadata: client.refreshMetadata,
+	})
+
+	client.setInitialSources(apis.SourceSpot)
+	if initialSpotUpdate {
+		client.RefreshInitialSources(ctx)
+	}
+
+	client.refreshInstanceTypeMetadataAndAvailableRegion()
+	return client, nil
+}
+
+func (a *AzurePriceClient) Run(ctx context.Context) {
+	odTicker := time.NewTicker(a.onDemandInterval())
+	defer odTicker.Stop()
+
+	spotTicker := time.NewTicker(a.spotInterval())
+	defer spotTicker.Stop()
+
+	for {
+		select {
+		case <-odTicker.C:
+			a.RefreshOnDemandPrice(ctx)
+		case <-spotTicker.C:
+			a.refreshSpotPrices(ctx)
+		case <-a.settingsUpdated:
+			odTicker.Reset(a.onDemandInterval())
+			spotTicker.Reset(a.spotInterval())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+type azureResourceSKUList struct {
+	Value    []azureResourceSKU `json:"value"`
+	NextLink string             `json:"nextLink"`
+}
+
+type azureResourceSKU struct {
+	ResourceType string `json:"resourceType"`
+	Name         string `json:"name"`
+	LocationInfo []struct {
+		Location string   `json:"location"`
+		Zones    []string `json:"zones"`
+	} `json:"locationInfo"`
+	Capabilities []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"capabilities"`
+	Restrictions []struct {
+		Type       string   `json:"type"`
+		Values     []string `json:"values"`
+		ReasonCode string   `json:"reasonCode"`
+	} `json:"restrictions"`
+}
+
+type azureRetailPriceList struct {
+	Items        []azureRetailPrice `json:"Items"`
+	NextPageLink string             `json:"NextPageLink"`
+}
+
+type azureRetailPrice struct {
+	ArmRegionName      string  `json:"armRegionName"`
+	ArmSkuName         string  `json:"armSkuName"`
+	MeterName          string  `json:"meterName"`
+	ProductName        string  `json:"productName"`
+	Type               string  `json:"type"`
+	UnitPrice          float64 `json:"unitPrice"`
+	UnitOfMeasure      string  `json:"unitOfMeasure"`
+	ReservationTerm    string  `json:"reservationTerm"`
+	EffectiveStartDate string  `json:"effectiveStartDate"`
+}
+
+func extractAzureArch(arch string) string {
+	switch strings.ToLower(arch) {
+	case "arm64":
+		return "arm64"
+	default:
+		return "amd64"
+	}
+}
+
+// managementClient returns the client authorized to call the azure resource manager.
+func (a *AzurePriceClient) managementClient(ctx context.Context) (*http.Client, error) {
+	a.tokenMutex.Lock()
+	defer a.tokenMutex.Unlock()
+
+	if !a.token.Valid() {
+		token, err := a.credConfig.Token(ctx)
+		a.observeAPICall("GetToken", err)
+		if err != nil {
+			klog.Errorf("Failed to get azure token: %v", err)
+			return nil, err
+		}
+		a.token = token
+	}
+	return oauth2.NewClient(ctx, oauth2.StaticTokenSource(a.token)), nil
+}
+
+// listVMSizes returns the metadata and the zones of the vm sizes available to the subscription, grouped by region.
+func (a *AzurePriceClient) listVMSizes(ctx context.Context) (map[string]map[string]*apis.InstanceTypePrice, error) {
+	query := url.Values{}
+	query.Set("api-version", azureResourceSKUsAPIVersion)
+	reqUrl := fmt.Sprintf("%s/subscriptions/%s/providers/Microsoft.Compute/skus?%s",
+		a.config.ManagementEndpoint, a.config.SubscriptionID, query.Encode())
+
+	ret := map[string]map[string]*apis.InstanceTypePrice{}
+	for reqUrl != "" {
+		managementClient, err := a.managementClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var data azureResourceSKUList
+		err = getJSON(ctx, managementClient, reqUrl, &data)
+		a.observeAPICall("ListResourceSKUs", err)
+		if err != nil {
+			klog.Errorf("Failed to list azure resource skus: %v", err)
+			return nil, err
+		}
+
+		for _, sku := range data.Value {
+			if sku.ResourceType != "virtualMachines" {
+				continue
+			}
+
+			metadata := apis.InstanceTypeMetadata{Arch: "amd64"}
+			for _, c := range sku.Capabilities {
+				switch c.Name {
+				case "vCPUs":
+					metadata.VCPU, _ = strconv.ParseFloat(c.Value, 64)
+				case "MemoryGB":
+					metadata.Memory, _ = strconv.ParseFloat(c.Value, 64)
+				case "GPUs":
+					metadata.GPU, _ = strconv.ParseFloat(c.Value, 64)
+				case "CpuArchitectureType":
+					metadata.Arch = extractAzureArch(c.Value)
+				}
+			}
+
+			restricted := sets.Set[string]{}
+			for _, r := range sku.Restrictions {
+				if r.Type == "Location" && r.ReasonCode == "NotAvailableForSubscription" {
+					restricted.Insert(lowerAll(r.Values)...)
+				}
+			}
+
+			for _, info := range sku.LocationInfo {
+				region := strings.ToLower(info.Location)
+				if restricted.Has(region) {
+					continue
+				}
+				if _, ok := ret[region]; !ok {
+					ret[region] = map[string]*apis.InstanceTypePrice{}
+				}
+				// Azure zones are numbered per region, align them with the topology.kubernetes.io/zone label of AKS
+				zones := make([]string, 0, len(info.Zones))
+				for _, z := range info.Zones {
+					zones = append(zones, fmt.Sprintf("%s-%s", region, z))
+				}
+				ret[region][sku.Name] = &apis.InstanceTypePrice{
+					InstanceTypeMetadata: metadata,
+					Zones:                zones,
+				}
+			}
+		}
+
+		reqUrl = data.NextLink
+	}
+
+	return ret, nil
+}
+
+func lowerAll(values []string) []string {
+	ret := make([]string, len(values))
+	for i := range values {
+		ret[i] = strings.ToLower(values[i])
+	}
+	return ret
+}
+
+// listRetailPrices returns the linux retail prices of virtual machines in the region that match the filter.
+func (a *AzurePriceClient) listRetailPrices(ctx context.Context, region, filter string) ([]azureRetailPrice, error) {
+	query := url.Values{}
+	query.Set("api-version", azureRetailPricesAPIVersion)
+	query.Set("$filter", fmt.Sprintf("serviceName eq 'Virtual Machines' and armRegionName eq '%s'%s", region, filter))
+	reqUrl := fmt.Sprintf("%s?%s", a.config.RetailPricesEndpoint, query.Encode())
+
+	var ret []azureRetailPrice
+	for reqUrl != "" {
+		var data azureRetailPriceList
+		err := getJSON(ctx, http.DefaultClient, reqUrl, &data)
+		a.observeAPICall("ListRetailPrices", err)
+		if err != nil {
+			klog.Errorf("Failed to list azure retail prices in region %s: %v", region, err)
+			return nil, err
+		}
+
+		for _, item := range data.Items {
+			if strings.HasSuffix(item.ProductName, " Windows") ||
+				strings.Contains(item.MeterName, "Low Priority") ||
+				item.UnitPrice == 0 {
+				continue
+			}
+			ret = append(ret, item)
+		}
+
+		reqUrl = data.NextPageLink
+	}
+
+	return ret, nil
+}
+
+func isAzureSpotMeter(item azureRetailPrice) bool {
+	return strings.HasSuffix(item.MeterName, " Spot")
+}
+
+func azureReservationHours(term string) (string, float64, bool) {
+	switch term {
+	case "1 Year":
+		return "1yr", 365 * 24, true
+	case "3 Years":
+		return "3yr", 3 * 365 * 24, true
+	default:
+		return "", 0, false
+	}
+}
+
+func (a *AzurePriceClient) RefreshOnDemandPrice(ctx context.Context) {
+	startTime := time.Now()
+	vmSizes, err := a.listVMSizes(ctx)
+	if err != nil {
+		a.observeRefreshAll(apis.SourceOnDemand, startTime, err)
+		return
+	}
+
+	regions := make([]string, 0, len(vmSizes))
+	for region := range vmSizes {
+		regions = append(regions, region)
+	}
+	regions = a.allowedRegions(regions)
+	workqueue.ParallelizeUntil(ctx, a.concurrency(10), len(regions), func(i int) {
+		region := regions[i]
+		klog.Infof("Start to handle region %s for on-demand", region)
+
+		regionStartTime := time.Now()
+		items, err := a.listRetailPrices(ctx, region, "")
+		a.observeRefresh(region, apis.SourceOnDemand, regionStartTime, err)
+		if err != nil {
+			return
+		}
+
+		instanceTypes := map[string]*apis.InstanceTypePrice{}
+		startDates := map[string]string{}
+		for _, item := range items {
+			size, ok := vmSizes[region][item.ArmSkuName]
+			if !ok {
+				continue
+			}
+			ins, ok := instanceTypes[item.ArmSkuName]
+			if !ok {
+				ins = size.DeepCopy()
+				instanceTypes[item.ArmSkuName] = ins
+			}
+
+			switch item.Type {
+			case "Consumption":
+				if item.UnitOfMeasure != "1 Hour" {
+					continue
+				}
+				if isAzureSpotMeter(item) {
+					putAzureSpotPrice(ins, region, item.UnitPrice)
+					continue
+				}
+				// keep the latest effective price if there are multiple meters
+				if item.EffectiveStartDate >= startDates[item.ArmSkuName] {
+					startDates[item.ArmSkuName] = item.EffectiveStartDate
+					ins.OnDemandPricePerHour = item.UnitPrice
+				}
+			case "Reservation":
+				term, hours, ok := azureReservationHours(item.ReservationTerm)
+				if !ok {
+					continue
+				}
+				if ins.CommittedPricePerHour == nil {
+					ins.CommittedPricePerHour = map[string]float64{}
+				}
+				ins.CommittedPricePerHour[term] = item.UnitPrice / hours
+			}
+		}
+
+		for name, ins := range instanceTypes {
+			if ins.OnDemandPricePerHour == 0 {
+				delete(instanceTypes, name)
+			}
+		}
+		if len(instanceTypes) == 0 {
+			return
+		}
+
+		a.dataMutex.Lock()
+		a.priceData[region] = &apis.RegionalInstancePrice{InstanceTypePrices: instanceTypes}
+		a.dataMutex.Unlock()
+	})
+
+	a.refreshInstanceTypeMetadataAndAvailableRegion()
+	klog.Infof("All on-demand prices are refreshed for Azure")
+}
+
+// putAzureSpotPrice fills the spot price of every zone, spot prices of azure are the same across the zones of
+// one region, the region itself is used as the key if the vm size is not zonal in the region.
+func putAzureSpotPrice(ins *apis.InstanceTypePrice, region string, price float64) {
+	if ins.SpotPricePerHour == nil {
+		ins.SpotPricePerHour = map[string]float64{}
+	}
+	if len(ins.Zones) == 0 {
+		ins.SpotPricePerHour[region] = price
+		return
+	}
+	for _, zone := range ins.Zones {
+		ins.SpotPricePerHour[zone] = price
+	}
+}
+
+func (a *AzurePriceClient) refreshSpotPrices(ctx context.Context) {
+	a.dataMutex.RLock()
+	empty := len(a.priceData) == 0
+	a.dataMutex.RUnlock()
+	// There is no on-demand data to attach the spot prices to, do a full refresh
+	if empty {
+		a.RefreshOnDemandPrice(ctx)
+	}
+
+	a.dataMutex.RLock()
+	regions := make([]string, 0, len(a.priceData))
+	for region := range a.priceData {
+		regions = append(regions, region)
+	}
+	a.dataMutex.RUnlock()
+
+	regions = a.allowedRegions(regions)
+	workqueue.ParallelizeUntil(ctx, a.concurrency(10), len(regions), func(i int) {
+		region := regions[i]
+		klog.Infof("Start to handle region %s", region)
+
+		regionStartTime := time.Now()
+		items, err := a.listRetailPrices(ctx, region, " and priceType eq 'Consumption' and contains(meterName, 'Spot')")
+		a.observeRefresh(region, apis.SourceSpot, regionStartTime, err)
+		if err != nil {
+			return
+		}
+
+		a.dataMutex.Lock()
+		defer a.dataMutex.Unlock()
+		for _, item := range items {
+			if !isAzureSpotMeter(item) || item.UnitOfMeasure != "1 Hour" {
+				continue
+			}
+			ins, ok := a.priceData[region].InstanceTypePrices[item.ArmSkuName]
+			if !ok {
+				continue
+			}
+			putAzureSpotPrice(ins, region, item.UnitPrice)
+		}
+	})
+
+	klog.Infof("All spot prices are refreshed for Azure")
+}
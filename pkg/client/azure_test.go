@@ -0,0 +1,129 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+)
+
+// routeAzure maps the calls of the login, the resource skus and the retail prices to the recorded pages, the
+// retail prices of eastus are split into two pages.
+func routeAzure(r *http.Request) string {
+	query := r.URL.Query()
+	switch r.URL.Path {
+	case "/tenant/oauth2/v2.0/token":
+		return "token.json"
+	case "/subscriptions/sub/providers/Microsoft.Compute/skus":
+		if r.Header.Get("Authorization") != "Bearer token" {
+			return ""
+		}
+		if query.Get("$skiptoken") == "2" {
+			return "skus-2.json"
+		}
+		return "skus-1.json"
+	case "/api/retail/prices":
+		switch {
+		case query.Get("$skip") == "4":
+			return "prices-2.json"
+		case strings.Contains(query.Get("$filter"), "contains(meterName, 'Spot')"):
+			return "spot-prices.json"
+		case strings.Contains(query.Get("$filter"), "armRegionName eq 'eastus'"):
+			return "prices-1.json"
+		}
+	}
+	return ""
+}
+
+func newAzureTestClient(t *testing.T) *AzurePriceClient {
+	t.Helper()
+
+	server := newRecordedServer(t, "azure", routeAzure)
+	c, err := NewAzurePriceClient(context.Background(), AzureConfig{
+		TenantID:             "tenant",
+		ClientID:             "client",
+		ClientSecret:         "secret",
+		SubscriptionID:       "sub",
+		RetailPricesEndpoint: server.URL + "/api/retail/prices",
+		ManagementEndpoint:   server.URL,
+		LoginEndpoint:        server.URL,
+	}, false)
+	if err != nil {
+		t.Fatalf("Failed to create the azure client: %v", err)
+	}
+	dropBuiltinData(&c.priceStore)
+	return c
+}
+
+func TestAzureRefreshOnDemandPrice(t *testing.T) {
+	c := newAzureTestClient(t)
+	c.RefreshOnDemandPrice(context.Background())
+
+	if regions := c.ListRegionsInstancesPrice(); len(regions) != 1 || regions["eastus"] == nil {
+		t.Fatalf("Expected the prices of eastus only, got %v", regions)
+	}
+
+	d2 := c.GetInstancePrice("eastus", "Standard_D2s_v5")
+	if d2 == nil {
+		t.Fatalf("Expected the price of Standard_D2s_v5")
+	}
+	if d2.OnDemandPricePerHour != 0.096 {
+		t.Errorf("Expected the linux on-demand price 0.096, got %v", d2.OnDemandPricePerHour)
+	}
+	if d2.VCPU != 2 || d2.Memory != 8 || d2.Arch != "amd64" {
+		t.Errorf("Unexpected metadata %+v", d2.InstanceTypeMetadata)
+	}
+	for _, zone := range []string{"eastus-1", "eastus-2", "eastus-3"} {
+		if d2.SpotPricePerHour[zone] != 0.0192 {
+			t.Errorf("Expected the spot price 0.0192 in zone %s, got %v", zone, d2.SpotPricePerHour)
+		}
+	}
+	if price := d2.CommittedPricePerHour["1yr"]; price < 0.0575 || price > 0.0577 {
+		t.Errorf("Expected the 1yr reserved price 504.576/8760, got %v", d2.CommittedPricePerHour)
+	}
+
+	// Standard_B1s is on the second pages of both the skus and the prices
+	b1 := c.GetInstancePrice("eastus", "Standard_B1s")
+	if b1 == nil || b1.OnDemandPricePerHour != 0.0104 {
+		t.Errorf("Expected the on-demand price 0.0104 of Standard_B1s, got %+v", b1)
+	}
+	// Standard_E2s_v5 is priced but not available to the subscription
+	if e2 := c.GetInstancePrice("eastus", "Standard_E2s_v5"); e2 != nil {
+		t.Errorf("Expected no price of Standard_E2s_v5, got %+v", e2)
+	}
+}
+
+func TestAzureRefreshSpotPricesWithoutData(t *testing.T) {
+	c := newAzureTestClient(t)
+	c.requireRefresh(apis.SourceSpot)
+	c.refreshSpotPrices(context.Background())
+
+	d2 := c.GetInstancePrice("eastus", "Standard_D2s_v5")
+	if d2 == nil || d2.OnDemandPricePerHour != 0.096 {
+		t.Fatalf("Expected the on-demand prices refreshed first, got %+v", d2)
+	}
+	if d2.SpotPricePerHour["eastus-1"] != 0.0211 {
+		t.Errorf("Expected the refreshed spot price 0.0211, got %v", d2.SpotPricePerHour)
+	}
+	// Standard_B1s is not zonal, so the region is the key
+	b1 := c.GetInstancePrice("eastus", "Standard_B1s")
+	if b1 == nil || b1.SpotPricePerHour["eastus"] != 0.0021 {
+		t.Errorf("Expected the spot price 0.0021 of Standard_B1s in eastus, got %+v", b1)
+	}
+
+	status := c.RefreshStatus()
+	if !status.Ready {
+		t.Errorf("Expected the spot source refreshed, got %+v", status)
+	}
+	var spotRefreshed bool
+	for _, r := range status.Refreshes {
+		if r.Region == "eastus" && r.Source == apis.SourceSpot && r.LastSuccessTime != nil {
+			spotRefreshed = true
+		}
+	}
+	if !spotRefreshed {
+		t.Errorf("Expected the spot refresh of eastus recorded, got %+v", status.Refreshes)
+	}
+}
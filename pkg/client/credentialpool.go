@@ -0,0 +1,179 @@
+package client
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/klog"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+)
+
+const (
+	// The credentials failed with the auth or quota errors are quarantined longer than the throttled ones
+	credentialFailureBaseBackoff  = time.Second * 30
+	credentialFailureMaxBackoff   = time.Minute * 30
+	credentialThrottleBaseBackoff = time.Second * 5
+	credentialThrottleMaxBackoff  = time.Minute * 5
+)
+
+type credentialErrorKind int
+
+const (
+	credentialErrorNone credentialErrorKind = iota
+	// credentialErrorOther is not caused by the credential, like an invalid parameter or a network error
+	credentialErrorOther
+	credentialErrorThrottled
+	// credentialErrorFailed means the credential is invalid, revoked or over quota
+	credentialErrorFailed
+)
+
+type pooledCredential[T any] struct {
+	id         string
+	credential T
+	limiter    *rate.Limiter
+
+	mutex               sync.Mutex
+	requests            int64
+	errors              int64
+	throttles           int64
+	consecutiveFailures int
+	quarantinedUntil    time.Time
+	lastError           string
+	lastErrorTime       time.Time
+}
+
+// credentialPool spreads the calls across the credentials by the per-credential rate limits, and quarantines
+// the failing credentials with backoff until they succeed again.
+type credentialPool[T any] struct {
+	credentials []*pooledCredential[T]
+}
+
+func newCredentialPool[T any](ids []string, credentials []T, qps float64) *credentialPool[T] {
+	burst := max(int(qps), 1)
+	pool := &credentialPool[T]{}
+	for i := range credentials {
+		pool.credentials = append(pool.credentials, &pooledCredential[T]{
+			id:         ids[i],
+			credential: credentials[i],
+			limiter:    rate.NewLimiter(rate.Limit(qps), burst),
+		})
+	}
+	return pool
+}
+
+// get picks a credential not quarantined, the ones with rate limit tokens available are preferred. When all the
+// credentials are quarantined, the one released first is probed instead of failing the call.
+func (p *credentialPool[T]) get(ctx context.Context) (*pooledCredential[T], error) {
+	now := time.Now()
+	var (
+		candidates []*pooledCredential[T]
+		probe      *pooledCredential[T]
+		probeUntil time.Time
+	)
+	for _, c := range p.credentials {
+		c.mutex.Lock()
+		until := c.quarantinedUntil
+		c.mutex.Unlock()
+
+		if !until.After(now) {
+			candidates = append(candidates, c)
+		} else if probe == nil || until.Before(probeUntil) {
+			probe, probeUntil = c, until
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = []*pooledCredential[T]{probe}
+	}
+
+	start := rand.Intn(len(candidates))
+	for i := range candidates {
+		if c := candidates[(start+i)%len(candidates)]; c.limiter.Allow() {
+			return c, nil
+		}
+	}
+	c := candidates[start]
+	if err := c.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// report records the result of a call made with the credential.
+func (p *credentialPool[T]) report(c *pooledCredential[T], kind credentialErrorKind, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.requests++
+	if kind == credentialErrorNone {
+		c.consecutiveFailures = 0
+		c.quarantinedUntil = time.Time{}
+		return
+	}
+
+	c.errors++
+	c.lastErrorTime = time.Now()
+	if err != nil {
+		c.lastError = err.Error()
+	}
+
+	var baseBackoff, maxBackoff time.Duration
+	switch kind {
+	case credentialErrorThrottled:
+		c.throttles++
+		baseBackoff, maxBackoff = credentialThrottleBaseBackoff, credentialThrottleMaxBackoff
+	case credentialErrorFailed:
+		baseBackoff, maxBackoff = credentialFailureBaseBackoff, credentialFailureMaxBackoff
+	default:
+		return
+	}
+
+	c.consecutiveFailures++
+	backoff := baseBackoff
+	for i := 1; i < c.consecutiveFailures && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, maxBackoff)
+	c.quarantinedUntil = c.lastErrorTime.Add(backoff)
+	klog.Warningf("Quarantine credential %s for %v after %d consecutive failures:%v", c.id, backoff,
+		c.consecutiveFailures, err)
+}
+
+func (p *credentialPool[T]) health() []apis.CredentialHealth {
+	now := time.Now()
+	ret := make([]apis.CredentialHealth, 0, len(p.credentials))
+	for _, c := range p.credentials {
+		c.mutex.Lock()
+		h := apis.CredentialHealth{
+			ID:                  c.id,
+			Healthy:             !c.quarantinedUntil.After(now),
+			ConsecutiveFailures: c.consecutiveFailures,
+			Requests:            c.requests,
+			Errors:              c.errors,
+			Throttles:           c.throttles,
+			LastError:           c.lastError,
+		}
+		if !h.Healthy {
+			until := c.quarantinedUntil
+			h.QuarantinedUntil = &until
+		}
+		if !c.lastErrorTime.IsZero() {
+			lastErrorTime := c.lastErrorTime
+			h.LastErrorTime = &lastErrorTime
+		}
+		c.mutex.Unlock()
+		ret = append(ret, h)
+	}
+	return ret
+}
+
+// maskAccessKey keeps the head and the tail of the access key to tell the keys apart.
+func maskAccessKey(ak string) string {
+	if len(ak) <= 8 {
+		return "****"
+	}
+	return ak[:4] + "****" + ak[len(ak)-4:]
+}
@@ -0,0 +1,490 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2/google"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+)
+
+const (
+	gcpComputeEndpoint = "https://compute.googleapis.com/compute/v1"
+	gcpBillingEndpoint = "https://cloudbilling.googleapis.com/v1"
+	// gceBillingServiceID is the service id of Compute Engine in the cloud billing catalog
+	gceBillingServiceID   = "6F81-5844-456A"
+	gcpCloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+	// gcpSpotCatalogMaxAge is how long the spot refreshes reuse the billing catalog downloaded before, the spot
+	// prices of gce change at most once a month, so the whole catalog is not downloaded on every spot tick
+	gcpSpotCatalogMaxAge = time.Hour * 24
+)
+
+type GCPPriceClient struct {
+	projectID  string
+	httpClient *http.Client
+
+	// machineTypesMutex guards the machine types and the unit prices cached by the refreshes
+	machineTypesMutex sync.Mutex
+	// region -> machineTypeName -> machineType
+	machineTypes map[string]map[string]*gceMachineType
+	// unitPrices are parsed from the billing catalog downloaded at unitPricesTime
+	unitPrices     map[gceUnitPriceKey]float64
+	unitPricesTime time.Time
+
+	priceStore
+}
+
+var _ Provider = &GCPPriceClient{}
+
+func NewGCPPriceClient(ctx context.Context, projectID string, initialSpotUpdate bool) (*GCPPriceClient, error) {
+	httpClient, err := google.DefaultClient(ctx, gcpCloudPlatformScope)
+	if err != nil {
+		klog.Errorf("Failed to create gcp http client: %v", err)
+		return nil, err
+	}
+
+	client := &GCPPriceClient{
+		projectID:    projectID,
+		httpClient:   httpClient,
+		machineTypes: map[string]map[string]*gceMachineType{},
+		priceStore:   newPriceStore(apis.GCPProviderName),
+	}
+	if err := client.loadBuiltinData("gcp_price.json"); err != nil {
+		return nil, err
+	}
+
+	client.setRefreshers(map[string]func(ctx context.Context){
+		apis.SourceOnDemand: client.RefreshOnDemandPrice,
+		apis.SourceSpot:     client.refreshSpotPrices,
+		apis.SourceMetadata: client.refreshMetadata,
+	})
+
+	client.setInitialSources(apis.SourceSpot)
+	if initialSpotUpdate {
+		client.RefreshInitialSources(ctx)
+	}
+
+	client.refreshInstanceTypeMetadataAndAvailableRegion()
+	return client, nil
+}
+
+func (g *GCPPriceClient) Run(ctx context.Context) {
+	odTicker := time.NewTicker(g.onDemandInterval())
+	defer odTicker.Stop()
+
+	spotTicker := time.NewTicker(g.spotInterval())
+	defer spotTicker.Stop()
+
+	metaTicker := time.NewTicker(g.metadataInterval())
+	defer metaTicker.Stop()
+
+	for {
+		select {
+		case <-odTicker.C:
+			g.RefreshOnDemandPrice(ctx)
+		case <-spotTicker.C:
+			g.refreshSpotPrices(ctx)
+		case <-metaTicker.C:
+			g.refreshMetadata(ctx)
+		case <-g.settingsUpdated:
+			odTicker.Reset(g.onDemandInterval())
+			spotTicker.Reset(g.spotInterval())
+			metaTicker.Reset(g.metadataInterval())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+type gceMachineTypeList struct {
+	Items map[string]struct {
+		MachineTypes []gceMachineType `json:"machineTypes"`
+	} `json:"items"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+type gceMachineType struct {
+	Name         string `json:"name"`
+	Zone         string `json:"zone"`
+	GuestCpus    int    `json:"guestCpus"`
+	MemoryMb     int    `json:"memoryMb"`
+	Accelerators []struct {
+		GuestAcceleratorType  string `json:"guestAcceleratorType"`
+		GuestAcceleratorCount int    `json:"guestAcceleratorCount"`
+	} `json:"accelerators"`
+	Deprecated *struct {
+		State string `json:"state"`
+	} `json:"deprecated"`
+
+	zones sets.Set[string]
+}
+
+type gcpSKUList struct {
+	Skus          []gcpSKU `json:"skus"`
+	NextPageToken string   `json:"nextPageToken"`
+}
+
+type gcpSKU struct {
+	Description string `json:"description"`
+	Category    struct {
+		ResourceFamily string `json:"resourceFamily"`
+		ResourceGroup  string `json:"resourceGroup"`
+		UsageType      string `json:"usageType"`
+	} `json:"category"`
+	ServiceRegions []string `json:"serviceRegions"`
+	PricingInfo    []struct {
+		PricingExpression struct {
+			UsageUnit   string `json:"usageUnit"`
+			TieredRates []struct {
+				UnitPrice struct {
+					CurrencyCode string `json:"currencyCode"`
+					Units        string `json:"units"`
+					Nanos        int64  `json:"nanos"`
+				} `json:"unitPrice"`
+			} `json:"tieredRates"`
+		} `json:"pricingExpression"`
+	} `json:"pricingInfo"`
+}
+
+// listMachineTypes returns all the predefined machine types grouped by region.
+func (g *GCPPriceClient) listMachineTypes(ctx context.Context) (map[string]map[string]*gceMachineType, error) {
+	ret := map[string]map[string]*gceMachineType{}
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("maxResults", "500")
+		if token != "" {
+			query.Set("pageToken", token)
+		}
+		reqUrl := fmt.Sprintf("%s/projects/%s/aggregated/machineTypes?%s", gcpComputeEndpoint, g.projectID, query.Encode())
+
+		var data gceMachineTypeList
+		err := getJSON(ctx, g.httpClient, reqUrl, &data)
+		g.observeAPICall("ListMachineTypes", err)
+		if err != nil {
+			klog.Errorf("Failed to list gce machine types: %v", err)
+			return nil, err
+		}
+
+		for _, scoped := range data.Items {
+			for i := range scoped.MachineTypes {
+				mt := scoped.MachineTypes[i]
+				if mt.Deprecated != nil && mt.Deprecated.State != "" {
+					continue
+				}
+				zone := mt.Zone[strings.LastIndex(mt.Zone, "/")+1:]
+				region := zone[:strings.LastIndex(zone, "-")]
+				if _, ok := ret[region]; !ok {
+					ret[region] = map[string]*gceMachineType{}
+				}
+				if _, ok := ret[region][mt.Name]; !ok {
+					mt.zones = sets.Set[string]{}
+					ret[region][mt.Name] = &mt
+				}
+				ret[region][mt.Name].zones.Insert(zone)
+			}
+		}
+
+		token = data.NextPageToken
+		if token == "" {
+			break
+		}
+	}
+
+	return ret, nil
+}
+
+type gceUnitPriceKey struct {
+	region    string
+	family    string
+	usageType string
+	resource  string
+}
+
+var (
+	gceComponentSKUPattern = regexp.MustCompile(`^(?:Commitment v1: )?(?:Spot Preemptible |Preemptible )?(.*?) ?(?:Instance )?(Core|Ram|Cpu) (?:running )?in `)
+	gceGPUSKUPattern       = regexp.MustCompile(`^(?:Spot Preemptible |Preemptible )?(.+?) GPU running in `)
+
+	// gceSKUFamilies maps the family name used in sku descriptions to the machine family
+	gceSKUFamilies = map[string]string{
+		"":                  "n1",
+		"N1 Predefined":     "n1",
+		"E2":                "e2",
+		"N2":                "n2",
+		"N2D AMD":           "n2d",
+		"N4":                "n4",
+		"Compute optimized": "c2",
+		"C2D AMD":           "c2d",
+		"C3":                "c3",
+		"C3D":               "c3d",
+		"C4":                "c4",
+		"T2D AMD":           "t2d",
+		"T2A Arm":           "t2a",
+		"Memory-optimized":  "m1",
+		"M3":                "m3",
+		"H3":                "h3",
+		"A2":                "a2",
+		"A3":                "a3",
+		"G2":                "g2",
+	}
+
+	// gceAcceleratorSKUNames maps the accelerator type to the gpu name used in sku descriptions
+	gceAcceleratorSKUNames = map[string]string{
+		"nvidia-tesla-a100": "Nvidia Tesla A100",
+		"nvidia-a100-80gb":  "Nvidia Tesla A100 80GB",
+		"nvidia-h100-80gb":  "Nvidia H100 80GB",
+		"nvidia-l4":         "Nvidia L4",
+	}
+
+	// shared-core machine types are billed by a fraction of the vCPU
+	gceSharedCoreFractions = map[string]float64{
+		"e2-micro":  0.25,
+		"e2-small":  0.5,
+		"e2-medium": 1,
+	}
+
+	gceUsageTypes = map[string]string{
+		"OnDemand":    "ondemand",
+		"Preemptible": "spot",
+		"Commit1Yr":   "1yr",
+		"Commit3Yr":   "3yr",
+	}
+)
+
+// listUnitPrices returns the per vCPU, per GiB memory and per GPU hourly prices of every machine family.
+func (g *GCPPriceClient) listUnitPrices(ctx context.Context) (map[gceUnitPriceKey]float64, error) {
+	ret := map[gceUnitPriceKey]float64{}
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("currencyCode", "USD")
+		query.Set("pageSize", "5000")
+		if token != "" {
+			query.Set("pageToken", token)
+		}
+		reqUrl := fmt.Sprintf("%s/services/%s/skus?%s", gcpBillingEndpoint, gceBillingServiceID, query.Encode())
+
+		var data gcpSKUList
+		err := getJSON(ctx, g.httpClient, reqUrl, &data)
+		g.observeAPICall("ListSKUs", err)
+		if err != nil {
+			klog.Errorf("Failed to list gce skus: %v", err)
+			return nil, err
+		}
+
+		for _, sku := range data.Skus {
+			key, ok := parseGCESKU(sku)
+			if !ok || len(sku.PricingInfo) == 0 {
+				continue
+			}
+			rates := sku.PricingInfo[0].PricingExpression.TieredRates
+			if len(rates) == 0 {
+				continue
+			}
+			// The last tier is the regular rate, the leading tiers may be free usage
+			unitPrice := rates[len(rates)-1].UnitPrice
+			units, err := strconv.ParseFloat(unitPrice.Units, 64)
+			if err != nil {
+				units = 0
+			}
+			price := units + float64(unitPrice.Nanos)/1e9
+			if price == 0 {
+				continue
+			}
+
+			for _, region := range sku.ServiceRegions {
+				key.region = region
+				ret[key] = price
+			}
+		}
+
+		token = data.NextPageToken
+		if token == "" {
+			break
+		}
+	}
+
+	return ret, nil
+}
+
+func parseGCESKU(sku gcpSKU) (gceUnitPriceKey, bool) {
+	usageType, ok := gceUsageTypes[sku.Category.UsageType]
+	if !ok || sku.Category.ResourceFamily != "Compute" {
+		return gceUnitPriceKey{}, false
+	}
+
+	switch sku.Category.ResourceGroup {
+	case "GPU":
+		match := gceGPUSKUPattern.FindStringSubmatch(sku.Description)
+		if match == nil {
+			return gceUnitPriceKey{}, false
+		}
+		return gceUnitPriceKey{family: match[1], usageType: usageType, resource: "gpu"}, true
+	default:
+		match := gceComponentSKUPattern.FindStringSubmatch(sku.Description)
+		if match == nil {
+			return gceUnitPriceKey{}, false
+		}
+		family, ok := gceSKUFamilies[match[1]]
+		if !ok {
+			return gceUnitPriceKey{}, false
+		}
+		resource := "core"
+		if match[2] == "Ram" {
+			resource = "ram"
+		}
+		return gceUnitPriceKey{family: family, usageType: usageType, resource: resource}, true
+	}
+}
+
+func gceMachineTypePrice(unitPrices map[gceUnitPriceKey]float64, region, usageType string, mt *gceMachineType) (float64, bool) {
+	family := strings.SplitN(mt.Name, "-", 2)[0]
+	corePrice, ok := unitPrices[gceUnitPriceKey{region: region, family: family, usageType: usageType, resource: "core"}]
+	if !ok {
+		return 0, false
+	}
+	ramPrice, ok := unitPrices[gceUnitPriceKey{region: region, family: family, usageType: usageType, resource: "ram"}]
+	if !ok {
+		return 0, false
+	}
+
+	vcpu := float64(mt.GuestCpus)
+	if fraction, ok := gceSharedCoreFractions[mt.Name]; ok {
+		vcpu = fraction
+	}
+	price := vcpu*corePrice + float64(mt.MemoryMb)/1024*ramPrice
+
+	for _, acc := range mt.Accelerators {
+		gpuPrice, ok := unitPrices[gceUnitPriceKey{
+			region:    region,
+			family:    gceAcceleratorSKUNames[acc.GuestAcceleratorType],
+			usageType: usageType,
+			resource:  "gpu",
+		}]
+		if !ok {
+			return 0, false
+		}
+		price += float64(acc.GuestAcceleratorCount) * gpuPrice
+	}
+
+	return price, true
+}
+
+func extractGCEArch(machineType string) string {
+	switch strings.SplitN(machineType, "-", 2)[0] {
+	case "t2a", "c4a":
+		return "arm64"
+	default:
+		return "amd64"
+	}
+}
+
+// refreshPrices rebuilds the price data of all the regions from the billing catalog,
+// the machine types are listed again only when refreshMachineTypes is set or none is cached,
+// and the catalog is downloaded again only when the cached one is older than catalogMaxAge.
+// source names the refresh in the metrics, all the prices are refreshed together either way.
+func (g *GCPPriceClient) refreshPrices(ctx context.Context, source string, refreshMachineTypes bool,
+	catalogMaxAge time.Duration) {
+	g.machineTypesMutex.Lock()
+	defer g.machineTypesMutex.Unlock()
+
+	startTime := time.Now()
+	if refreshMachineTypes || len(g.machineTypes) == 0 {
+		machineTypes, err := g.listMachineTypes(ctx)
+		if err != nil {
+			g.observeRefreshAll(source, startTime, err)
+			return
+		}
+		g.machineTypes = machineTypes
+	}
+
+	if g.unitPrices == nil || time.Since(g.unitPricesTime) >= catalogMaxAge {
+		unitPrices, err := g.listUnitPrices(ctx)
+		if err != nil {
+			g.observeRefreshAll(source, startTime, err)
+			return
+		}
+		g.unitPrices, g.unitPricesTime = unitPrices, startTime
+	} else {
+		klog.Infof("Reuse the gce billing catalog downloaded at %s", g.unitPricesTime.Format(time.RFC3339))
+	}
+	unitPrices := g.unitPrices
+
+	for region, machineTypes := range g.machineTypes {
+		klog.Infof("Start to handle region %s for gce", region)
+
+		instanceTypes := map[string]*apis.InstanceTypePrice{}
+		for name, mt := range machineTypes {
+			onDemandPrice, ok := gceMachineTypePrice(unitPrices, region, "ondemand", mt)
+			if !ok {
+				continue
+			}
+
+			gpu := 0
+			for _, acc := range mt.Accelerators {
+				gpu += acc.GuestAcceleratorCount
+			}
+			ins := &apis.InstanceTypePrice{
+				InstanceTypeMetadata: apis.InstanceTypeMetadata{
+					Arch:   extractGCEArch(name),
+					VCPU:   float64(mt.GuestCpus),
+					Memory: float64(mt.MemoryMb) / 1024,
+					GPU:    float64(gpu),
+				},
+				Zones:                mt.zones.UnsortedList(),
+				OnDemandPricePerHour: onDemandPrice,
+			}
+
+			if spotPrice, ok := gceMachineTypePrice(unitPrices, region, "spot", mt); ok {
+				// spot prices are the same across the zones of one region
+				ins.SpotPricePerHour = map[string]float64{}
+				for _, zone := range ins.Zones {
+					ins.SpotPricePerHour[zone] = spotPrice
+				}
+			}
+
+			for _, term := range []string{"1yr", "3yr"} {
+				committedPrice, ok := gceMachineTypePrice(unitPrices, region, term, mt)
+				if !ok {
+					continue
+				}
+				if ins.CommittedPricePerHour == nil {
+					ins.CommittedPricePerHour = map[string]float64{}
+				}
+				ins.CommittedPricePerHour[term] = committedPrice
+			}
+
+			instanceTypes[name] = ins
+		}
+		if len(instanceTypes) == 0 {
+			continue
+		}
+
+		g.dataMutex.Lock()
+		g.priceData[region] = &apis.RegionalInstancePrice{InstanceTypePrices: instanceTypes}
+		g.dataMutex.Unlock()
+		g.observeRefresh(region, source, startTime, nil)
+	}
+
+	g.refreshInstanceTypeMetadataAndAvailableRegion()
+}
+
+func (g *GCPPriceClient) RefreshOnDemandPrice(ctx context.Context) {
+	g.refreshPrices(ctx, apis.SourceOnDemand, true, 0)
+	klog.Infof("All on-demand prices are refreshed for GCP")
+}
+
+func (g *GCPPriceClient) refreshSpotPrices(ctx context.Context) {
+	g.refreshPrices(ctx, apis.SourceSpot, false, gcpSpotCatalogMaxAge)
+	klog.Infof("All spot prices are refreshed for GCP")
+}
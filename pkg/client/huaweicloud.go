@@ -0,0 +1,487 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/auth/basic"
+	"github.com/huaweicloud/huaweicloud-sdk-go-v3/core/auth/global"
+	bss "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/bss/v2"
+	bssmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/bss/v2/model"
+	ecs "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/ecs/v2"
+	ecsmodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/ecs/v2/model"
+	ecsregion "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/ecs/v2/region"
+	iam "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/iam/v3"
+	iammodel "github.com/huaweicloud/huaweicloud-sdk-go-v3/services/iam/v3/model"
+	"github.com/samber/lo"
+	"github.com/shopspring/decimal"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+)
+
+const (
+	DefaultHuaweiCloudIAMEndpoint = "https://iam.myhuaweicloud.com"
+	DefaultHuaweiCloudBSSEndpoint = "https://bss.myhuaweicloud.com"
+
+	huaweiCloudECSServiceType = "hws.service.type.ec2"
+	huaweiCloudVMResourceType = "hws.resource.type.vm"
+	// 4 is the measure id of hour
+	huaweiCloudHourMeasureID = 4
+	// the max product count of one on-demand rating request
+	huaweiCloudRatingBatchSize = 100
+)
+
+type HuaweiCloudPriceClient struct {
+	akskPool []AKSKPair
+	// bssEndpoint is the endpoint of the billing center, international accounts should use
+	// https://bss-intl.myhuaweicloud.com
+	bssEndpoint string
+
+	// regionName -> projectID
+	regionProjects map[string]string
+	// regions are discovered by the first refresh
+	regions *lazyRegions
+
+	priceStore
+}
+
+var _ Provider = &HuaweiCloudPriceClient{}
+
+func NewHuaweiCloudPriceClient(ctx context.Context, akskPool []AKSKPair, bssEndpoint string,
+	initialUpdate bool) (*HuaweiCloudPriceClient, error) {
+	if bssEndpoint == "" {
+		bssEndpoint = DefaultHuaweiCloudBSSEndpoint
+	}
+	client := &HuaweiCloudPriceClient{
+		akskPool:       akskPool,
+		bssEndpoint:    bssEndpoint,
+		regionProjects: map[string]string{},
+		priceStore:     newPriceStore(apis.HuaweiCloudProviderName),
+	}
+	if err := client.loadBuiltinData("huaweicloud_price.json"); err != nil {
+		return nil, err
+	}
+
+	client.regions = newLazyRegions(client.listRegions)
+	// The followers never refresh, so they never discover the regions, the others fail fast on the invalid
+	// credentials
+	if initialUpdate {
+		if _, err := client.regions.get(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	client.setRefreshers(map[string]func(ctx context.Context){
+		apis.SourceOnDemand: client.RefreshOnDemandPrice,
+		apis.SourceMetadata: client.refreshMetadata,
+	})
+	client.setUnsupportedSources(map[string]string{
+		apis.SourceSpot: "the open api of huawei cloud does not expose the spot market price",
+	})
+
+	client.setInitialSources(apis.SourceOnDemand)
+	if initialUpdate {
+		client.RefreshInitialSources(ctx)
+		return client, nil
+	}
+
+	client.refreshInstanceTypeMetadataAndAvailableRegion()
+	return client, nil
+}
+
+// Run refreshes the pay-per-use prices periodically. HuaweiCloud does not expose the spot market price through
+// its open API, so there is no spot refresh and SpotPricePerHour stays empty.
+func (h *HuaweiCloudPriceClient) Run(ctx context.Context) {
+	odTicker := time.NewTicker(h.onDemandInterval())
+	defer odTicker.Stop()
+
+	for {
+		select {
+		case <-odTicker.C:
+			h.RefreshOnDemandPrice(ctx)
+		case <-h.settingsUpdated:
+			odTicker.Reset(h.onDemandInterval())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (h *HuaweiCloudPriceClient) pickAKSK() AKSKPair {
+	// Take one ak/sk from pool
+	return h.akskPool[rand.Intn(len(h.akskPool))]
+}
+
+func (h *HuaweiCloudPriceClient) createECSClient(region string) (*ecs.EcsClient, error) {
+	r, err := ecsregion.SafeValueOf(region)
+	if err != nil {
+		klog.Errorf("Failed to find ecs endpoint of region %s:%v", region, err)
+		return nil, err
+	}
+
+	aksk := h.pickAKSK()
+	credential, err := basic.NewCredentialsBuilder().
+		WithAk(aksk.AK).
+		WithSk(aksk.SK).
+		WithProjectId(h.regionProjects[region]).
+		SafeBuild()
+	if err != nil {
+		klog.Errorf("Failed to build huaweicloud credential:%v", err)
+		return nil, err
+	}
+
+	hcClient, err := ecs.EcsClientBuilder().WithRegion(r).WithCredential(credential).SafeBuild()
+	if err != nil {
+		klog.Errorf("Failed to create ecs client of region %s:%v", region, err)
+		return nil, err
+	}
+	return ecs.NewEcsClient(hcClient), nil
+}
+
+func (h *HuaweiCloudPriceClient) createGlobalCredential() (*global.Credentials, error) {
+	aksk := h.pickAKSK()
+	credential, err := global.NewCredentialsBuilder().
+		WithAk(aksk.AK).
+		WithSk(aksk.SK).
+		SafeBuild()
+	if err != nil {
+		klog.Errorf("Failed to build huaweicloud global credential:%v", err)
+		return nil, err
+	}
+	return credential, nil
+}
+
+func (h *HuaweiCloudPriceClient) createBSSClient() (*bss.BssClient, error) {
+	credential, err := h.createGlobalCredential()
+	if err != nil {
+		return nil, err
+	}
+
+	hcClient, err := bss.BssClientBuilder().WithEndpoint(h.bssEndpoint).WithCredential(credential).SafeBuild()
+	if err != nil {
+		klog.Errorf("Failed to create bss client:%v", err)
+		return nil, err
+	}
+	return bss.NewBssClient(hcClient), nil
+}
+
+// listRegions lists the projects the account can access, every region has a default project named by the region.
+// The projects are kept for the ecs clients, they are read after the regions are discovered only.
+func (h *HuaweiCloudPriceClient) listRegions(ctx context.Context) ([]string, error) {
+	// The iam sdk does not take a ctx, so the ctx is checked before the call only
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	credential, err := h.createGlobalCredential()
+	if err != nil {
+		return nil, err
+	}
+
+	hcClient, err := iam.IamClientBuilder().WithEndpoint(DefaultHuaweiCloudIAMEndpoint).WithCredential(credential).SafeBuild()
+	if err != nil {
+		klog.Errorf("Failed to create iam client:%v", err)
+		return nil, err
+	}
+
+	resp, err := iam.NewIamClient(hcClient).KeystoneListAuthProjects(&iammodel.KeystoneListAuthProjectsRequest{})
+	h.observeAPICall("KeystoneListAuthProjects", err)
+	if err != nil {
+		klog.Errorf("Failed to list projects:%v", err)
+		return nil, err
+	}
+
+	var regions []string
+	for _, project := range lo.FromPtr(resp.Projects) {
+		if !project.Enabled {
+			continue
+		}
+		// Skip the sub projects and the projects which are not a region, like MOS
+		if _, err := ecsregion.SafeValueOf(project.Name); err != nil {
+			continue
+		}
+		h.regionProjects[project.Name] = project.Id
+		regions = append(regions, project.Name)
+	}
+
+	return regions, nil
+}
+
+// parseHuaweiCloudAZStatus parses the az status list like az1(normal),az2(sellout) and returns the zones on sale.
+func parseHuaweiCloudAZStatus(azStatus string) []string {
+	var zones []string
+	for _, item := range strings.Split(azStatus, ",") {
+		item = strings.TrimSpace(item)
+		name, status, found := strings.Cut(item, "(")
+		if !found || name == "" {
+			continue
+		}
+		if isHuaweiCloudOnSale(strings.TrimSuffix(status, ")")) {
+			zones = append(zones, name)
+		}
+	}
+	return zones
+}
+
+func isHuaweiCloudOnSale(status string) bool {
+	return status == "normal" || status == "promotion"
+}
+
+// extractHuaweiCloudGPU returns the gpu count of the flavor, info:gpus is like [{"type":"nvidia-t4","count":1}]
+// and pci_passthrough:alias is like nvidia-t4:1.
+func extractHuaweiCloudGPU(spec *ecsmodel.FlavorExtraSpec) float64 {
+	var gpus []struct {
+		Count float64 `json:"count"`
+	}
+	var count float64
+	if err := json.Unmarshal([]byte(lo.FromPtr(spec.Infogpus)), &gpus); err == nil && len(gpus) > 0 {
+		for _, gpu := range gpus {
+			count += gpu.Count
+		}
+		return count
+	}
+
+	if lo.FromPtr(spec.PciPassthroughenableGpu) != "true" {
+		return 0
+	}
+	for _, alias := range strings.Split(lo.FromPtr(spec.PciPassthroughalias), ",") {
+		_, num, found := strings.Cut(alias, ":")
+		if !found {
+			continue
+		}
+		if n, err := strconv.ParseFloat(strings.TrimSpace(num), 64); err == nil {
+			count += n
+		}
+	}
+	return count
+}
+
+func extractHuaweiCloudArch(spec *ecsmodel.FlavorExtraSpec) string {
+	if lo.FromPtr(spec.EcsinstanceArchitecture) == "arm64" {
+		return "arm64"
+	}
+	return "amd64"
+}
+
+// listAvailabilityZones returns the available zones of the region
+func (h *HuaweiCloudPriceClient) listAvailabilityZones(client *ecs.EcsClient, region string) ([]string, error) {
+	resp, err := client.NovaListAvailabilityZones(&ecsmodel.NovaListAvailabilityZonesRequest{})
+	h.observeAPICall("NovaListAvailabilityZones", err)
+	if err != nil {
+		klog.Errorf("Failed to list availability zones in region %s:%v", region, err)
+		return nil, err
+	}
+
+	var zones []string
+	for _, zone := range lo.FromPtr(resp.AvailabilityZoneInfo) {
+		if zone.ZoneState == nil || !zone.ZoneState.Available {
+			continue
+		}
+		zones = append(zones, zone.ZoneName)
+	}
+	return zones, nil
+}
+
+// listFlavors returns the flavors on sale in the region with the metadata and the zones selling them.
+func (h *HuaweiCloudPriceClient) listFlavors(region string) (map[string]*apis.InstanceTypePrice, error) {
+	client, err := h.createECSClient(region)
+	if err != nil {
+		return nil, err
+	}
+
+	regionZones, err := h.listAvailabilityZones(client, region)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.ListFlavors(&ecsmodel.ListFlavorsRequest{})
+	h.observeAPICall("ListFlavors", err)
+	if err != nil {
+		klog.Errorf("Failed to list flavors in region %s:%v", region, err)
+		return nil, err
+	}
+
+	instanceTypes := map[string]*apis.InstanceTypePrice{}
+	for _, flavor := range lo.FromPtr(resp.Flavors) {
+		if flavor.OSFLVDISABLEDdisabled || flavor.OsExtraSpecs == nil {
+			continue
+		}
+		spec := flavor.OsExtraSpecs
+
+		// cond:operation:az overrides cond:operation:status for the listed zones
+		zones := regionZones
+		if lo.FromPtr(spec.Condoperationaz) != "" {
+			zones = parseHuaweiCloudAZStatus(*spec.Condoperationaz)
+		} else if !isHuaweiCloudOnSale(lo.FromPtr(spec.Condoperationstatus)) {
+			zones = nil
+		}
+		if len(zones) == 0 {
+			continue
+		}
+
+		vcpu, err := strconv.ParseFloat(flavor.Vcpus, 64)
+		if err != nil {
+			klog.Warningf("Failed to parse vcpus %s of flavor %s:%v", flavor.Vcpus, flavor.Name, err)
+			continue
+		}
+
+		family, _, _ := strings.Cut(flavor.Name, ".")
+		instanceTypes[flavor.Name] = &apis.InstanceTypePrice{
+			InstanceTypeMetadata: apis.InstanceTypeMetadata{
+				Arch:           extractHuaweiCloudArch(spec),
+				VCPU:           vcpu,
+				Memory:         float64(flavor.Ram) / 1024,
+				GPU:            extractHuaweiCloudGPU(spec),
+				InstanceFamily: family,
+			},
+			Zones: sets.New(zones...).UnsortedList(),
+		}
+	}
+
+	return instanceTypes, nil
+}
+
+func newHuaweiCloudProductInfo(region, instanceType string) bssmodel.DemandProductInfo {
+	return bssmodel.DemandProductInfo{
+		Id:               instanceType,
+		CloudServiceType: huaweiCloudECSServiceType,
+		ResourceType:     huaweiCloudVMResourceType,
+		ResourceSpec:     instanceType + ".linux",
+		Region:           region,
+		UsageFactor:      "Duration",
+		UsageValue:       lo.ToPtr(decimal.NewFromInt(1)),
+		UsageMeasureId:   huaweiCloudHourMeasureID,
+		SubscriptionNum:  1,
+	}
+}
+
+func (h *HuaweiCloudPriceClient) rateOnDemand(client *bss.BssClient, region string,
+	instanceTypes []string) (map[string]float64, error) {
+	productInfos := lo.Map(instanceTypes, func(instanceType string, _ int) bssmodel.DemandProductInfo {
+		return newHuaweiCloudProductInfo(region, instanceType)
+	})
+
+	resp, err := client.ListOnDemandResourceRatings(&bssmodel.ListOnDemandResourceRatingsRequest{
+		Body: &bssmodel.RateOnDemandReq{
+			ProjectId:    h.regionProjects[region],
+			ProductInfos: productInfos,
+		},
+	})
+	h.observeAPICall("ListOnDemandResourceRatings", err)
+	if err != nil {
+		return nil, err
+	}
+
+	prices := map[string]float64{}
+	for _, result := range lo.FromPtr(resp.ProductRatingResults) {
+		// the official website amount is the list price without the discount of the account
+		price := result.OfficialWebsiteAmount
+		if price == nil {
+			price = result.Amount
+		}
+		if result.Id == nil || price == nil || price.IsZero() {
+			continue
+		}
+		prices[*result.Id] = price.InexactFloat64()
+	}
+	return prices, nil
+}
+
+// listOnDemandPrices returns the hourly price of the instance types, the rating request fails as a whole
+// when any of the specs is not priced, so the failed batch is retried one by one. The bss sdk does not take a
+// ctx, so the ctx is checked between the requests.
+func (h *HuaweiCloudPriceClient) listOnDemandPrices(ctx context.Context, region string, instanceTypes []string) (map[string]float64, error) {
+	client, err := h.createBSSClient()
+	if err != nil {
+		return nil, err
+	}
+
+	prices := map[string]float64{}
+	for _, batch := range lo.Chunk(instanceTypes, huaweiCloudRatingBatchSize) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		batchPrices, err := h.rateOnDemand(client, region, batch)
+		if err == nil {
+			for k, v := range batchPrices {
+				prices[k] = v
+			}
+			continue
+		}
+
+		klog.Warningf("Failed to rate flavors in batch in region %s, retry one by one:%v", region, err)
+		for _, instanceType := range batch {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			itemPrices, err := h.rateOnDemand(client, region, []string{instanceType})
+			if err != nil {
+				klog.Errorf("Failed to rate flavor %s in region %s:%v", instanceType, region, err)
+				continue
+			}
+			for k, v := range itemPrices {
+				prices[k] = v
+			}
+		}
+	}
+
+	if len(prices) == 0 && len(instanceTypes) != 0 {
+		return nil, fmt.Errorf("no on-demand price found in region %s", region)
+	}
+	return prices, nil
+}
+
+func (h *HuaweiCloudPriceClient) RefreshOnDemandPrice(ctx context.Context) {
+	regions, ok := h.allowedRegionsOf(ctx, h.regions, apis.SourceOnDemand)
+	if !ok {
+		return
+	}
+	workqueue.ParallelizeUntil(ctx, h.concurrency(10), len(regions), func(i int) {
+		region := regions[i]
+		klog.Infof("Start to handle region %s for on-demand", region)
+
+		startTime := time.Now()
+		err := h.refreshRegionOnDemandPrice(ctx, region)
+		h.observeRefresh(region, apis.SourceOnDemand, startTime, err)
+	})
+
+	h.refreshInstanceTypeMetadataAndAvailableRegion()
+	klog.Infof("All on-demand prices are refreshed for HuaweiCloud")
+}
+
+func (h *HuaweiCloudPriceClient) refreshRegionOnDemandPrice(ctx context.Context, region string) error {
+	instanceTypes, err := h.listFlavors(region)
+	if err != nil {
+		return err
+	}
+	if len(instanceTypes) == 0 {
+		return nil
+	}
+
+	prices, err := h.listOnDemandPrices(ctx, region, lo.Keys(instanceTypes))
+	if err != nil {
+		klog.Errorf("Failed to list on-demand prices in region %s:%v", region, err)
+		return err
+	}
+
+	for instanceType, ins := range instanceTypes {
+		price, ok := prices[instanceType]
+		if !ok {
+			delete(instanceTypes, instanceType)
+			continue
+		}
+		ins.OnDemandPricePerHour = price
+	}
+
+	h.dataMutex.Lock()
+	defer h.dataMutex.Unlock()
+	h.priceData[region] = &apis.RegionalInstancePrice{InstanceTypePrices: instanceTypes}
+	return nil
+}
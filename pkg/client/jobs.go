@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/scheduler"
+)
+
+// defaultJitterFraction delays the runs of an interval by up to a tenth of the interval, so the replicas started
+// together spread their refreshes
+const defaultJitterFraction = 10
+
+// JobRegistrar is implemented by the providers whose sources are refreshed by the jobs of the scheduler instead of
+// their Run loop.
+type JobRegistrar interface {
+	RegisterJobs(s *scheduler.Scheduler) error
+}
+
+// newJob creates the job refreshing the source every interval, the schedule is read from the settings before every
+// run, so the reloaded settings apply from the next run.
+func (s *priceStore) newJob(source string, interval func() time.Duration,
+	run func(ctx context.Context)) scheduler.Job {
+	return scheduler.Job{
+		Provider: s.provider,
+		Source:   source,
+		Spec: func() scheduler.Spec {
+			settings := s.currentSettings().Schedules[source]
+			spec := scheduler.Spec{
+				Schedule:   scheduler.Every(interval()),
+				Jitter:     interval() / defaultJitterFraction,
+				MaxRuntime: settings.MaxRuntime,
+			}
+			// The cron expressions pick the times explicitly, they are not delayed unless asked
+			if settings.Cron != nil {
+				spec.Schedule = settings.Cron
+				spec.Jitter = 0
+			}
+			if settings.Jitter != nil {
+				spec.Jitter = *settings.Jitter
+			}
+			return spec
+		},
+		Run: run,
+	}
+}
+
+func registerJobs(s *scheduler.Scheduler, jobs ...scheduler.Job) error {
+	for _, j := range jobs {
+		if err := s.Register(j); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,413 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/core"
+	"github.com/oracle/oci-go-sdk/v65/identity"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+)
+
+const (
+	// DefaultOCIPriceListEndpoint is the public price list of OCI, it does not need any credential
+	DefaultOCIPriceListEndpoint = "https://apexapps.oracle.com/pls/apex/cetools/api/v1/products/?currencyCode=USD"
+
+	ociPayAsYouGoModel = "PAY_AS_YOU_GO"
+	ociCPUUnit         = "ocpu"
+)
+
+// ociPriceParts are the display names of the price list items a shape series is billed by.
+type ociPriceParts struct {
+	OCPU   string
+	Memory string
+	GPU    string
+}
+
+// ociShapePriceParts maps the shape series to the price list items, the key is the shape name without the size
+// suffix, the key with the VM/BM prefix takes precedence.
+var ociShapePriceParts = map[string]ociPriceParts{
+	"Standard.E3":  {OCPU: "Compute - Standard - E3 - OCPU", Memory: "Compute - Standard - E3 - Memory"},
+	"Standard.E4":  {OCPU: "Compute - Standard - E4 - OCPU", Memory: "Compute - Standard - E4 - Memory"},
+	"Standard.E5":  {OCPU: "Compute - Standard - E5 - OCPU", Memory: "Compute - Standard - E5 - Memory"},
+	"DenseIO.E4":   {OCPU: "Compute - Dense I/O - E4 - OCPU", Memory: "Compute - Dense I/O - E4 - Memory"},
+	"DenseIO.E5":   {OCPU: "Compute - Dense I/O - E5 - OCPU", Memory: "Compute - Dense I/O - E5 - Memory"},
+	"Standard.A1":  {OCPU: "Compute - Ampere A1 - OCPU", Memory: "Compute - Ampere A1 - Memory"},
+	"Standard.A2":  {OCPU: "Compute - Ampere A2 - OCPU", Memory: "Compute - Ampere A2 - Memory"},
+	"Standard3":    {OCPU: "Compute - Standard - X9 - OCPU", Memory: "Compute - Standard - X9 - Memory"},
+	"Optimized3":   {OCPU: "Compute - Optimized - X9 - OCPU", Memory: "Compute - Optimized - X9 - Memory"},
+	"VM.Standard2": {OCPU: "Compute - Virtual Machine Standard - X7"},
+	"BM.Standard2": {OCPU: "Compute - Bare Metal Standard - X7"},
+	"VM.DenseIO2":  {OCPU: "Compute - Virtual Machine Dense I/O - X7"},
+	"BM.DenseIO2":  {OCPU: "Compute - Bare Metal Dense I/O - X7"},
+	"GPU3":         {GPU: "Compute - GPU Standard - V100"},
+	"GPU.A10":      {GPU: "Compute - GPU Standard - A10"},
+	"GPU.A100-v2":  {GPU: "Compute - GPU Standard - A100 - v2"},
+	"GPU.H100":     {GPU: "Compute - GPU Standard - H100"},
+}
+
+type ociPriceList struct {
+	Items []struct {
+		DisplayName               string `json:"displayName"`
+		CurrencyCodeLocalizations []struct {
+			CurrencyCode string `json:"currencyCode"`
+			Prices       []struct {
+				Model string  `json:"model"`
+				Value float64 `json:"value"`
+			} `json:"prices"`
+		} `json:"currencyCodeLocalizations"`
+	} `json:"items"`
+}
+
+type OCIPriceClient struct {
+	configProvider    common.ConfigurationProvider
+	tenancyID         string
+	priceListEndpoint string
+	httpClient        *http.Client
+
+	// regions are discovered by the first refresh
+	regions *lazyRegions
+
+	priceStore
+}
+
+var _ Provider = &OCIPriceClient{}
+
+// NewOCIPriceClient creates the client with the oci config file, the default config provider is used
+// when the file is not set.
+func NewOCIPriceClient(ctx context.Context, configFile, profile string, initialUpdate bool) (*OCIPriceClient, error) {
+	configProvider := common.DefaultConfigProvider()
+	if configFile != "" {
+		var err error
+		configProvider, err = common.ConfigurationProviderFromFileWithProfile(configFile, lo.Ternary(profile == "",
+			"DEFAULT", profile), "")
+		if err != nil {
+			klog.Errorf("Failed to load oci config file %s:%v", configFile, err)
+			return nil, err
+		}
+	}
+	tenancyID, err := configProvider.TenancyOCID()
+	if err != nil {
+		klog.Errorf("Failed to get oci tenancy id:%v", err)
+		return nil, err
+	}
+
+	client := &OCIPriceClient{
+		configProvider:    configProvider,
+		tenancyID:         tenancyID,
+		priceListEndpoint: DefaultOCIPriceListEndpoint,
+		httpClient:        &http.Client{Timeout: time.Minute},
+		priceStore:        newPriceStore(apis.OCIProviderName),
+	}
+	if err := client.loadBuiltinData("oci_price.json"); err != nil {
+		return nil, err
+	}
+
+	client.regions = newLazyRegions(client.listRegions)
+	// The followers never refresh, so they never discover the regions, the others fail fast on the invalid
+	// credentials
+	if initialUpdate {
+		if _, err := client.regions.get(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	client.setRefreshers(map[string]func(ctx context.Context){
+		apis.SourceOnDemand: client.RefreshOnDemandPrice,
+		apis.SourceMetadata: client.refreshMetadata,
+	})
+
+	client.setInitialSources(apis.SourceOnDemand)
+	if initialUpdate {
+		client.RefreshInitialSources(ctx)
+		return client, nil
+	}
+
+	client.refreshInstanceTypeMetadataAndAvailableRegion()
+	return client, nil
+}
+
+func (o *OCIPriceClient) Run(ctx context.Context) {
+	odTicker := time.NewTicker(o.onDemandInterval())
+	defer odTicker.Stop()
+
+	for {
+		select {
+		case <-odTicker.C:
+			o.RefreshOnDemandPrice(ctx)
+		case <-o.settingsUpdated:
+			odTicker.Reset(o.onDemandInterval())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (o *OCIPriceClient) listRegions(ctx context.Context) ([]string, error) {
+	client, err := identity.NewIdentityClientWithConfigurationProvider(o.configProvider)
+	if err != nil {
+		klog.Errorf("Failed to create oci identity client:%v", err)
+		return nil, err
+	}
+
+	resp, err := client.ListRegionSubscriptions(ctx, identity.ListRegionSubscriptionsRequest{
+		TenancyId: &o.tenancyID,
+	})
+	o.observeAPICall("ListRegionSubscriptions", err)
+	if err != nil {
+		klog.Errorf("Failed to list region subscriptions:%v", err)
+		return nil, err
+	}
+
+	var regions []string
+	for _, subscription := range resp.Items {
+		if subscription.Status != identity.RegionSubscriptionStatusReady {
+			continue
+		}
+		regions = append(regions, lo.FromPtr(subscription.RegionName))
+	}
+	return regions, nil
+}
+
+// listUnitPrices returns the pay-as-you-go price of every price list item by the display name.
+func (o *OCIPriceClient) listUnitPrices(ctx context.Context) (map[string]float64, error) {
+	var priceList ociPriceList
+	err := getJSON(ctx, o.httpClient, o.priceListEndpoint, &priceList)
+	o.observeAPICall("GetPriceList", err)
+	if err != nil {
+		klog.Errorf("Failed to get oci price list:%v", err)
+		return nil, err
+	}
+
+	prices := map[string]float64{}
+	for _, item := range priceList.Items {
+		for _, localization := range item.CurrencyCodeLocalizations {
+			if localization.CurrencyCode != "USD" {
+				continue
+			}
+			// The items with free tier have a zero price for the first range, take the paid one
+			for _, price := range localization.Prices {
+				if price.Model == ociPayAsYouGoModel && price.Value > prices[item.DisplayName] {
+					prices[item.DisplayName] = price.Value
+				}
+			}
+		}
+	}
+	return prices, nil
+}
+
+// ociShapeSeries trims the size suffix of the shape, like VM.Standard.E4.Flex to VM.Standard.E4
+// and BM.Standard2.52 to BM.Standard2.
+func ociShapeSeries(shape string) string {
+	parts := strings.Split(shape, ".")
+	for len(parts) > 1 {
+		last := parts[len(parts)-1]
+		if _, err := strconv.Atoi(last); err != nil && last != "Flex" {
+			break
+		}
+		parts = parts[:len(parts)-1]
+	}
+	return strings.Join(parts, ".")
+}
+
+func findOCIPriceParts(shape string) (ociPriceParts, bool) {
+	series := ociShapeSeries(shape)
+	if parts, ok := ociShapePriceParts[series]; ok {
+		return parts, true
+	}
+	_, withoutPrefix, _ := strings.Cut(series, ".")
+	parts, ok := ociShapePriceParts[withoutPrefix]
+	return parts, ok
+}
+
+// listShapes returns the shapes of every availability domain in the region.
+func (o *OCIPriceClient) listShapes(ctx context.Context, region string) (map[string]core.Shape, map[string]sets.Set[string], error) {
+	identityClient, err := identity.NewIdentityClientWithConfigurationProvider(o.configProvider)
+	if err != nil {
+		klog.Errorf("Failed to create oci identity client:%v", err)
+		return nil, nil, err
+	}
+	identityClient.SetRegion(region)
+
+	adResp, err := identityClient.ListAvailabilityDomains(ctx, identity.ListAvailabilityDomainsRequest{
+		CompartmentId: &o.tenancyID,
+	})
+	o.observeAPICall("ListAvailabilityDomains", err)
+	if err != nil {
+		klog.Errorf("Failed to list availability domains in region %s:%v", region, err)
+		return nil, nil, err
+	}
+
+	computeClient, err := core.NewComputeClientWithConfigurationProvider(o.configProvider)
+	if err != nil {
+		klog.Errorf("Failed to create oci compute client:%v", err)
+		return nil, nil, err
+	}
+	computeClient.SetRegion(region)
+
+	shapes := map[string]core.Shape{}
+	zones := map[string]sets.Set[string]{}
+	for _, ad := range adResp.Items {
+		req := core.ListShapesRequest{
+			CompartmentId:      &o.tenancyID,
+			AvailabilityDomain: ad.Name,
+		}
+		for {
+			resp, err := computeClient.ListShapes(ctx, req)
+			o.observeAPICall("ListShapes", err)
+			if err != nil {
+				klog.Errorf("Failed to list shapes in availability domain %s:%v", lo.FromPtr(ad.Name), err)
+				return nil, nil, err
+			}
+			for _, shape := range resp.Items {
+				name := lo.FromPtr(shape.Shape)
+				if _, ok := shapes[name]; !ok {
+					shapes[name] = shape
+					zones[name] = sets.Set[string]{}
+				}
+				zones[name].Insert(lo.FromPtr(ad.Name))
+			}
+
+			if resp.OpcNextPage == nil {
+				break
+			}
+			req.Page = resp.OpcNextPage
+		}
+	}
+
+	return shapes, zones, nil
+}
+
+// newOCIInstanceTypePrice prices the shape by the unit prices, the flexible shape carries the unit price
+// and the on-demand price of its default configuration.
+func newOCIInstanceTypePrice(shape core.Shape, parts ociPriceParts, unitPrices map[string]float64) (*apis.InstanceTypePrice, error) {
+	var cpuPrice, memoryPrice, gpuPrice float64
+	for _, part := range []struct {
+		name  string
+		price *float64
+	}{
+		{parts.OCPU, &cpuPrice},
+		{parts.Memory, &memoryPrice},
+		{parts.GPU, &gpuPrice},
+	} {
+		if part.name == "" {
+			continue
+		}
+		price, ok := unitPrices[part.name]
+		if !ok {
+			return nil, fmt.Errorf("price of %s is not found", part.name)
+		}
+		*part.price = price
+	}
+
+	arch, vcpuPerOCPU := "amd64", 2.0
+	if strings.Contains(lo.FromPtr(shape.ProcessorDescription), "Ampere") {
+		// One OCPU of Ampere is one core with a single thread
+		arch, vcpuPerOCPU = "arm64", 1.0
+	}
+
+	ocpus := float64(lo.FromPtr(shape.Ocpus))
+	memory := float64(lo.FromPtr(shape.MemoryInGBs))
+	gpus := float64(lo.FromPtr(shape.Gpus))
+	ret := &apis.InstanceTypePrice{
+		InstanceTypeMetadata: apis.InstanceTypeMetadata{
+			Arch:           arch,
+			VCPU:           ocpus * vcpuPerOCPU,
+			Memory:         memory,
+			GPU:            gpus,
+			InstanceFamily: ociShapeSeries(lo.FromPtr(shape.Shape)),
+		},
+		OnDemandPricePerHour: ocpus*cpuPrice + memory*memoryPrice + gpus*gpuPrice,
+	}
+
+	if lo.FromPtr(shape.IsFlexible) {
+		unitPrice := &apis.UnitPrice{
+			CPUUnit:         ociCPUUnit,
+			VCPUPerCPUUnit:  vcpuPerOCPU,
+			CPUPerHour:      cpuPrice,
+			MemoryGBPerHour: memoryPrice,
+			GPUPerHour:      gpuPrice,
+			GPU:             gpus,
+		}
+		if shape.OcpuOptions != nil {
+			unitPrice.MinCPU = float64(lo.FromPtr(shape.OcpuOptions.Min))
+			unitPrice.MaxCPU = float64(lo.FromPtr(shape.OcpuOptions.Max))
+		}
+		if shape.MemoryOptions != nil {
+			unitPrice.MinMemoryGB = float64(lo.FromPtr(shape.MemoryOptions.MinInGBs))
+			unitPrice.MaxMemoryGB = float64(lo.FromPtr(shape.MemoryOptions.MaxInGBs))
+			unitPrice.MinMemoryGBPerCPU = float64(lo.FromPtr(shape.MemoryOptions.MinPerOcpuInGBs))
+			unitPrice.MaxMemoryGBPerCPU = float64(lo.FromPtr(shape.MemoryOptions.MaxPerOcpuInGBs))
+		}
+		ret.UnitPrice = unitPrice
+	}
+
+	return ret, nil
+}
+
+func (o *OCIPriceClient) RefreshOnDemandPrice(ctx context.Context) {
+	startTime := time.Now()
+	unitPrices, err := o.listUnitPrices(ctx)
+	if err != nil {
+		o.observeRefreshAll(apis.SourceOnDemand, startTime, err)
+		return
+	}
+
+	regions, ok := o.allowedRegionsOf(ctx, o.regions, apis.SourceOnDemand)
+	if !ok {
+		return
+	}
+	workqueue.ParallelizeUntil(ctx, o.concurrency(10), len(regions), func(i int) {
+		region := regions[i]
+		klog.Infof("Start to handle region %s for on-demand", region)
+
+		regionStartTime := time.Now()
+		shapes, zones, err := o.listShapes(ctx, region)
+		o.observeRefresh(region, apis.SourceOnDemand, regionStartTime, err)
+		if err != nil {
+			return
+		}
+
+		instanceTypes := map[string]*apis.InstanceTypePrice{}
+		for name, shape := range shapes {
+			if shape.BillingType == core.ShapeBillingTypeAlwaysFree {
+				continue
+			}
+			parts, ok := findOCIPriceParts(name)
+			if !ok {
+				klog.V(4).Infof("Skip shape %s without the price parts", name)
+				continue
+			}
+			ins, err := newOCIInstanceTypePrice(shape, parts, unitPrices)
+			if err != nil {
+				klog.Warningf("Failed to price shape %s:%v", name, err)
+				continue
+			}
+			if ins.OnDemandPricePerHour == 0 {
+				continue
+			}
+			ins.Zones = zones[name].UnsortedList()
+			instanceTypes[name] = ins
+		}
+		if len(instanceTypes) == 0 {
+			return
+		}
+
+		o.dataMutex.Lock()
+		defer o.dataMutex.Unlock()
+		o.priceData[region] = &apis.RegionalInstancePrice{InstanceTypePrices: instanceTypes}
+	})
+
+	o.refreshInstanceTypeMetadataAndAvailableRegion()
+	klog.Infof("All on-demand prices are refreshed for OCI")
+}
@@ -0,0 +1,312 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+	"github.com/cloudpilot-ai/priceserver/pkg/plugin"
+	pluginv1 "github.com/cloudpilot-ai/priceserver/pkg/plugin/api/v1"
+)
+
+const (
+	defaultPluginRefreshInterval = time.Minute * 30
+	pluginStartTimeout           = time.Minute
+	pluginCallTimeout            = time.Second * 10
+	pluginMaxRestartBackoff      = time.Minute
+	// the max lookups of the instance types not polled waiting for the Run loop
+	pluginMaxPendingLookups = 100
+)
+
+// PluginConfig describes an out-of-process provider plugin, either Command or Address should be set.
+type PluginConfig struct {
+	Name    string
+	Service string
+	// Command is started and restarted by priceserver, the address to listen on is passed by plugin.AddressEnv
+	Command []string
+	// Address is the address of a plugin managed by others, like unix:///tmp/plugin.sock or 127.0.0.1:9000
+	Address         string
+	RefreshInterval time.Duration
+}
+
+// ParsePluginConfigs parses the plugins like <name>/<service>=exec:<command> <args>;<name>/<service>=grpc:<address>.
+func ParsePluginConfigs(value string) ([]PluginConfig, error) {
+	var configs []PluginConfig
+	for _, item := range strings.Split(value, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		key, target, found := strings.Cut(item, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid plugin %q, should be like <name>/<service>=exec:<command>", item)
+		}
+		name, service, found := strings.Cut(key, "/")
+		if !found || name == "" || service == "" {
+			return nil, fmt.Errorf("invalid plugin %q, the name and the service are required", item)
+		}
+
+		config := PluginConfig{Name: name, Service: service, RefreshInterval: defaultPluginRefreshInterval}
+		if command, ok := strings.CutPrefix(target, "exec:"); ok {
+			config.Command = strings.Fields(command)
+		} else if address, ok := strings.CutPrefix(target, "grpc:"); ok {
+			config.Address = address
+		}
+		if len(config.Command) == 0 && config.Address == "" {
+			return nil, fmt.Errorf("invalid plugin %q, exec:<command> or grpc:<address> is required", item)
+		}
+		configs = append(configs, config)
+	}
+	return configs, nil
+}
+
+// PluginPriceClient serves the prices of an out-of-process plugin. The prices are polled into the local store,
+// so the prices are still served with the last data when the plugin is down, and a crashed plugin started by
+// priceserver is restarted with backoff.
+type PluginPriceClient struct {
+	config  PluginConfig
+	address string
+
+	conn   *grpc.ClientConn
+	client pluginv1.PriceProviderClient
+
+	// lookups are the instance types not polled, they are looked up by the Run loop
+	lookups       chan pluginLookup
+	lookupsMutex  sync.Mutex
+	lookupsQueued map[pluginLookup]time.Time
+
+	priceStore
+}
+
+type pluginLookup struct {
+	region       string
+	instanceType string
+}
+
+var _ Provider = &PluginPriceClient{}
+
+func NewPluginPriceClient(config PluginConfig) (*PluginPriceClient, error) {
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = defaultPluginRefreshInterval
+	}
+
+	address := config.Address
+	if len(config.Command) != 0 {
+		address = "unix://" + filepath.Join(os.TempDir(), fmt.Sprintf("priceserver-plugin-%s.sock", config.Name))
+	}
+
+	// The connection is established lazily, so the plugin does not need to be up here
+	conn, err := grpc.Dial(address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(plugin.MaxMessageSize)))
+	if err != nil {
+		klog.Errorf("Failed to connect to plugin %s:%v", config.Name, err)
+		return nil, err
+	}
+
+	client := &PluginPriceClient{
+		config:        config,
+		address:       address,
+		conn:          conn,
+		client:        pluginv1.NewPriceProviderClient(conn),
+		lookups:       make(chan pluginLookup, pluginMaxPendingLookups),
+		lookupsQueued: map[pluginLookup]time.Time{},
+		priceStore:    newPriceStore(config.Name),
+	}
+	client.setRefreshers(map[string]func(ctx context.Context){
+		apis.SourcePlugin: func(ctx context.Context) { client.refresh(ctx) },
+	})
+	return client, nil
+}
+
+func (p *PluginPriceClient) Run(ctx context.Context) {
+	defer p.conn.Close()
+
+	if len(p.config.Command) != 0 {
+		go p.supervise(ctx)
+	}
+
+	// Wait for the plugin to be ready for the first refresh
+	startCtx, cancel := context.WithTimeout(ctx, pluginStartTimeout)
+	p.refresh(startCtx, grpc.WaitForReady(true))
+	cancel()
+
+	ticker := time.NewTicker(p.config.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.refresh(ctx)
+		case l := <-p.lookups:
+			p.lookup(ctx, l)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// supervise starts the plugin command and restarts it when it exits until the ctx is done.
+func (p *PluginPriceClient) supervise(ctx context.Context) {
+	backoff := time.Second
+	for {
+		startTime := time.Now()
+		err := p.runCommand(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Reset the backoff when the plugin has been running for a while
+		if time.Since(startTime) > pluginMaxRestartBackoff {
+			backoff = time.Second
+		}
+		klog.Errorf("Plugin %s exited:%v, restart it in %v", p.config.Name, err, backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff = min(backoff*2, pluginMaxRestartBackoff)
+	}
+}
+
+func (p *PluginPriceClient) runCommand(ctx context.Context) error {
+	cmd := exec.CommandContext(ctx, p.config.Command[0], p.config.Command[1:]...)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%s", plugin.AddressEnv, p.address))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// Stop the plugin gracefully when priceserver exits, and kill it if it does not exit in time
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.WaitDelay = time.Second * 10
+
+	klog.Infof("Start plugin %s: %s", p.config.Name, strings.Join(p.config.Command, " "))
+	return cmd.Run()
+}
+
+// refresh polls all the prices from the plugin, the regions failed to be listed keep the previous data.
+func (p *PluginPriceClient) refresh(ctx context.Context, opts ...grpc.CallOption) {
+	defer func() {
+		// The data from the plugin is untrusted, never let it take the server down
+		if r := recover(); r != nil {
+			klog.Errorf("Recovered from refreshing plugin %s:%v", p.config.Name, r)
+		}
+	}()
+
+	startTime := time.Now()
+	callCtx, cancel := context.WithTimeout(ctx, pluginCallTimeout)
+	regionsResp, err := p.client.ListRegions(callCtx, &pluginv1.ListRegionsRequest{}, opts...)
+	cancel()
+	p.observeAPICall("ListRegions", err)
+	if err != nil {
+		p.observeRefreshAll(apis.SourcePlugin, startTime, err)
+		klog.Errorf("Failed to list regions from plugin %s:%v", p.config.Name, err)
+		return
+	}
+
+	priceData := map[string]*apis.RegionalInstancePrice{}
+	for _, region := range p.allowedRegions(regionsResp.GetRegions()) {
+		regionStartTime := time.Now()
+		callCtx, cancel := context.WithTimeout(ctx, pluginCallTimeout)
+		resp, err := p.client.ListInstancePrices(callCtx, &pluginv1.ListInstancePricesRequest{Region: region}, opts...)
+		cancel()
+		p.observeAPICall("ListInstancePrices", err)
+		p.observeRefresh(region, apis.SourcePlugin, regionStartTime, err)
+		if err != nil {
+			klog.Errorf("Failed to list instance prices in region %s from plugin %s:%v", region, p.config.Name, err)
+			p.dataMutex.RLock()
+			if old, ok := p.priceData[region]; ok {
+				priceData[region] = old
+			}
+			p.dataMutex.RUnlock()
+			continue
+		}
+
+		instanceTypes := map[string]*apis.InstanceTypePrice{}
+		for instanceType, price := range resp.GetInstanceTypePrices() {
+			if price == nil {
+				continue
+			}
+			instanceTypes[instanceType] = plugin.ToInstanceTypePrice(price)
+		}
+		priceData[region] = &apis.RegionalInstancePrice{InstanceTypePrices: instanceTypes}
+	}
+
+	p.dataMutex.Lock()
+	p.priceData = priceData
+	p.dataMutex.Unlock()
+
+	p.refreshInstanceTypeMetadataAndAvailableRegion()
+	klog.Infof("All prices are refreshed for plugin %s", p.config.Name)
+}
+
+// GetInstancePrice serves from the local store only, the instance types not polled yet are queued to be looked up
+// by the Run loop, so the requests never wait for the plugin. An instance type is looked up once per refresh
+// interval, the unknown ones are not asked again and again.
+func (p *PluginPriceClient) GetInstancePrice(region, instanceType string) *apis.InstanceTypePrice {
+	if price := p.priceStore.GetInstancePrice(region, instanceType); price != nil {
+		return price
+	}
+	if !p.regionAllowed(region) {
+		return nil
+	}
+
+	l := pluginLookup{region: region, instanceType: instanceType}
+	p.lookupsMutex.Lock()
+	defer p.lookupsMutex.Unlock()
+	if queueTime, ok := p.lookupsQueued[l]; ok && time.Since(queueTime) < p.config.RefreshInterval {
+		return nil
+	}
+	select {
+	case p.lookups <- l:
+		p.lookupsQueued[l] = time.Now()
+	default:
+		// Too many lookups are pending, the instance type is looked up by a later request
+	}
+	return nil
+}
+
+// lookup gets the price of an instance type not polled from the plugin and stores it until the next refresh.
+func (p *PluginPriceClient) lookup(ctx context.Context, l pluginLookup) {
+	callCtx, cancel := context.WithTimeout(ctx, pluginCallTimeout)
+	defer cancel()
+	resp, err := p.client.GetInstancePrice(callCtx, &pluginv1.GetInstancePriceRequest{
+		Region:       l.region,
+		InstanceType: l.instanceType,
+	})
+	p.observeAPICall("GetInstancePrice", err)
+	if err != nil {
+		if status.Code(err) != codes.NotFound {
+			klog.Errorf("Failed to get price of %s in region %s from plugin %s:%v", l.instanceType, l.region,
+				p.config.Name, err)
+		}
+		return
+	}
+
+	p.dataMutex.Lock()
+	regional, ok := p.priceData[l.region]
+	if !ok {
+		regional = &apis.RegionalInstancePrice{InstanceTypePrices: map[string]*apis.InstanceTypePrice{}}
+		p.priceData[l.region] = regional
+	}
+	regional.InstanceTypePrices[l.instanceType] = plugin.ToInstanceTypePrice(resp)
+	p.dataMutex.Unlock()
+
+	p.refreshInstanceTypeMetadataAndAvailableRegion()
+}
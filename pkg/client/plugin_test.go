@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pluginv1 "github.com/cloudpilot-ai/priceserver/pkg/plugin/api/v1"
+)
+
+// fakePlugin knows the price of m1.large in r1 only and counts the lookups.
+type fakePlugin struct {
+	pluginv1.PriceProviderClient
+	calls int
+}
+
+func (f *fakePlugin) GetInstancePrice(_ context.Context, req *pluginv1.GetInstancePriceRequest,
+	_ ...grpc.CallOption) (*pluginv1.InstanceTypePrice, error) {
+	f.calls++
+	if req.GetRegion() != "r1" || req.GetInstanceType() != "m1.large" {
+		return nil, status.Error(codes.NotFound, "unknown instance type")
+	}
+	return &pluginv1.InstanceTypePrice{Vcpu: 2, Memory: 8, OnDemandPricePerHour: 0.1}, nil
+}
+
+func TestPluginGetInstancePriceLookup(t *testing.T) {
+	fake := &fakePlugin{}
+	p := &PluginPriceClient{
+		config:        PluginConfig{Name: "fake", RefreshInterval: time.Hour},
+		client:        fake,
+		lookups:       make(chan pluginLookup, pluginMaxPendingLookups),
+		lookupsQueued: map[pluginLookup]time.Time{},
+		priceStore:    newPriceStore("fake"),
+	}
+	// lookupAll runs the lookups queued like the Run loop
+	lookupAll := func() {
+		for {
+			select {
+			case l := <-p.lookups:
+				p.lookup(context.Background(), l)
+			default:
+				return
+			}
+		}
+	}
+
+	// The misses are served at once and looked up later
+	for i := 0; i < 3; i++ {
+		if price := p.GetInstancePrice("r1", "m1.large"); price != nil {
+			t.Fatalf("Expected no price before the lookup, got %+v", price)
+		}
+		if price := p.GetInstancePrice("r1", "unknown"); price != nil {
+			t.Fatalf("Expected no price of the unknown instance type, got %+v", price)
+		}
+	}
+	lookupAll()
+	if fake.calls != 2 {
+		t.Errorf("Expected every instance type looked up once, got %d calls", fake.calls)
+	}
+
+	price := p.GetInstancePrice("r1", "m1.large")
+	if price == nil || price.OnDemandPricePerHour != 0.1 || price.VCPU != 2 {
+		t.Fatalf("Expected the looked up price of m1.large, got %+v", price)
+	}
+	// The unknown instance type is not asked again within the refresh interval
+	if price := p.GetInstancePrice("r1", "unknown"); price != nil {
+		t.Errorf("Expected no price of the unknown instance type, got %+v", price)
+	}
+	lookupAll()
+	if fake.calls != 2 {
+		t.Errorf("Expected the unknown instance type not looked up again, got %d calls", fake.calls)
+	}
+}
@@ -0,0 +1,135 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+)
+
+// Provider is implemented by every cloud price client served by priceserver.
+type Provider interface {
+	// Run is used to refresh the price data periodically
+	Run(ctx context.Context)
+	// ListRegionsInstancesPrice returns the price data of all the regions
+	ListRegionsInstancesPrice() map[string]*apis.RegionalInstancePrice
+	// ListInstancesPrice returns the price data of the specified region
+	ListInstancesPrice(region string) *map[string]apis.RegionalInstancePrice
+	// GetInstancePrice returns the price data of the specified instance type in one region
+	GetInstancePrice(region, instanceType string) *apis.InstanceTypePrice
+	// ListInstanceTypes returns all the known instance types
+	ListInstanceTypes() []string
+	// GetInstanceInfo returns the metadata and the available regions of the specified instance type
+	GetInstanceInfo(instanceType string) *apis.InstanceInfo
+}
+
+// InstanceTypeCounter is implemented by the providers able to count the instance types of each region cheaply.
+type InstanceTypeCounter interface {
+	InstanceTypeCounts() map[string]int
+}
+
+// CredentialHealthReporter is implemented by the providers tracking the health of their credentials.
+type CredentialHealthReporter interface {
+	CredentialHealth() []apis.CredentialHealth
+}
+
+// CredentialReloader is implemented by the providers able to swap the credentials read from the secret files
+// without restarting.
+type CredentialReloader interface {
+	// SecretFiles returns the secret files to watch, no file means the credentials are not from files
+	SecretFiles() []string
+	ReloadCredentials() error
+}
+
+// RegisteredProvider is a provider mounted under /api/v1/{Name}/{Service}.
+type RegisteredProvider struct {
+	Name     string
+	Service  string
+	Provider Provider
+}
+
+type ProviderRegistry struct {
+	mutex     sync.RWMutex
+	providers []*RegisteredProvider
+	// disabled are the known providers not enabled, they are mounted to tell the clients so
+	disabled []*RegisteredProvider
+}
+
+func NewProviderRegistry() *ProviderRegistry {
+	return &ProviderRegistry{}
+}
+
+func (r *ProviderRegistry) Register(name, service string, provider Provider) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err := r.checkRegistered(name); err != nil {
+		return err
+	}
+	r.providers = append(r.providers, &RegisteredProvider{
+		Name:     name,
+		Service:  service,
+		Provider: provider,
+	})
+	return nil
+}
+
+// RegisterDisabled records a known provider which is not enabled, its routes return 501 with the provider not
+// enabled instead of 404.
+func (r *ProviderRegistry) RegisterDisabled(name, service string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err := r.checkRegistered(name); err != nil {
+		return err
+	}
+	r.disabled = append(r.disabled, &RegisteredProvider{
+		Name:    name,
+		Service: service,
+	})
+	return nil
+}
+
+func (r *ProviderRegistry) checkRegistered(name string) error {
+	for _, providers := range [][]*RegisteredProvider{r.providers, r.disabled} {
+		for _, p := range providers {
+			if p.Name == name {
+				return fmt.Errorf("provider %s is already registered", name)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *ProviderRegistry) Get(name string) (*RegisteredProvider, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, p := range r.providers {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// List returns the registered providers in registration order.
+func (r *ProviderRegistry) List() []*RegisteredProvider {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	ret := make([]*RegisteredProvider, len(r.providers))
+	copy(ret, r.providers)
+	return ret
+}
+
+// ListDisabled returns the disabled providers in registration order, the Provider of them is nil.
+func (r *ProviderRegistry) ListDisabled() []*RegisteredProvider {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	ret := make([]*RegisteredProvider, len(r.disabled))
+	copy(ret, r.disabled)
+	return ret
+}
@@ -0,0 +1,42 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+)
+
+// newRecordedServer replays the responses recorded in testdata/<dir>. The route returns the file of the response
+// to a request, the requests without a recorded response fail the test. {{server}} in the responses is replaced by
+// the url of the server, like in the links to the next pages.
+func newRecordedServer(t *testing.T, dir string, route func(r *http.Request) string) *httptest.Server {
+	t.Helper()
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := route(r)
+		data, err := os.ReadFile(filepath.Join("testdata", dir, name))
+		if name == "" || err != nil {
+			t.Errorf("No response is recorded for %s %s: %v", r.Method, r.URL, err)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(strings.ReplaceAll(string(data), "{{server}}", server.URL)))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// dropBuiltinData drops the prices loaded by the constructors, so only the refreshed prices are checked.
+func dropBuiltinData(s *priceStore) {
+	s.dataMutex.Lock()
+	s.priceData = map[string]*apis.RegionalInstancePrice{}
+	s.dataMutex.Unlock()
+	s.refreshInstanceTypeMetadataAndAvailableRegion()
+}
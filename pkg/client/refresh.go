@@ -0,0 +1,84 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+)
+
+// Refresher is implemented by the providers able to refresh a source on demand, like after a price change is
+// announced.
+type Refresher interface {
+	// ValidateRefresh checks whether the provider is able to refresh the scope
+	ValidateRefresh(scope apis.RefreshScope) error
+	// Refresh refreshes the scope and returns when it is done, the results are tracked by the refresh status
+	Refresh(ctx context.Context, scope apis.RefreshScope)
+}
+
+// InitialRefresher is implemented by the providers refreshing some sources before serving, so the builtin data
+// is not served for long.
+type InitialRefresher interface {
+	// RefreshInitialSources refreshes the sources and marks the provider not ready until they succeed
+	RefreshInitialSources(ctx context.Context)
+}
+
+// setRefreshers sets the functions refreshing the whole sources, they are keyed by the source.
+func (s *priceStore) setRefreshers(refreshers map[string]func(ctx context.Context)) {
+	s.refreshers = refreshers
+}
+
+// setUnsupportedSources sets the sources the provider can not serve, they are reported by the refresh status and
+// their refreshes are rejected with the reasons.
+func (s *priceStore) setUnsupportedSources(sources map[string]string) {
+	s.unsupportedSources = sources
+}
+
+// ValidateRefresh accepts the sources refreshed by the provider, the refreshes can not be narrowed by the region
+// or the instance type unless the provider overrides it.
+func (s *priceStore) ValidateRefresh(scope apis.RefreshScope) error {
+	if reason, ok := s.unsupportedSources[scope.Source]; ok {
+		return fmt.Errorf("source %q is not supported by the provider, %s", scope.Source, reason)
+	}
+	if len(s.refreshers) == 0 {
+		return fmt.Errorf("the provider does not refresh the prices")
+	}
+	if _, ok := s.refreshers[scope.Source]; !ok {
+		sources := make([]string, 0, len(s.refreshers))
+		for source := range s.refreshers {
+			sources = append(sources, source)
+		}
+		sort.Strings(sources)
+		return fmt.Errorf("source %q is not refreshed by the provider, the sources are %v", scope.Source, sources)
+	}
+	if scope.Region != "" || scope.InstanceType != "" {
+		return fmt.Errorf("the provider refreshes the whole source %s only, the region and the instance type are "+
+			"not supported", scope.Source)
+	}
+	return nil
+}
+
+func (s *priceStore) Refresh(ctx context.Context, scope apis.RefreshScope) {
+	s.refreshers[scope.Source](ctx)
+}
+
+// setInitialSources sets the sources refreshed by RefreshInitialSources, they should have the refreshers.
+func (s *priceStore) setInitialSources(sources ...string) {
+	s.initialSources = sources
+}
+
+func (s *priceStore) RefreshInitialSources(ctx context.Context) {
+	s.requireRefresh(s.initialSources...)
+	for _, source := range s.initialSources {
+		s.refreshers[source](ctx)
+	}
+}
+
+// refreshMetadata rebuilds the instance type index from the price data.
+func (s *priceStore) refreshMetadata(ctx context.Context) {
+	startTime := time.Now()
+	s.refreshInstanceTypeMetadataAndAvailableRegion()
+	s.observeRefresh("", apis.SourceMetadata, startTime, nil)
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// lazyRegions discovers the regions of a provider on the first refresh instead of in the constructor, so the
+// followers of the leader election serving the snapshots never call the cloud apis. A failed discovery is retried
+// by the next refresh.
+type lazyRegions struct {
+	mutex    sync.Mutex
+	regions  []string
+	discover func(ctx context.Context) ([]string, error)
+}
+
+func newLazyRegions(discover func(ctx context.Context) ([]string, error)) *lazyRegions {
+	return &lazyRegions{discover: discover}
+}
+
+// get returns the regions, they are discovered once unless the discovery fails.
+func (l *lazyRegions) get(ctx context.Context) ([]string, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.regions != nil {
+		return l.regions, nil
+	}
+	regions, err := l.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	l.regions = append([]string{}, regions...)
+	return l.regions, nil
+}
+
+// allowedRegionsOf returns the regions allowed by the settings, the refresh of the source fails in all the regions
+// served when the regions can not be discovered.
+func (s *priceStore) allowedRegionsOf(ctx context.Context, regions *lazyRegions, source string) ([]string, bool) {
+	startTime := time.Now()
+	discovered, err := regions.get(ctx)
+	if err != nil {
+		s.observeRefreshAll(source, startTime, err)
+		return nil, false
+	}
+	return s.allowedRegions(discovered), true
+}
@@ -0,0 +1,122 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog"
+)
+
+const (
+	// SecretFileEnvSuffix is appended to the env of a secret to read the secret from a file, like the mounted
+	// secret volumes, for example AWS_GLOBAL_SECRET_KEY_FILE
+	SecretFileEnvSuffix = "_FILE"
+
+	// secretReloadDelay merges the burst of events when a secret volume is updated
+	secretReloadDelay = time.Second
+)
+
+// getSecretEnv returns the secret of the env, it is read from the file in <env>_FILE when that is set.
+func getSecretEnv(env string) string {
+	file := os.Getenv(env + SecretFileEnvSuffix)
+	if file == "" {
+		return os.Getenv(env)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		klog.Errorf("Failed to read the secret file %s of %s:%v", file, env, err)
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// SecretFiles returns the files of the secret envs set with the _FILE suffix.
+func SecretFiles(envs ...string) []string {
+	var files []string
+	for _, env := range envs {
+		if file := os.Getenv(env + SecretFileEnvSuffix); file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// WatchSecretFiles calls onChange when the content of any file changes until the ctx is done. The parent
+// directories are watched, so the secret volumes updated by swapping the symlinks are also noticed.
+func WatchSecretFiles(ctx context.Context, files []string, onChange func()) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		klog.Errorf("Failed to create secret watcher:%v", err)
+		return
+	}
+	defer watcher.Close()
+
+	dirs := sets.Set[string]{}
+	for _, file := range files {
+		dirs.Insert(filepath.Dir(file))
+	}
+	for _, dir := range sets.List(dirs) {
+		if err := watcher.Add(dir); err != nil {
+			klog.Errorf("Failed to watch %s:%v", dir, err)
+		}
+	}
+
+	contents := readSecretFiles(files)
+	reloadTimer := time.NewTimer(secretReloadDelay)
+	reloadTimer.Stop()
+	defer reloadTimer.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Has(fsnotify.Chmod) {
+				continue
+			}
+			reloadTimer.Reset(secretReloadDelay)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			klog.Errorf("Secret watcher error:%v", err)
+		case <-reloadTimer.C:
+			// The events of the other files in the same directories are ignored here
+			newContents := readSecretFiles(files)
+			if secretFilesEqual(contents, newContents) {
+				continue
+			}
+			contents = newContents
+			onChange()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func readSecretFiles(files []string) map[string][]byte {
+	contents := map[string][]byte{}
+	for _, file := range files {
+		// The missing files are taken as empty, they may be in the middle of an update
+		data, _ := os.ReadFile(file)
+		contents[file] = data
+	}
+	return contents
+}
+
+func secretFilesEqual(a, b map[string][]byte) bool {
+	for file, data := range a {
+		if !bytes.Equal(data, b[file]) {
+			return false
+		}
+	}
+	return len(a) == len(b)
+}
@@ -0,0 +1,141 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"k8s.io/klog"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+)
+
+// Snapshotter is implemented by the providers whose prices can be published to the other replicas, the snapshots
+// are in the format of the builtin data.
+type Snapshotter interface {
+	Snapshot() ([]byte, error)
+	// LoadSnapshot replaces the prices with the snapshot
+	LoadSnapshot(data []byte) error
+	// ObserveSnapshotSync records whether the prices are in sync with the latest published snapshot, and the last
+	// successful refreshes of the sources by the publisher of it
+	ObserveSnapshotSync(startTime time.Time, refreshTimes map[string]time.Time, err error)
+	// LastRefreshTimes returns the last successful refreshes of the sources refreshed locally
+	LastRefreshTimes() map[string]time.Time
+}
+
+// SnapshotPriceClient serves a price snapshot of a provider without calling any cloud api, it is used by the
+// offline mode. The snapshot is the builtin data, or the one in the snapshot directory with the same file name
+// written by the pull-data tool.
+type SnapshotPriceClient struct {
+	priceStore
+}
+
+var _ Provider = &SnapshotPriceClient{}
+
+// SnapshotFileName returns the file name of the price snapshot of the provider, like aws_price.json.
+func SnapshotFileName(provider string) string {
+	return provider + "_price.json"
+}
+
+// HasSnapshot returns whether the snapshot of the provider served by NewSnapshotPriceClient has the prices of any
+// region, the placeholder snapshots like {} are treated as missing. A snapshot failing to parse is reported as
+// present, so the error is returned when it is loaded instead of the provider being disabled silently.
+func HasSnapshot(provider, dir string) bool {
+	var (
+		data []byte
+		err  error
+	)
+	if dir != "" {
+		data, err = os.ReadFile(filepath.Join(dir, SnapshotFileName(provider)))
+	}
+	if dir == "" || errors.Is(err, fs.ErrNotExist) {
+		data, err = file.ReadFile(path.Join("builtin-data", SnapshotFileName(provider)))
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+
+	priceData := map[string]*apis.RegionalInstancePrice{}
+	if err := json.Unmarshal(data, &priceData); err != nil {
+		return true
+	}
+	return len(priceData) > 0
+}
+
+// NewSnapshotPriceClient loads the snapshot of the provider from the directory, and falls back to the builtin
+// data when the directory is empty or does not contain it.
+func NewSnapshotPriceClient(provider, dir string) (*SnapshotPriceClient, error) {
+	client := &SnapshotPriceClient{
+		priceStore: newPriceStore(provider),
+	}
+
+	fileName := SnapshotFileName(provider)
+	if dir == "" {
+		if err := client.loadBuiltinData(fileName); err != nil {
+			return nil, err
+		}
+	} else {
+		data, err := os.ReadFile(filepath.Join(dir, fileName))
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			klog.Infof("No snapshot of %s in %s, use the builtin data", provider, dir)
+			if err := client.loadBuiltinData(fileName); err != nil {
+				return nil, err
+			}
+		case err != nil:
+			klog.Errorf("Failed to read the snapshot of %s:%v", provider, err)
+			return nil, err
+		default:
+			if err := client.loadData(data); err != nil {
+				klog.Errorf("Failed to parse the snapshot of %s:%v", provider, err)
+				return nil, err
+			}
+		}
+	}
+
+	client.refreshInstanceTypeMetadataAndAvailableRegion()
+	return client, nil
+}
+
+// Run does nothing, the snapshot is never refreshed.
+func (s *SnapshotPriceClient) Run(ctx context.Context) {}
+
+func (s *priceStore) Snapshot() ([]byte, error) {
+	s.dataMutex.RLock()
+	defer s.dataMutex.RUnlock()
+	return json.Marshal(s.priceData)
+}
+
+// LoadSnapshot replaces the prices instead of merging them like loadData, so the regions and the instance types
+// dropped by the publisher are dropped here too.
+func (s *priceStore) LoadSnapshot(data []byte) error {
+	priceData := map[string]*apis.RegionalInstancePrice{}
+	if err := json.Unmarshal(data, &priceData); err != nil {
+		return err
+	}
+
+	s.dataMutex.Lock()
+	s.priceData = priceData
+	s.dataMutex.Unlock()
+	s.refreshInstanceTypeMetadataAndAvailableRegion()
+	return nil
+}
+
+func (s *priceStore) ObserveSnapshotSync(startTime time.Time, refreshTimes map[string]time.Time, err error) {
+	s.observeRefresh("", apis.SourceSnapshot, startTime, err)
+	if err == nil {
+		s.refreshes.recordPublished(refreshTimes)
+	}
+}
+
+func (s *priceStore) LastRefreshTimes() map[string]time.Time {
+	return s.refreshes.lastSuccessTimes()
+}
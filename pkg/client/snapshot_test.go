@@ -0,0 +1,41 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+)
+
+func TestHasSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"empty_price.json":   `{}`,
+		"regions_price.json": `{"us-east-1":{"instanceTypePrices":{"m5.large":{"onDemandPricePerHour":0.096}}}}`,
+		"invalid_price.json": `{`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to write the snapshot %s: %v", name, err)
+		}
+	}
+
+	for _, c := range []struct {
+		provider string
+		dir      string
+		expected bool
+	}{
+		{provider: "empty", dir: dir, expected: false},
+		{provider: "regions", dir: dir, expected: true},
+		{provider: "invalid", dir: dir, expected: true},
+		{provider: "unknown", dir: dir, expected: false},
+		{provider: "unknown", dir: "", expected: false},
+		// The builtin data is used when the directory does not contain the snapshot
+		{provider: apis.AlibabaCloudProviderName, dir: dir, expected: true},
+		{provider: apis.AlibabaCloudProviderName, dir: "", expected: true},
+	} {
+		if got := HasSnapshot(c.provider, c.dir); got != c.expected {
+			t.Errorf("HasSnapshot(%q, %q) = %v, expected %v", c.provider, c.dir, got, c.expected)
+		}
+	}
+}
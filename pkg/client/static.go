@@ -0,0 +1,333 @@
+package client
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog"
+	"sigs.k8s.io/yaml"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+)
+
+// staticReloadDelay merges the burst of events when a file is rewritten
+const staticReloadDelay = time.Second
+
+// PriceSheetEntry is one row of the price sheet, zone is optional.
+type PriceSheetEntry struct {
+	Region       string  `json:"region"`
+	Zone         string  `json:"zone"`
+	InstanceType string  `json:"instanceType"`
+	Arch         string  `json:"arch"`
+	VCPU         float64 `json:"vcpu"`
+	Memory       float64 `json:"memory"`
+	GPU          float64 `json:"gpu"`
+	PricePerHour float64 `json:"pricePerHour"`
+}
+
+// StaticPriceClient serves the prices loaded from the csv or yaml price sheets on disk, the sheets are
+// reloaded when they are changed.
+type StaticPriceClient struct {
+	// paths are the price sheet files or the directories containing them
+	paths []string
+
+	priceStore
+}
+
+var _ Provider = &StaticPriceClient{}
+
+func NewStaticPriceClient(paths []string) (*StaticPriceClient, error) {
+	client := &StaticPriceClient{
+		paths:      paths,
+		priceStore: newPriceStore(apis.StaticProviderName),
+	}
+	if err := client.reload(); err != nil {
+		return nil, err
+	}
+	client.setRefreshers(map[string]func(ctx context.Context){
+		apis.SourcePriceSheet: func(ctx context.Context) {
+			if err := client.reload(); err != nil {
+				klog.Errorf("Failed to reload price sheets, keep serving the previous ones:%v", err)
+			}
+		},
+	})
+	return client, nil
+}
+
+// Run watches the price sheets and reloads them on changes. The parent directories are watched so that the
+// files replaced by rename, like the configmap volumes, are also noticed.
+func (s *StaticPriceClient) Run(ctx context.Context) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		klog.Errorf("Failed to create price sheet watcher:%v", err)
+		return
+	}
+	defer watcher.Close()
+
+	for _, dir := range s.watchDirs() {
+		if err := watcher.Add(dir); err != nil {
+			klog.Errorf("Failed to watch %s:%v", dir, err)
+		}
+	}
+
+	reloadTimer := time.NewTimer(staticReloadDelay)
+	reloadTimer.Stop()
+	defer reloadTimer.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Has(fsnotify.Chmod) {
+				continue
+			}
+			klog.V(4).Infof("Price sheet event: %s", event)
+			reloadTimer.Reset(staticReloadDelay)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			klog.Errorf("Price sheet watcher error:%v", err)
+		case <-reloadTimer.C:
+			if err := s.reload(); err != nil {
+				klog.Errorf("Failed to reload price sheets, keep serving the previous ones:%v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *StaticPriceClient) watchDirs() []string {
+	dirs := sets.Set[string]{}
+	for _, path := range s.paths {
+		info, err := os.Stat(path)
+		if err == nil && info.IsDir() {
+			dirs.Insert(path)
+			continue
+		}
+		dirs.Insert(filepath.Dir(path))
+	}
+	return sets.List(dirs)
+}
+
+func isPriceSheet(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv", ".yaml", ".yml":
+		return true
+	default:
+		return false
+	}
+}
+
+// sheetFiles expands the directories to the price sheets in them.
+func (s *StaticPriceClient) sheetFiles() ([]string, error) {
+	var files []string
+	for _, path := range s.paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			// Skip the hidden files, like the ..data of the configmap volumes
+			if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || !isPriceSheet(entry.Name()) {
+				continue
+			}
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+	}
+	return files, nil
+}
+
+func (s *StaticPriceClient) reload() (err error) {
+	startTime := time.Now()
+	defer func() {
+		s.observeRefreshAll(apis.SourcePriceSheet, startTime, err)
+	}()
+
+	files, err := s.sheetFiles()
+	if err != nil {
+		return err
+	}
+
+	var entries []PriceSheetEntry
+	for _, file := range files {
+		fileEntries, err := loadPriceSheet(file)
+		if err != nil {
+			return fmt.Errorf("failed to load price sheet %s: %w", file, err)
+		}
+		entries = append(entries, fileEntries...)
+	}
+
+	priceData, err := buildStaticPriceData(entries)
+	if err != nil {
+		return err
+	}
+
+	s.dataMutex.Lock()
+	s.priceData = priceData
+	s.dataMutex.Unlock()
+
+	s.refreshInstanceTypeMetadataAndAvailableRegion()
+	klog.Infof("Loaded %d price sheet entries from %d files", len(entries), len(files))
+	return nil
+}
+
+// buildStaticPriceData groups the entries by region and instance type, the entries of one instance type in
+// different zones are merged and the lowest price is taken.
+func buildStaticPriceData(entries []PriceSheetEntry) (map[string]*apis.RegionalInstancePrice, error) {
+	priceData := map[string]*apis.RegionalInstancePrice{}
+	zones := map[apis.RegionTypeKey]sets.Set[string]{}
+	for i, entry := range entries {
+		if entry.Region == "" || entry.InstanceType == "" {
+			return nil, fmt.Errorf("entry %d: region and instance type are required", i)
+		}
+		if entry.PricePerHour <= 0 {
+			return nil, fmt.Errorf("entry %d: price of %s in %s should be positive", i, entry.InstanceType, entry.Region)
+		}
+
+		if _, ok := priceData[entry.Region]; !ok {
+			priceData[entry.Region] = &apis.RegionalInstancePrice{
+				InstanceTypePrices: map[string]*apis.InstanceTypePrice{},
+			}
+		}
+		key := apis.RegionTypeKey{Region: entry.Region, InstanceType: entry.InstanceType}
+		ins, ok := priceData[entry.Region].InstanceTypePrices[entry.InstanceType]
+		if !ok {
+			arch := entry.Arch
+			if arch == "" {
+				arch = "amd64"
+			}
+			ins = &apis.InstanceTypePrice{
+				InstanceTypeMetadata: apis.InstanceTypeMetadata{
+					Arch:   arch,
+					VCPU:   entry.VCPU,
+					Memory: entry.Memory,
+					GPU:    entry.GPU,
+				},
+				OnDemandPricePerHour: entry.PricePerHour,
+			}
+			priceData[entry.Region].InstanceTypePrices[entry.InstanceType] = ins
+			zones[key] = sets.Set[string]{}
+		}
+		if entry.PricePerHour < ins.OnDemandPricePerHour {
+			ins.OnDemandPricePerHour = entry.PricePerHour
+		}
+		if entry.Zone != "" {
+			zones[key].Insert(entry.Zone)
+		}
+	}
+
+	for key, zoneSet := range zones {
+		priceData[key.Region].InstanceTypePrices[key.InstanceType].Zones = sets.List(zoneSet)
+	}
+	return priceData, nil
+}
+
+func loadPriceSheet(file string) ([]PriceSheetEntry, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if strings.ToLower(filepath.Ext(file)) == ".csv" {
+		return parseCSVPriceSheet(f)
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	var entries []PriceSheetEntry
+	if err := yaml.UnmarshalStrict(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// parseCSVPriceSheet parses the csv with a header row, the columns are matched by the header names of
+// PriceSheetEntry case-insensitively, so the order of them does not matter.
+func parseCSVPriceSheet(r io.Reader) ([]PriceSheetEntry, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"region", "instancetype", "priceperhour"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("column %s is required", required)
+		}
+	}
+
+	var entries []PriceSheetEntry
+	for line, record := range records[1:] {
+		get := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		getFloat := func(name string) (float64, error) {
+			value := get(name)
+			if value == "" {
+				return 0, nil
+			}
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return 0, fmt.Errorf("line %d: invalid %s %q", line+2, name, value)
+			}
+			return f, nil
+		}
+
+		entry := PriceSheetEntry{
+			Region:       get("region"),
+			Zone:         get("zone"),
+			InstanceType: get("instancetype"),
+			Arch:         get("arch"),
+		}
+		for name, field := range map[string]*float64{
+			"vcpu":         &entry.VCPU,
+			"memory":       &entry.Memory,
+			"gpu":          &entry.GPU,
+			"priceperhour": &entry.PricePerHour,
+		} {
+			if *field, err = getFloat(name); err != nil {
+				return nil, err
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,126 @@
+package client
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+)
+
+// RefreshStatusReporter is implemented by the providers tracking their refreshes.
+type RefreshStatusReporter interface {
+	RefreshStatus() apis.ProviderRefreshStatus
+}
+
+type refreshKey struct {
+	region string
+	source string
+}
+
+// refreshTracker keeps the last refresh of every region and source of one provider.
+type refreshTracker struct {
+	mutex    sync.RWMutex
+	statuses map[refreshKey]*apis.RefreshStatus
+	// requiredSources should be refreshed successfully once before the provider is ready, like the spot prices
+	// missing from the builtin data
+	requiredSources []string
+	// published are the refresh times of the snapshot in sync, keyed by the source
+	published map[string]time.Time
+}
+
+func newRefreshTracker() *refreshTracker {
+	return &refreshTracker{statuses: map[refreshKey]*apis.RefreshStatus{}}
+}
+
+func (t *refreshTracker) require(sources ...string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.requiredSources = append(t.requiredSources, sources...)
+}
+
+func (t *refreshTracker) record(region, source string, startTime time.Time, err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	key := refreshKey{region: region, source: source}
+	status, ok := t.statuses[key]
+	if !ok {
+		status = &apis.RefreshStatus{Region: region, Source: source}
+		t.statuses[key] = status
+	}
+
+	now := time.Now()
+	status.LastAttemptTime = &startTime
+	status.DurationSeconds = now.Sub(startTime).Seconds()
+	if err != nil {
+		status.LastError = err.Error()
+		status.LastErrorTime = &now
+		return
+	}
+	status.LastSuccessTime = &now
+}
+
+func (t *refreshTracker) recordPublished(refreshTimes map[string]time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.published = make(map[string]time.Time, len(refreshTimes))
+	for source, refreshTime := range refreshTimes {
+		t.published[source] = refreshTime
+	}
+}
+
+// lastSuccessTimes returns the last success of every source refreshed locally, the syncs of the snapshots are
+// not refreshes of the prices.
+func (t *refreshTracker) lastSuccessTimes() map[string]time.Time {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	ret := map[string]time.Time{}
+	for key, status := range t.statuses {
+		if key.source == apis.SourceSnapshot || status.LastSuccessTime == nil {
+			continue
+		}
+		if status.LastSuccessTime.After(ret[key.source]) {
+			ret[key.source] = *status.LastSuccessTime
+		}
+	}
+	return ret
+}
+
+func (t *refreshTracker) status() apis.ProviderRefreshStatus {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	ret := apis.ProviderRefreshStatus{
+		Ready:           true,
+		RequiredSources: append([]string(nil), t.requiredSources...),
+		Refreshes:       make([]apis.RefreshStatus, 0, len(t.statuses)),
+	}
+	if len(t.published) != 0 {
+		ret.PublishedRefreshTimes = make(map[string]time.Time, len(t.published))
+		for source, refreshTime := range t.published {
+			ret.PublishedRefreshTimes[source] = refreshTime
+		}
+	}
+	refreshed := map[string]bool{}
+	for _, status := range t.statuses {
+		ret.Refreshes = append(ret.Refreshes, *status)
+		if status.LastSuccessTime != nil {
+			refreshed[status.Source] = true
+		}
+	}
+	for _, source := range t.requiredSources {
+		if !refreshed[source] {
+			ret.Ready = false
+		}
+	}
+
+	sort.Slice(ret.Refreshes, func(i, j int) bool {
+		if ret.Refreshes[i].Source != ret.Refreshes[j].Source {
+			return ret.Refreshes[i].Source < ret.Refreshes[j].Source
+		}
+		return ret.Refreshes[i].Region < ret.Refreshes[j].Region
+	})
+	return ret
+}
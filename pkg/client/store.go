@@ -0,0 +1,368 @@
+package client
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"path"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+	"github.com/cloudpilot-ai/priceserver/pkg/metrics"
+	"github.com/cloudpilot-ai/priceserver/pkg/scheduler"
+)
+
+//go:embed builtin-data/*.json
+var file embed.FS
+
+const (
+	defaultOnDemandInterval = time.Hour * 24 * 7
+	defaultSpotInterval     = time.Minute * 30
+	defaultMetadataInterval = time.Hour * 20
+)
+
+// ProviderSettings are the tunables of a provider, the zero values keep the defaults of the provider.
+type ProviderSettings struct {
+	OnDemandInterval time.Duration
+	SpotInterval     time.Duration
+	MetadataInterval time.Duration
+	// Concurrency limits the regions or the instance types refreshed in parallel
+	Concurrency int
+	// AllowRegions are the only regions refreshed and served when it is not empty, DenyRegions are never
+	// refreshed nor served
+	AllowRegions []string
+	DenyRegions  []string
+	// Schedules override the schedules of the sources refreshed by the scheduler, keyed by the source
+	Schedules map[string]ScheduleSettings
+}
+
+// ScheduleSettings override the schedule of a source, the nil fields keep the defaults.
+type ScheduleSettings struct {
+	// Cron replaces the interval of the source
+	Cron   scheduler.Schedule
+	Jitter *time.Duration
+	// MaxRuntime cancels the refresh taking longer, 0 means no limit
+	MaxRuntime time.Duration
+}
+
+// Configurable is implemented by the providers whose settings can be changed without restarting.
+type Configurable interface {
+	Configure(settings ProviderSettings)
+}
+
+// priceStore holds the regional price data of one provider and the instance
+// type index built from it.
+type priceStore struct {
+	// provider is the name of the provider in the metrics
+	provider  string
+	refreshes *refreshTracker
+	// refreshers refresh the whole sources on demand, keyed by the source
+	refreshers map[string]func(ctx context.Context)
+	// initialSources are refreshed before the prices are served
+	initialSources []string
+	// unsupportedSources are the sources the provider can not serve, keyed by the source, the values are the
+	// reasons
+	unsupportedSources map[string]string
+
+	dataMutex sync.RWMutex
+	priceData map[string]*apis.RegionalInstancePrice
+	// instanceTypeName -> instanceInfo
+	instanceInfos map[string]*apis.InstanceInfo
+	instanceTypes []string
+
+	settingsMutex sync.RWMutex
+	settings      ProviderSettings
+	// defaultDenyRegions are denied when the settings do not specify the regions
+	defaultDenyRegions []string
+	// settingsUpdated notifies the Run loop to reset the tickers
+	settingsUpdated chan struct{}
+}
+
+func newPriceStore(provider string) priceStore {
+	return priceStore{
+		provider:        provider,
+		refreshes:       newRefreshTracker(),
+		priceData:       map[string]*apis.RegionalInstancePrice{},
+		instanceInfos:   map[string]*apis.InstanceInfo{},
+		instanceTypes:   []string{},
+		settingsUpdated: make(chan struct{}, 1),
+	}
+}
+
+// Configure applies the settings, the intervals take effect at once and the regions from the next refresh.
+func (s *priceStore) Configure(settings ProviderSettings) {
+	s.settingsMutex.Lock()
+	s.settings = settings
+	s.settingsMutex.Unlock()
+
+	select {
+	case s.settingsUpdated <- struct{}{}:
+	default:
+	}
+	s.refreshInstanceTypeMetadataAndAvailableRegion()
+}
+
+func (s *priceStore) currentSettings() ProviderSettings {
+	s.settingsMutex.RLock()
+	defer s.settingsMutex.RUnlock()
+	return s.settings
+}
+
+func (s *priceStore) onDemandInterval() time.Duration {
+	return durationOrDefault(s.currentSettings().OnDemandInterval, defaultOnDemandInterval)
+}
+
+func (s *priceStore) spotInterval() time.Duration {
+	return durationOrDefault(s.currentSettings().SpotInterval, defaultSpotInterval)
+}
+
+func (s *priceStore) metadataInterval() time.Duration {
+	return durationOrDefault(s.currentSettings().MetadataInterval, defaultMetadataInterval)
+}
+
+// concurrency returns the configured concurrency, or the default one of the call site.
+func (s *priceStore) concurrency(defaultConcurrency int) int {
+	if concurrency := s.currentSettings().Concurrency; concurrency > 0 {
+		return concurrency
+	}
+	return defaultConcurrency
+}
+
+func durationOrDefault(d, defaultDuration time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return defaultDuration
+}
+
+func (s *priceStore) regionAllowed(region string) bool {
+	settings := s.currentSettings()
+	denyRegions := settings.DenyRegions
+	if len(settings.AllowRegions) == 0 && len(denyRegions) == 0 {
+		denyRegions = s.defaultDenyRegions
+	}
+	for _, r := range denyRegions {
+		if r == region {
+			return false
+		}
+	}
+	if len(settings.AllowRegions) == 0 {
+		return true
+	}
+	for _, r := range settings.AllowRegions {
+		if r == region {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedRegions filters the regions to refresh by the settings.
+func (s *priceStore) allowedRegions(regions []string) []string {
+	ret := make([]string, 0, len(regions))
+	for _, region := range regions {
+		if s.regionAllowed(region) {
+			ret = append(ret, region)
+		}
+	}
+	return ret
+}
+
+// observeRefresh records the refresh of the source in the region, the region is empty for the refreshes not split
+// by region.
+func (s *priceStore) observeRefresh(region, source string, startTime time.Time, err error) {
+	metrics.ObserveRefresh(s.provider, region, source, startTime, err)
+	s.refreshes.record(region, source, startTime, err)
+}
+
+// requireRefresh marks the provider not ready until every source is refreshed successfully in any region.
+func (s *priceStore) requireRefresh(sources ...string) {
+	s.refreshes.require(sources...)
+}
+
+// RefreshStatus returns the last refreshes of the provider, the items are counted from the prices served now.
+func (s *priceStore) RefreshStatus() apis.ProviderRefreshStatus {
+	status := s.refreshes.status()
+	if len(s.unsupportedSources) != 0 {
+		status.UnsupportedSources = make(map[string]string, len(s.unsupportedSources))
+		for source, reason := range s.unsupportedSources {
+			status.UnsupportedSources[source] = reason
+		}
+	}
+	for i := range status.Refreshes {
+		status.Refreshes[i].Items = s.countItems(status.Refreshes[i].Region, status.Refreshes[i].Source)
+	}
+	return status
+}
+
+// countItems counts the instance types with the prices of the source in the region, or all the instance types
+// for the sources not limited to one kind of price.
+func (s *priceStore) countItems(region, source string) int {
+	s.dataMutex.RLock()
+	defer s.dataMutex.RUnlock()
+
+	if region == "" {
+		return len(s.instanceTypes)
+	}
+	regionData, ok := s.priceData[region]
+	if !ok {
+		return 0
+	}
+
+	count := 0
+	for _, price := range regionData.InstanceTypePrices {
+		switch source {
+		case apis.SourceOnDemand:
+			if price.OnDemandPricePerHour > 0 {
+				count++
+			}
+		case apis.SourceSpot:
+			if len(price.SpotPricePerHour) > 0 {
+				count++
+			}
+		case apis.SourceSavingsPlan:
+			if len(price.AWSEC2Billing) > 0 {
+				count++
+			}
+		default:
+			count++
+		}
+	}
+	return count
+}
+
+// observeRefreshAll records the refresh in all the regions served, like the refreshes not split by region or
+// failed before reaching any region.
+func (s *priceStore) observeRefreshAll(source string, startTime time.Time, err error) {
+	s.dataMutex.RLock()
+	regions := make([]string, 0, len(s.priceData))
+	for region := range s.priceData {
+		regions = append(regions, region)
+	}
+	s.dataMutex.RUnlock()
+
+	for _, region := range s.allowedRegions(regions) {
+		s.observeRefresh(region, source, startTime, err)
+	}
+}
+
+// observeAPICall records a call to the cloud api of the provider.
+func (s *priceStore) observeAPICall(api string, err error) {
+	metrics.ObserveCloudAPICall(s.provider, api, err)
+}
+
+func (s *priceStore) loadBuiltinData(fileName string) error {
+	data, err := file.ReadFile(path.Join("builtin-data", fileName))
+	if err != nil {
+		return err
+	}
+	return s.loadData(data)
+}
+
+func (s *priceStore) loadData(data []byte) error {
+	s.dataMutex.Lock()
+	defer s.dataMutex.Unlock()
+	return json.Unmarshal(data, &s.priceData)
+}
+
+// refresh the instanceTypeMetadata and instanceTypeAvailableRegion by priceData.
+func (s *priceStore) refreshInstanceTypeMetadataAndAvailableRegion() {
+	s.dataMutex.Lock()
+	defer s.dataMutex.Unlock()
+	s.instanceInfos = map[string]*apis.InstanceInfo{}
+	s.instanceTypes = []string{}
+
+	for region, data := range s.priceData {
+		if !s.regionAllowed(region) {
+			continue
+		}
+		for instanceType, priceData := range data.InstanceTypePrices {
+			if _, ok := s.instanceInfos[instanceType]; !ok {
+				s.instanceInfos[instanceType] = &apis.InstanceInfo{
+					InstanceTypeMetadata: priceData.InstanceTypeMetadata,
+					RegionsSet:           sets.Set[string]{},
+				}
+				s.instanceTypes = append(s.instanceTypes, instanceType)
+			}
+
+			s.instanceInfos[instanceType].RegionsSet.Insert(region)
+		}
+	}
+
+	for _, info := range s.instanceInfos {
+		info.Regions = info.RegionsSet.UnsortedList()
+	}
+}
+
+func (s *priceStore) ListRegionsInstancesPrice() map[string]*apis.RegionalInstancePrice {
+	s.dataMutex.RLock()
+	defer s.dataMutex.RUnlock()
+
+	ret := make(map[string]*apis.RegionalInstancePrice)
+	for k, v := range s.priceData {
+		if s.regionAllowed(k) {
+			ret[k] = v.DeepCopy()
+		}
+	}
+	return ret
+}
+
+func (s *priceStore) ListInstancesPrice(region string) *map[string]apis.RegionalInstancePrice {
+	s.dataMutex.RLock()
+	defer s.dataMutex.RUnlock()
+
+	d, ok := s.priceData[region]
+	if !ok || !s.regionAllowed(region) {
+		return nil
+	}
+	return &map[string]apis.RegionalInstancePrice{
+		region: *d.DeepCopy(),
+	}
+}
+
+func (s *priceStore) GetInstancePrice(region, instanceType string) *apis.InstanceTypePrice {
+	s.dataMutex.RLock()
+	defer s.dataMutex.RUnlock()
+
+	regionData, ok := s.priceData[region]
+	if !ok || !s.regionAllowed(region) {
+		return nil
+	}
+	d, ok := regionData.InstanceTypePrices[instanceType]
+	if !ok {
+		return nil
+	}
+
+	return d
+}
+
+// InstanceTypeCounts returns the number of the instance types served in each region.
+func (s *priceStore) InstanceTypeCounts() map[string]int {
+	s.dataMutex.RLock()
+	defer s.dataMutex.RUnlock()
+
+	ret := make(map[string]int, len(s.priceData))
+	for region, data := range s.priceData {
+		if s.regionAllowed(region) {
+			ret[region] = len(data.InstanceTypePrices)
+		}
+	}
+	return ret
+}
+
+func (s *priceStore) ListInstanceTypes() []string {
+	s.dataMutex.RLock()
+	defer s.dataMutex.RUnlock()
+
+	return s.instanceTypes
+}
+
+func (s *priceStore) GetInstanceInfo(instanceType string) *apis.InstanceInfo {
+	s.dataMutex.RLock()
+	defer s.dataMutex.RUnlock()
+
+	return s.instanceInfos[instanceType]
+}
@@ -0,0 +1,299 @@
+package client
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/profile"
+	cvm "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/cvm/v20170312"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+)
+
+const (
+	tencentCloudChargeTypeOnDemand = "POSTPAID_BY_HOUR"
+	tencentCloudChargeTypeSpot     = "SPOTPAID"
+)
+
+type TencentCloudPriceClient struct {
+	akskPool []AKSKPair
+	// endpoint overrides the cvm endpoint of the sdk, like cvm.tencentcloudapi.com or http://127.0.0.1:8080
+	endpoint string
+
+	// regions are discovered by the first refresh
+	regions *lazyRegions
+
+	priceStore
+}
+
+var _ Provider = &TencentCloudPriceClient{}
+
+func NewTencentCloudPriceClient(ctx context.Context, akskPool []AKSKPair, endpoint string,
+	initialSpotUpdate bool) (*TencentCloudPriceClient, error) {
+	client := &TencentCloudPriceClient{
+		akskPool:   akskPool,
+		endpoint:   endpoint,
+		priceStore: newPriceStore(apis.TencentCloudProviderName),
+	}
+	if err := client.loadBuiltinData("tencentcloud_price.json"); err != nil {
+		return nil, err
+	}
+
+	client.regions = newLazyRegions(client.listRegions)
+	// The followers never refresh, so they never discover the regions, the others fail fast on the invalid
+	// credentials
+	if initialSpotUpdate {
+		if _, err := client.regions.get(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	client.setRefreshers(map[string]func(ctx context.Context){
+		apis.SourceOnDemand: client.RefreshOnDemandPrice,
+		apis.SourceSpot:     client.refreshSpotPrice,
+		apis.SourceMetadata: client.refreshMetadata,
+	})
+
+	client.setInitialSources(apis.SourceSpot)
+	if initialSpotUpdate {
+		client.RefreshInitialSources(ctx)
+	}
+
+	client.refreshInstanceTypeMetadataAndAvailableRegion()
+	return client, nil
+}
+
+func (t *TencentCloudPriceClient) Run(ctx context.Context) {
+	odTicker := time.NewTicker(t.onDemandInterval())
+	defer odTicker.Stop()
+
+	spotTicker := time.NewTicker(t.spotInterval())
+	defer spotTicker.Stop()
+
+	for {
+		select {
+		case <-odTicker.C:
+			t.RefreshOnDemandPrice(ctx)
+		case <-spotTicker.C:
+			t.refreshSpotPrice(ctx)
+		case <-t.settingsUpdated:
+			odTicker.Reset(t.onDemandInterval())
+			spotTicker.Reset(t.spotInterval())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (t *TencentCloudPriceClient) createCVMClient(region string) (*cvm.Client, error) {
+	// Take one ak/sk from pool
+	pick := rand.Intn(len(t.akskPool))
+	credential := common.NewCredential(t.akskPool[pick].AK, t.akskPool[pick].SK)
+
+	cpf := profile.NewClientProfile()
+	if t.endpoint != "" {
+		endpoint := t.endpoint
+		if strings.HasPrefix(endpoint, "http://") {
+			cpf.HttpProfile.Scheme = "HTTP"
+		}
+		endpoint = strings.TrimPrefix(endpoint, "http://")
+		cpf.HttpProfile.Endpoint = strings.TrimPrefix(endpoint, "https://")
+	}
+
+	client, err := cvm.NewClient(credential, region, cpf)
+	if err != nil {
+		klog.Errorf("Failed to create cvm client:%v", err)
+		return nil, err
+	}
+	return client, nil
+}
+
+func (t *TencentCloudPriceClient) listRegions(ctx context.Context) ([]string, error) {
+	// We use ap-guangzhou as the default region to list regions
+	client, err := t.createCVMClient("ap-guangzhou")
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.DescribeRegionsWithContext(ctx, cvm.NewDescribeRegionsRequest())
+	t.observeAPICall("DescribeRegions", err)
+	if err != nil {
+		klog.Errorf("Failed to list regions:%v", err)
+		return nil, err
+	}
+
+	var regions []string
+	for _, regionData := range resp.Response.RegionSet {
+		if lo.FromPtr(regionData.RegionState) != "AVAILABLE" {
+			continue
+		}
+		regions = append(regions, *regionData.Region)
+	}
+
+	return regions, nil
+}
+
+func extractCVMArch(instanceFamily, cpuType string) string {
+	// SR1 is powered by Ampere Altra
+	if strings.HasPrefix(instanceFamily, "SR") || strings.Contains(cpuType, "Ampere") {
+		return "arm64"
+	}
+	return "amd64"
+}
+
+// listZoneInstanceConfigs returns the instance types on sale in the region with the metadata and the hourly price
+// of every zone for the charge type.
+func (t *TencentCloudPriceClient) listZoneInstanceConfigs(ctx context.Context, region, chargeType string) (map[string]*apis.InstanceTypePrice,
+	map[string]map[string]float64, error) {
+	client, err := t.createCVMClient(region)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req := cvm.NewDescribeZoneInstanceConfigInfosRequest()
+	req.Filters = []*cvm.Filter{
+		{
+			Name:   common.StringPtr("instance-charge-type"),
+			Values: common.StringPtrs([]string{chargeType}),
+		},
+	}
+	resp, err := client.DescribeZoneInstanceConfigInfosWithContext(ctx, req)
+	t.observeAPICall("DescribeZoneInstanceConfigInfos", err)
+	if err != nil {
+		klog.Errorf("Failed to list %s instance configs in region %s:%v", chargeType, region, err)
+		return nil, nil, err
+	}
+
+	instanceTypes := map[string]*apis.InstanceTypePrice{}
+	zones := map[string]sets.Set[string]{}
+	prices := map[string]map[string]float64{}
+	for _, item := range resp.Response.InstanceTypeQuotaSet {
+		if item.InstanceType == nil || item.Zone == nil || item.Price == nil ||
+			lo.FromPtr(item.Status) != "SELL" {
+			continue
+		}
+		instanceType := *item.InstanceType
+		price := lo.FromPtr(item.Price.UnitPrice)
+		// the discounted price is the actual price paid for spot instances
+		if chargeType == tencentCloudChargeTypeSpot && lo.FromPtr(item.Price.UnitPriceDiscount) > 0 {
+			price = lo.FromPtr(item.Price.UnitPriceDiscount)
+		}
+		if price == 0 {
+			continue
+		}
+
+		if _, ok := instanceTypes[instanceType]; !ok {
+			family := lo.FromPtr(item.InstanceFamily)
+			instanceTypes[instanceType] = &apis.InstanceTypePrice{
+				InstanceTypeMetadata: apis.InstanceTypeMetadata{
+					Arch:           extractCVMArch(family, lo.FromPtr(item.CpuType)),
+					VCPU:           float64(lo.FromPtr(item.Cpu)),
+					Memory:         float64(lo.FromPtr(item.Memory)),
+					GPU:            float64(lo.FromPtr(item.Gpu)),
+					InstanceFamily: family,
+				},
+			}
+			zones[instanceType] = sets.Set[string]{}
+			prices[instanceType] = map[string]float64{}
+		}
+		zones[instanceType].Insert(*item.Zone)
+		prices[instanceType][*item.Zone] = price
+	}
+
+	for instanceType, ins := range instanceTypes {
+		ins.Zones = zones[instanceType].UnsortedList()
+	}
+
+	return instanceTypes, prices, nil
+}
+
+func (t *TencentCloudPriceClient) RefreshOnDemandPrice(ctx context.Context) {
+	regions, ok := t.allowedRegionsOf(ctx, t.regions, apis.SourceOnDemand)
+	if !ok {
+		return
+	}
+	workqueue.ParallelizeUntil(ctx, t.concurrency(50), len(regions), func(i int) {
+		region := regions[i]
+		klog.Infof("Start to handle region %s for on-demand", region)
+
+		startTime := time.Now()
+		instanceTypes, prices, err := t.listZoneInstanceConfigs(ctx, region, tencentCloudChargeTypeOnDemand)
+		t.observeRefresh(region, apis.SourceOnDemand, startTime, err)
+		if err != nil {
+			return
+		}
+		if len(instanceTypes) == 0 {
+			return
+		}
+
+		t.dataMutex.Lock()
+		defer t.dataMutex.Unlock()
+		for instanceType, ins := range instanceTypes {
+			// the price of an instance type is the same across the zones, take the lowest one in case it is not
+			for _, price := range prices[instanceType] {
+				if ins.OnDemandPricePerHour == 0 || price < ins.OnDemandPricePerHour {
+					ins.OnDemandPricePerHour = price
+				}
+			}
+
+			if regionData, ok := t.priceData[region]; ok {
+				if old, ok := regionData.InstanceTypePrices[instanceType]; ok {
+					ins.SpotPricePerHour = old.SpotPricePerHour
+				}
+			}
+		}
+		t.priceData[region] = &apis.RegionalInstancePrice{InstanceTypePrices: instanceTypes}
+	})
+
+	t.refreshInstanceTypeMetadataAndAvailableRegion()
+	klog.Infof("All on-demand prices are refreshed for TencentCloud")
+}
+
+func (t *TencentCloudPriceClient) refreshSpotPrice(ctx context.Context) {
+	t.dataMutex.RLock()
+	empty := len(t.priceData) == 0
+	t.dataMutex.RUnlock()
+	// There is no on-demand data to attach the spot prices to, do a full refresh
+	if empty {
+		t.RefreshOnDemandPrice(ctx)
+	}
+
+	regions, ok := t.allowedRegionsOf(ctx, t.regions, apis.SourceSpot)
+	if !ok {
+		return
+	}
+	workqueue.ParallelizeUntil(ctx, t.concurrency(50), len(regions), func(i int) {
+		region := regions[i]
+		klog.Infof("Start to handle region %s", region)
+
+		startTime := time.Now()
+		_, prices, err := t.listZoneInstanceConfigs(ctx, region, tencentCloudChargeTypeSpot)
+		t.observeRefresh(region, apis.SourceSpot, startTime, err)
+		if err != nil {
+			return
+		}
+
+		t.dataMutex.Lock()
+		defer t.dataMutex.Unlock()
+		regionData, ok := t.priceData[region]
+		if !ok {
+			return
+		}
+		for instanceType, zonePrices := range prices {
+			ins, ok := regionData.InstanceTypePrices[instanceType]
+			if !ok {
+				continue
+			}
+			ins.SpotPricePerHour = zonePrices
+		}
+	})
+
+	klog.Infof("All spot prices are refreshed for TencentCloud")
+}
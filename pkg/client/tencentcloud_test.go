@@ -0,0 +1,143 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"testing"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+)
+
+// routeTencentCloud maps the cvm calls to the recorded responses by the action, the region and the charge type of
+// the instance configs.
+func routeTencentCloud(r *http.Request) string {
+	action := r.Header.Get("X-TC-Action")
+	if action != "DescribeZoneInstanceConfigInfos" {
+		return action + ".json"
+	}
+
+	var req struct {
+		Filters []struct {
+			Name   string   `json:"Name"`
+			Values []string `json:"Values"`
+		} `json:"Filters"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Filters) != 1 ||
+		req.Filters[0].Name != "instance-charge-type" || len(req.Filters[0].Values) != 1 {
+		return ""
+	}
+	return fmt.Sprintf("%s-%s-%s.json", action, r.Header.Get("X-TC-Region"), req.Filters[0].Values[0])
+}
+
+func TestTencentCloudListZoneInstanceConfigs(t *testing.T) {
+	server := newRecordedServer(t, "tencentcloud", routeTencentCloud)
+	c, err := NewTencentCloudPriceClient(context.Background(), []AKSKPair{{AK: "ak", SK: "sk"}}, server.URL, false)
+	if err != nil {
+		t.Fatalf("Failed to create the tencent cloud client: %v", err)
+	}
+	// The unavailable regions are not listed
+	regions, err := c.regions.get(context.Background())
+	if err != nil || fmt.Sprint(regions) != "[ap-guangzhou ap-shanghai]" {
+		t.Errorf("Expected the regions ap-guangzhou and ap-shanghai, got %v: %v", regions, err)
+	}
+
+	for _, tc := range []struct {
+		chargeType string
+		// the prices of the instance types by zone, the sold out and the unpriced ones are skipped
+		expected map[string]map[string]float64
+	}{
+		{
+			chargeType: tencentCloudChargeTypeOnDemand,
+			expected: map[string]map[string]float64{
+				"S5.MEDIUM4":  {"ap-guangzhou-3": 0.32, "ap-guangzhou-4": 0.3},
+				"SR1.MEDIUM4": {"ap-guangzhou-6": 0.27},
+			},
+		},
+		{
+			// the discounted prices are paid for the spot instances
+			chargeType: tencentCloudChargeTypeSpot,
+			expected: map[string]map[string]float64{
+				"S5.MEDIUM4": {"ap-guangzhou-3": 0.064, "ap-guangzhou-4": 0.06},
+				"S5.LARGE8":  {"ap-guangzhou-3": 0.128},
+			},
+		},
+	} {
+		t.Run(tc.chargeType, func(t *testing.T) {
+			instanceTypes, prices, err := c.listZoneInstanceConfigs(context.Background(), "ap-guangzhou",
+				tc.chargeType)
+			if err != nil {
+				t.Fatalf("Failed to list the instance configs: %v", err)
+			}
+			if fmt.Sprint(prices) != fmt.Sprint(tc.expected) {
+				t.Errorf("Expected the prices %v, got %v", tc.expected, prices)
+			}
+			for name, zonePrices := range tc.expected {
+				zones := instanceTypes[name].Zones
+				sort.Strings(zones)
+				if len(zones) != len(zonePrices) {
+					t.Errorf("Expected the zones of %s to match the prices %v, got %v", name, zonePrices, zones)
+				}
+			}
+		})
+	}
+
+	if sr1 := c.GetInstancePrice("ap-guangzhou", "SR1.MEDIUM4"); sr1 != nil {
+		t.Errorf("Expected nothing stored by listing the instance configs, got %+v", sr1)
+	}
+}
+
+// TestTencentCloudNoCallsWithoutInitialUpdate covers the followers, they never call the cloud api until they
+// refresh.
+func TestTencentCloudNoCallsWithoutInitialUpdate(t *testing.T) {
+	server := newRecordedServer(t, "tencentcloud", func(r *http.Request) string { return "" })
+	if _, err := NewTencentCloudPriceClient(context.Background(), []AKSKPair{{AK: "ak", SK: "sk"}}, server.URL,
+		false); err != nil {
+		t.Fatalf("Failed to create the tencent cloud client: %v", err)
+	}
+}
+
+// TestTencentCloudRefreshSpotPriceWithoutData covers the first spot refresh of a client without the builtin data,
+// the on-demand prices are refreshed first and the spot prices are attached to them.
+func TestTencentCloudRefreshSpotPriceWithoutData(t *testing.T) {
+	server := newRecordedServer(t, "tencentcloud", routeTencentCloud)
+	c, err := NewTencentCloudPriceClient(context.Background(), []AKSKPair{{AK: "ak", SK: "sk"}}, server.URL, false)
+	if err != nil {
+		t.Fatalf("Failed to create the tencent cloud client: %v", err)
+	}
+	dropBuiltinData(&c.priceStore)
+	c.requireRefresh(apis.SourceSpot)
+	c.refreshSpotPrice(context.Background())
+
+	// ap-shanghai has no instance type on sale
+	if regions := c.ListRegionsInstancesPrice(); len(regions) != 1 || regions["ap-guangzhou"] == nil {
+		t.Fatalf("Expected the prices of ap-guangzhou only, got %v", regions)
+	}
+	s5 := c.GetInstancePrice("ap-guangzhou", "S5.MEDIUM4")
+	if s5 == nil || s5.OnDemandPricePerHour != 0.3 || s5.Arch != "amd64" || s5.InstanceFamily != "S5" {
+		t.Fatalf("Expected the lowest on-demand price 0.3 of S5.MEDIUM4 across the zones, got %+v", s5)
+	}
+	if s5.SpotPricePerHour["ap-guangzhou-4"] != 0.06 {
+		t.Errorf("Expected the spot prices of S5.MEDIUM4 attached, got %v", s5.SpotPricePerHour)
+	}
+	if sr1 := c.GetInstancePrice("ap-guangzhou", "SR1.MEDIUM4"); sr1 == nil || sr1.Arch != "arm64" ||
+		len(sr1.SpotPricePerHour) != 0 {
+		t.Errorf("Expected SR1.MEDIUM4 on arm64 without spot prices, got %+v", sr1)
+	}
+	// S5.LARGE8 has no on-demand price to attach the spot prices to
+	if ins := c.GetInstancePrice("ap-guangzhou", "S5.LARGE8"); ins != nil {
+		t.Errorf("Expected no price of S5.LARGE8, got %+v", ins)
+	}
+
+	status := c.RefreshStatus()
+	if !status.Ready {
+		t.Errorf("Expected the spot source refreshed, got %+v", status)
+	}
+	for _, refresh := range status.Refreshes {
+		if refresh.LastError != "" {
+			t.Errorf("Expected no failed refresh, got %+v", refresh)
+		}
+	}
+}
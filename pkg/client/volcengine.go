@@ -0,0 +1,445 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/volcengine/volcengine-go-sdk/service/billing"
+	"github.com/volcengine/volcengine-go-sdk/service/ecs"
+	"github.com/volcengine/volcengine-go-sdk/volcengine"
+	"github.com/volcengine/volcengine-go-sdk/volcengine/credentials"
+	"github.com/volcengine/volcengine-go-sdk/volcengine/session"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+)
+
+const (
+	volcengineECSProduct = "ECS"
+	// the max config count of one pay-as-you-go price request
+	volcenginePriceBatchSize = 50
+)
+
+type VolcenginePriceClient struct {
+	akskPool []AKSKPair
+
+	// regions are discovered by the first refresh
+	regions *lazyRegions
+
+	priceStore
+}
+
+var _ Provider = &VolcenginePriceClient{}
+
+func NewVolcenginePriceClient(ctx context.Context, akskPool []AKSKPair,
+	initialSpotUpdate bool) (*VolcenginePriceClient, error) {
+	client := &VolcenginePriceClient{
+		akskPool:   akskPool,
+		priceStore: newPriceStore(apis.VolcengineProviderName),
+	}
+	if err := client.loadBuiltinData("volcengine_price.json"); err != nil {
+		return nil, err
+	}
+
+	client.regions = newLazyRegions(client.listRegions)
+	// The followers never refresh, so they never discover the regions, the others fail fast on the invalid
+	// credentials
+	if initialSpotUpdate {
+		if _, err := client.regions.get(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	client.setRefreshers(map[string]func(ctx context.Context){
+		apis.SourceOnDemand: client.RefreshOnDemandPrice,
+		apis.SourceSpot:     client.refreshSpotPrice,
+		apis.SourceMetadata: client.refreshMetadata,
+	})
+
+	client.setInitialSources(apis.SourceSpot)
+	if initialSpotUpdate {
+		client.RefreshInitialSources(ctx)
+	}
+
+	client.refreshInstanceTypeMetadataAndAvailableRegion()
+	return client, nil
+}
+
+func (v *VolcenginePriceClient) Run(ctx context.Context) {
+	odTicker := time.NewTicker(v.onDemandInterval())
+	defer odTicker.Stop()
+
+	spotTicker := time.NewTicker(v.spotInterval())
+	defer spotTicker.Stop()
+
+	for {
+		select {
+		case <-odTicker.C:
+			v.RefreshOnDemandPrice(ctx)
+		case <-spotTicker.C:
+			v.refreshSpotPrice(ctx)
+		case <-v.settingsUpdated:
+			odTicker.Reset(v.onDemandInterval())
+			spotTicker.Reset(v.spotInterval())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (v *VolcenginePriceClient) createSession(region string) (*session.Session, error) {
+	// Take one ak/sk from pool
+	pick := rand.Intn(len(v.akskPool))
+	config := volcengine.NewConfig().
+		WithRegion(region).
+		WithCredentials(credentials.NewStaticCredentials(v.akskPool[pick].AK, v.akskPool[pick].SK, ""))
+	sess, err := session.NewSession(config)
+	if err != nil {
+		klog.Errorf("Failed to create volcengine session:%v", err)
+		return nil, err
+	}
+	return sess, nil
+}
+
+func (v *VolcenginePriceClient) createECSClient(region string) (*ecs.ECS, error) {
+	sess, err := v.createSession(region)
+	if err != nil {
+		return nil, err
+	}
+	return ecs.New(sess), nil
+}
+
+func (v *VolcenginePriceClient) listRegions(ctx context.Context) ([]string, error) {
+	// We use cn-beijing as the default region to list regions
+	client, err := v.createECSClient("cn-beijing")
+	if err != nil {
+		return nil, err
+	}
+
+	input := &ecs.DescribeRegionsInput{MaxResults: volcengine.Int32(100)}
+	var regions []string
+	for {
+		resp, err := client.DescribeRegionsWithContext(ctx, input)
+		v.observeAPICall("DescribeRegions", err)
+		if err != nil {
+			klog.Errorf("Failed to list regions:%v", err)
+			return nil, err
+		}
+		for _, regionData := range resp.Regions {
+			regions = append(regions, volcengine.StringValue(regionData.RegionId))
+		}
+
+		if volcengine.StringValue(resp.NextToken) == "" {
+			break
+		}
+		input.NextToken = resp.NextToken
+	}
+
+	return regions, nil
+}
+
+func extractVolcengineArch(processorModel string) string {
+	for _, armProcessor := range []string{"Ampere", "Kunpeng", "Yitian"} {
+		if strings.Contains(processorModel, armProcessor) {
+			return "arm64"
+		}
+	}
+	return "amd64"
+}
+
+// listInstanceTypes returns the pay-as-you-go instance types available in the region with the metadata and zones.
+func (v *VolcenginePriceClient) listInstanceTypes(ctx context.Context, region string) (map[string]*apis.InstanceTypePrice, error) {
+	client, err := v.createECSClient(region)
+	if err != nil {
+		return nil, err
+	}
+
+	availableResp, err := client.DescribeAvailableResourceWithContext(ctx, &ecs.DescribeAvailableResourceInput{
+		DestinationResource: volcengine.String("InstanceType"),
+		InstanceChargeType:  volcengine.String("PostPaid"),
+	})
+	v.observeAPICall("DescribeAvailableResource", err)
+	if err != nil {
+		klog.Errorf("Failed to list available instance types in region %s:%v", region, err)
+		return nil, err
+	}
+	if len(availableResp.AvailableZones) == 0 {
+		klog.Errorf("Failed to get available instance types data")
+		return nil, fmt.Errorf("failed to get available instance types data")
+	}
+
+	availableTypesZone := map[string]sets.Set[string]{}
+	for _, zoneData := range availableResp.AvailableZones {
+		if volcengine.StringValue(zoneData.Status) != "Available" {
+			continue
+		}
+		for _, resource := range zoneData.AvailableResources {
+			for _, it := range resource.SupportedResources {
+				if volcengine.StringValue(it.Status) != "Available" {
+					continue
+				}
+				instanceType := volcengine.StringValue(it.Value)
+				if _, ok := availableTypesZone[instanceType]; !ok {
+					availableTypesZone[instanceType] = sets.Set[string]{}
+				}
+				availableTypesZone[instanceType].Insert(volcengine.StringValue(zoneData.ZoneId))
+			}
+		}
+	}
+
+	ret := map[string]*apis.InstanceTypePrice{}
+	input := &ecs.DescribeInstanceTypesInput{MaxResults: volcengine.Int32(100)}
+	for {
+		typesResp, err := client.DescribeInstanceTypesWithContext(ctx, input)
+		v.observeAPICall("DescribeInstanceTypes", err)
+		if err != nil {
+			klog.Errorf("Failed to list instance types in region %s:%v", region, err)
+			return nil, err
+		}
+
+		for _, item := range typesResp.InstanceTypes {
+			instanceType := volcengine.StringValue(item.InstanceTypeId)
+			zones, ok := availableTypesZone[instanceType]
+			if !ok {
+				continue
+			}
+
+			var vcpu, memory, gpu float64
+			arch := "amd64"
+			if item.Processor != nil {
+				vcpu = float64(volcengine.Int32Value(item.Processor.Cpus))
+				arch = extractVolcengineArch(volcengine.StringValue(item.Processor.Model))
+			}
+			if item.Memory != nil {
+				// the memory size is in MiB
+				memory = float64(volcengine.Int32Value(item.Memory.Size)) / 1024
+			}
+			if item.Gpu != nil {
+				for _, device := range item.Gpu.GpuDevices {
+					gpu += float64(volcengine.Int32Value(device.Count))
+				}
+			}
+
+			ret[instanceType] = &apis.InstanceTypePrice{
+				InstanceTypeMetadata: apis.InstanceTypeMetadata{
+					Arch:           arch,
+					VCPU:           vcpu,
+					Memory:         memory,
+					GPU:            gpu,
+					InstanceFamily: volcengine.StringValue(item.InstanceTypeFamily),
+				},
+				Zones: zones.UnsortedList(),
+			}
+		}
+
+		if volcengine.StringValue(typesResp.NextToken) == "" {
+			break
+		}
+		input.NextToken = typesResp.NextToken
+	}
+
+	return ret, nil
+}
+
+// listOnDemandPrices returns the hourly list price of the instance types in the region.
+func (v *VolcenginePriceClient) listOnDemandPrices(ctx context.Context, region string, instanceTypes []string) (map[string]float64, error) {
+	sess, err := v.createSession(region)
+	if err != nil {
+		return nil, err
+	}
+	client := billing.New(sess)
+
+	ret := map[string]float64{}
+	for _, batch := range lo.Chunk(instanceTypes, volcenginePriceBatchSize) {
+		configList := lo.Map(batch, func(instanceType string, _ int) *billing.ConfigListForQueryPriceForPayAsYouGoInput {
+			return &billing.ConfigListForQueryPriceForPayAsYouGoInput{
+				ConfigurationCode: volcengine.String(instanceType),
+				Quantity:          volcengine.Int32(1),
+				UseDuration:       volcengine.Int32(1),
+			}
+		})
+
+		resp, err := client.QueryPriceForPayAsYouGoWithContext(ctx, &billing.QueryPriceForPayAsYouGoInput{
+			Product:    volcengine.String(volcengineECSProduct),
+			ConfigList: configList,
+		})
+		v.observeAPICall("QueryPriceForPayAsYouGo", err)
+		if err != nil {
+			klog.Errorf("Failed to query pay-as-you-go price in region %s:%v", region, err)
+			return nil, err
+		}
+
+		for _, item := range resp.ConfigList {
+			price, err := strconv.ParseFloat(volcengine.StringValue(item.OriginalAmount), 64)
+			if err != nil {
+				klog.Warningf("Failed to parse price %s of instance type %s:%v",
+					volcengine.StringValue(item.OriginalAmount), volcengine.StringValue(item.ConfigurationCode), err)
+				continue
+			}
+			ret[volcengine.StringValue(item.ConfigurationCode)] = price
+		}
+	}
+
+	return ret, nil
+}
+
+func (v *VolcenginePriceClient) RefreshOnDemandPrice(ctx context.Context) {
+	regions, ok := v.allowedRegionsOf(ctx, v.regions, apis.SourceOnDemand)
+	if !ok {
+		return
+	}
+	workqueue.ParallelizeUntil(ctx, v.concurrency(10), len(regions), func(i int) {
+		region := regions[i]
+		klog.Infof("Start to handle region %s for on-demand", region)
+
+		startTime := time.Now()
+		instanceTypes, err := v.listInstanceTypes(ctx, region)
+		if err != nil {
+			v.observeRefresh(region, apis.SourceOnDemand, startTime, err)
+			return
+		}
+
+		prices, err := v.listOnDemandPrices(ctx, region, lo.Keys(instanceTypes))
+		v.observeRefresh(region, apis.SourceOnDemand, startTime, err)
+		if err != nil {
+			return
+		}
+
+		for instanceType := range instanceTypes {
+			// the instance types not on sale by the hour have no price
+			if prices[instanceType] == 0 {
+				delete(instanceTypes, instanceType)
+			}
+		}
+		if len(instanceTypes) == 0 {
+			return
+		}
+
+		v.dataMutex.Lock()
+		defer v.dataMutex.Unlock()
+		for instanceType, ins := range instanceTypes {
+			ins.OnDemandPricePerHour = prices[instanceType]
+			if regionData, ok := v.priceData[region]; ok {
+				if old, ok := regionData.InstanceTypePrices[instanceType]; ok {
+					ins.SpotPricePerHour = old.SpotPricePerHour
+				}
+			}
+		}
+		v.priceData[region] = &apis.RegionalInstancePrice{InstanceTypePrices: instanceTypes}
+	})
+
+	v.refreshInstanceTypeMetadataAndAvailableRegion()
+	klog.Infof("All on-demand prices are refreshed for Volcengine")
+}
+
+// getVolcengineSpotPrice returns the latest spot price of every zone in the last hour.
+func getVolcengineSpotPrice(ctx context.Context, client *ecs.ECS, region, instanceType string) (map[string]float64, error) {
+	input := &ecs.DescribeSpotPriceHistoryInput{
+		InstanceTypeId: volcengine.String(instanceType),
+		TimestampStart: volcengine.String(time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)),
+		MaxResults:     volcengine.Int32(100),
+	}
+
+	ret := map[string]float64{}
+	latest := map[string]string{}
+	for {
+		resp, err := client.DescribeSpotPriceHistoryWithContext(ctx, input)
+		if err != nil {
+			klog.Errorf("Failed to get spot price of instance %s in region %s:%v", instanceType, region, err)
+			return nil, err
+		}
+		for _, spotPrice := range resp.SpotPrices {
+			zone, timestamp := volcengine.StringValue(spotPrice.ZoneId), volcengine.StringValue(spotPrice.Timestamp)
+			// the timestamps are in the same RFC3339 format, compare them as strings
+			if timestamp < latest[zone] {
+				continue
+			}
+			latest[zone] = timestamp
+			ret[zone] = volcengine.Float64Value(spotPrice.SpotPrice)
+		}
+
+		if volcengine.StringValue(resp.NextToken) == "" {
+			break
+		}
+		input.NextToken = resp.NextToken
+	}
+
+	if len(ret) == 0 {
+		klog.Warningf("No spot price available for instance %s in region %s", instanceType, region)
+		return nil, nil
+	}
+	return ret, nil
+}
+
+func (v *VolcenginePriceClient) refreshSpotPrice(ctx context.Context) {
+	v.dataMutex.RLock()
+	empty := len(v.priceData) == 0
+	v.dataMutex.RUnlock()
+	// There is no on-demand data to attach the spot prices to, do a full refresh
+	if empty {
+		v.RefreshOnDemandPrice(ctx)
+	}
+
+	var regionTypes []apis.RegionTypeKey
+	v.dataMutex.RLock()
+	for region, regionData := range v.priceData {
+		for instanceType := range regionData.InstanceTypePrices {
+			regionTypes = append(regionTypes, apis.RegionTypeKey{Region: region, InstanceType: instanceType})
+		}
+	}
+	v.dataMutex.RUnlock()
+
+	startTime := time.Now()
+	spotPrices := make([]map[string]float64, len(regionTypes))
+	spotErrs := make([]error, len(regionTypes))
+	workqueue.ParallelizeUntil(ctx, v.concurrency(50), len(regionTypes), func(i int) {
+		client, err := v.createECSClient(regionTypes[i].Region)
+		if err != nil {
+			spotErrs[i] = err
+			return
+		}
+
+		spotPrice, err := getVolcengineSpotPrice(ctx, client, regionTypes[i].Region, regionTypes[i].InstanceType)
+		v.observeAPICall("DescribeSpotPriceHistory", err)
+		if err != nil {
+			spotErrs[i] = err
+			return
+		}
+		spotPrices[i] = spotPrice
+	})
+
+	// A region fails when any of its spot prices fails
+	regionErrs := map[string]error{}
+	for i, key := range regionTypes {
+		if _, ok := regionErrs[key.Region]; !ok || spotErrs[i] != nil {
+			regionErrs[key.Region] = spotErrs[i]
+		}
+	}
+	for region, err := range regionErrs {
+		v.observeRefresh(region, apis.SourceSpot, startTime, err)
+	}
+
+	v.dataMutex.Lock()
+	for i, key := range regionTypes {
+		if spotPrices[i] == nil {
+			continue
+		}
+		regionData, ok := v.priceData[key.Region]
+		if !ok {
+			continue
+		}
+		if ins, ok := regionData.InstanceTypePrices[key.InstanceType]; ok {
+			ins.SpotPricePerHour = spotPrices[i]
+		}
+	}
+	v.dataMutex.Unlock()
+
+	klog.Infof("All spot prices are refreshed for Volcengine")
+}
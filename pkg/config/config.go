@@ -0,0 +1,466 @@
+// Package config contains the versioned config file of priceserver.
+package config
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+	"github.com/cloudpilot-ai/priceserver/pkg/client"
+	"github.com/cloudpilot-ai/priceserver/pkg/scheduler"
+)
+
+const (
+	APIVersion = "priceserver.cloudpilot.ai/v1alpha1"
+	Kind       = "PriceServerConfig"
+
+	DefaultListenAddress = ":8080"
+
+	maxConcurrency = 1000
+
+	// The default staleness are a few refresh intervals of the providers, so a failed refresh or two do not
+	// pull the replicas out
+	defaultSpotStaleness        = time.Hour * 6
+	defaultOnDemandStaleness    = time.Hour * 24 * 21
+	defaultSavingsPlanStaleness = time.Hour * 24 * 21
+	defaultMetadataStaleness    = time.Hour * 24 * 3
+	defaultPluginStaleness      = time.Hour * 6
+	defaultSnapshotStaleness    = time.Minute * 10
+
+	// The default lease timings are the ones of the kubernetes controllers
+	defaultLeaseName                = "priceserver"
+	defaultLeaseDuration            = time.Second * 15
+	defaultRenewDeadline            = time.Second * 10
+	defaultRetryPeriod              = time.Second * 2
+	defaultSnapshotPrefix           = "priceserver-snapshot"
+	defaultSnapshotPublishInterval  = time.Minute
+	defaultSnapshotSyncInterval     = time.Second * 30
+	leaderElectionRetryJitterFactor = 1.2
+)
+
+// scheduledSources are the sources refreshed by the jobs of the scheduler
+var scheduledSources = sets.New(apis.SourceOnDemand, apis.SourceSpot, apis.SourceSavingsPlan, apis.SourceMetadata)
+
+// Config is the config file of priceserver. The intervals, the regions, the concurrency and the schedules of the
+// providers, the cors settings, the api keys and the health settings are applied on reload, the other fields take
+// effect after restarting.
+type Config struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+
+	Server         ServerConfig         `json:"server,omitempty"`
+	Auth           AuthConfig           `json:"auth,omitempty"`
+	Health         HealthConfig         `json:"health,omitempty"`
+	LeaderElection LeaderElectionConfig `json:"leaderElection,omitempty"`
+	// Providers are keyed by the name of the built-in providers or the plugins
+	Providers map[string]ProviderConfig `json:"providers,omitempty"`
+}
+
+type ServerConfig struct {
+	ListenAddress string `json:"listenAddress,omitempty"`
+	// HealthListenAddress is optional, the health checks are also served on it over plain http, so the probes
+	// keep working when tls is enabled
+	HealthListenAddress string     `json:"healthListenAddress,omitempty"`
+	TLS                 TLSConfig  `json:"tls,omitempty"`
+	CORS                CORSConfig `json:"cors,omitempty"`
+}
+
+// TLSConfig serves https when both the files are set, the files are reloaded when they change.
+type TLSConfig struct {
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+	// ClientCAFile is optional, the client certificates are required and verified against it when it is set
+	ClientCAFile string `json:"clientCAFile,omitempty"`
+}
+
+// Enabled returns whether https is served.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" && c.KeyFile != ""
+}
+
+type CORSConfig struct {
+	AllowOrigins []string `json:"allowOrigins,omitempty"`
+	AllowHeaders []string `json:"allowHeaders,omitempty"`
+}
+
+// GinConfig converts the config to the config of the cors middleware.
+func (c CORSConfig) GinConfig() cors.Config {
+	config := cors.DefaultConfig()
+	config.AllowOrigins = c.AllowOrigins
+	config.AllowHeaders = c.AllowHeaders
+	return config
+}
+
+// AuthConfig requires an api key for the price and the admin apis when any key is configured, the health checks
+// and the metrics are always served without a key.
+type AuthConfig struct {
+	APIKeys []APIKeyConfig `json:"apiKeys,omitempty"`
+	// APIKeysFile is optional, it is a yaml list of the api keys merged with APIKeys, and it is reloaded when it
+	// changes, so the keys can be kept in a secret
+	APIKeysFile string `json:"apiKeysFile,omitempty"`
+}
+
+type APIKeyConfig struct {
+	// Name identifies the key in the usage, the key itself is never exposed
+	Name string `json:"name"`
+	Key  string `json:"key"`
+	// QPS and Burst limit the requests of the key with a token bucket, the key is not limited when QPS is 0,
+	// Burst defaults to the QPS rounded up
+	QPS   float64 `json:"qps,omitempty"`
+	Burst int     `json:"burst,omitempty"`
+	// Admin allows the key to call the admin apis
+	Admin bool `json:"admin,omitempty"`
+}
+
+// LoadAPIKeysFile reads the api keys from the file.
+func LoadAPIKeysFile(path string) ([]APIKeyConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []APIKeyConfig
+	if err := yaml.UnmarshalStrict(data, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse api keys file %s: %w", path, err)
+	}
+	return keys, nil
+}
+
+// ValidateAPIKeys checks the keys merged from the config file and the api keys file.
+func ValidateAPIKeys(keys []APIKeyConfig) error {
+	names, values := sets.Set[string]{}, sets.Set[string]{}
+	for i, k := range keys {
+		if k.Name == "" || k.Key == "" {
+			return fmt.Errorf("api key %d: name and key are required", i)
+		}
+		if names.Has(k.Name) {
+			return fmt.Errorf("api key %s: the name is duplicated", k.Name)
+		}
+		if values.Has(k.Key) {
+			return fmt.Errorf("api key %s: the key is duplicated", k.Name)
+		}
+		if k.QPS < 0 || k.Burst < 0 {
+			return fmt.Errorf("api key %s: qps and burst should not be negative", k.Name)
+		}
+		names.Insert(k.Name)
+		values.Insert(k.Key)
+	}
+	return nil
+}
+
+// HealthConfig configures the readiness checks served by /readyz.
+type HealthConfig struct {
+	// Staleness fails the readiness when no region of a source is refreshed successfully within it, the unset
+	// sources keep the defaults and 0 disables the check of the source
+	Staleness StalenessConfig `json:"staleness,omitempty"`
+}
+
+type StalenessConfig struct {
+	OnDemand    *metav1.Duration `json:"onDemand,omitempty"`
+	Spot        *metav1.Duration `json:"spot,omitempty"`
+	SavingsPlan *metav1.Duration `json:"savingsPlan,omitempty"`
+	Metadata    *metav1.Duration `json:"metadata,omitempty"`
+	Plugin      *metav1.Duration `json:"plugin,omitempty"`
+	// Snapshot is the sync with the snapshots published by the leader, it applies with the leader election only
+	Snapshot *metav1.Duration `json:"snapshot,omitempty"`
+}
+
+// Thresholds returns the staleness keyed by the source, the price sheets are reloaded when they change only, so
+// they are never stale.
+func (c StalenessConfig) Thresholds() map[string]time.Duration {
+	return map[string]time.Duration{
+		apis.SourceOnDemand:    durationOrDefault(c.OnDemand, defaultOnDemandStaleness),
+		apis.SourceSpot:        durationOrDefault(c.Spot, defaultSpotStaleness),
+		apis.SourceSavingsPlan: durationOrDefault(c.SavingsPlan, defaultSavingsPlanStaleness),
+		apis.SourceMetadata:    durationOrDefault(c.Metadata, defaultMetadataStaleness),
+		apis.SourcePlugin:      durationOrDefault(c.Plugin, defaultPluginStaleness),
+		apis.SourceSnapshot:    durationOrDefault(c.Snapshot, defaultSnapshotStaleness),
+	}
+}
+
+func durationOrDefault(d *metav1.Duration, defaultValue time.Duration) time.Duration {
+	if d == nil {
+		return defaultValue
+	}
+	return d.Duration
+}
+
+// LeaderElectionConfig lets only the leader of the replicas refresh the prices from the cloud apis, the leader
+// publishes the snapshots of the prices to the configmaps and the followers serve the latest published ones.
+type LeaderElectionConfig struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Namespace holds the lease and the snapshot configmaps, it defaults to the namespace of the pod
+	Namespace string `json:"namespace,omitempty"`
+	LeaseName string `json:"leaseName,omitempty"`
+	// LeaseDuration, RenewDeadline and RetryPeriod are the timings of the lease like the kubernetes controllers
+	LeaseDuration metav1.Duration `json:"leaseDuration,omitempty"`
+	RenewDeadline metav1.Duration `json:"renewDeadline,omitempty"`
+	RetryPeriod   metav1.Duration `json:"retryPeriod,omitempty"`
+	// SnapshotPrefix names the configmaps of the snapshots, like <prefix>-aws
+	SnapshotPrefix string `json:"snapshotPrefix,omitempty"`
+	// PublishInterval is how often the leader publishes the changed prices, and SyncInterval is how often the
+	// followers check for a new snapshot
+	PublishInterval metav1.Duration `json:"publishInterval,omitempty"`
+	SyncInterval    metav1.Duration `json:"syncInterval,omitempty"`
+}
+
+type ProviderConfig struct {
+	// Enabled overrides whether the provider is enabled, the <PROVIDER>_ENABLED env takes precedence over it
+	Enabled     *bool           `json:"enabled,omitempty"`
+	Intervals   IntervalsConfig `json:"intervals,omitempty"`
+	Regions     RegionsConfig   `json:"regions,omitempty"`
+	Concurrency int             `json:"concurrency,omitempty"`
+	// Schedules are keyed by the source, they apply to the providers refreshed by the scheduler
+	Schedules map[string]ScheduleConfig `json:"schedules,omitempty"`
+}
+
+// ScheduleConfig overrides the schedule of a source, the unset fields keep the defaults.
+type ScheduleConfig struct {
+	// Cron is a 5-field cron expression in UTC, like "*/30 * * * *", it replaces the interval of the source
+	Cron string `json:"cron,omitempty"`
+	// Jitter delays every run by a random duration up to it, a tenth of the interval by default and none for the
+	// cron expressions
+	Jitter *metav1.Duration `json:"jitter,omitempty"`
+	// MaxRuntime cancels the refresh taking longer, the refreshes are not limited by default
+	MaxRuntime *metav1.Duration `json:"maxRuntime,omitempty"`
+}
+
+// IntervalsConfig are the refresh intervals, the unset ones keep the defaults of the provider.
+type IntervalsConfig struct {
+	OnDemand *metav1.Duration `json:"onDemand,omitempty"`
+	Spot     *metav1.Duration `json:"spot,omitempty"`
+	Metadata *metav1.Duration `json:"metadata,omitempty"`
+}
+
+// RegionsConfig limits the regions refreshed and served, only the allowed regions are served when Allow is set.
+type RegionsConfig struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+// Default returns the config used when no config file is given.
+func Default() *Config {
+	c := &Config{APIVersion: APIVersion, Kind: Kind}
+	c.setDefaults()
+	return c
+}
+
+// Load reads and validates the config file, the unknown fields are rejected.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Config{}
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	c.setDefaults()
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return c, nil
+}
+
+func (c *Config) setDefaults() {
+	if c.Server.ListenAddress == "" {
+		c.Server.ListenAddress = DefaultListenAddress
+	}
+	if len(c.Server.CORS.AllowOrigins) == 0 {
+		c.Server.CORS.AllowOrigins = []string{"*"}
+	}
+	if len(c.Server.CORS.AllowHeaders) == 0 {
+		c.Server.CORS.AllowHeaders = []string{"*"}
+	}
+
+	l := &c.LeaderElection
+	if l.LeaseName == "" {
+		l.LeaseName = defaultLeaseName
+	}
+	if l.SnapshotPrefix == "" {
+		l.SnapshotPrefix = defaultSnapshotPrefix
+	}
+	for d, defaultValue := range map[*metav1.Duration]time.Duration{
+		&l.LeaseDuration:   defaultLeaseDuration,
+		&l.RenewDeadline:   defaultRenewDeadline,
+		&l.RetryPeriod:     defaultRetryPeriod,
+		&l.PublishInterval: defaultSnapshotPublishInterval,
+		&l.SyncInterval:    defaultSnapshotSyncInterval,
+	} {
+		if d.Duration == 0 {
+			d.Duration = defaultValue
+		}
+	}
+}
+
+func (c *Config) Validate() error {
+	if c.APIVersion != APIVersion {
+		return fmt.Errorf("apiVersion should be %s, got %q", APIVersion, c.APIVersion)
+	}
+	if c.Kind != Kind {
+		return fmt.Errorf("kind should be %s, got %q", Kind, c.Kind)
+	}
+
+	if _, _, err := net.SplitHostPort(c.Server.ListenAddress); err != nil {
+		return fmt.Errorf("invalid server.listenAddress %q: %v", c.Server.ListenAddress, err)
+	}
+	if c.Server.HealthListenAddress != "" {
+		if _, _, err := net.SplitHostPort(c.Server.HealthListenAddress); err != nil {
+			return fmt.Errorf("invalid server.healthListenAddress %q: %v", c.Server.HealthListenAddress, err)
+		}
+		if c.Server.HealthListenAddress == c.Server.ListenAddress {
+			return fmt.Errorf("server.healthListenAddress should be different from server.listenAddress")
+		}
+	}
+	if (c.Server.TLS.CertFile == "") != (c.Server.TLS.KeyFile == "") {
+		return fmt.Errorf("server.tls.certFile and server.tls.keyFile should be set together")
+	}
+	if c.Server.TLS.ClientCAFile != "" && !c.Server.TLS.Enabled() {
+		return fmt.Errorf("server.tls.clientCAFile needs server.tls.certFile and server.tls.keyFile")
+	}
+	if err := c.Server.CORS.GinConfig().Validate(); err != nil {
+		return fmt.Errorf("invalid server.cors: %v", err)
+	}
+	if err := ValidateAPIKeys(c.Auth.APIKeys); err != nil {
+		return fmt.Errorf("invalid auth.apiKeys: %v", err)
+	}
+	for field, d := range map[string]*metav1.Duration{
+		"onDemand":    c.Health.Staleness.OnDemand,
+		"spot":        c.Health.Staleness.Spot,
+		"savingsPlan": c.Health.Staleness.SavingsPlan,
+		"metadata":    c.Health.Staleness.Metadata,
+		"plugin":      c.Health.Staleness.Plugin,
+		"snapshot":    c.Health.Staleness.Snapshot,
+	} {
+		if d != nil && d.Duration < 0 {
+			return fmt.Errorf("health.staleness.%s should not be negative, got %v", field, d.Duration)
+		}
+	}
+	if err := c.LeaderElection.validate(); err != nil {
+		return fmt.Errorf("invalid leaderElection: %v", err)
+	}
+
+	for name, p := range c.Providers {
+		for field, d := range map[string]*metav1.Duration{
+			"onDemand": p.Intervals.OnDemand,
+			"spot":     p.Intervals.Spot,
+			"metadata": p.Intervals.Metadata,
+		} {
+			if d != nil && d.Duration <= 0 {
+				return fmt.Errorf("providers.%s.intervals.%s should be positive, got %v", name, field, d.Duration)
+			}
+		}
+		if p.Concurrency < 0 || p.Concurrency > maxConcurrency {
+			return fmt.Errorf("providers.%s.concurrency should be in [0, %d], got %d", name, maxConcurrency,
+				p.Concurrency)
+		}
+		if both := sets.New(p.Regions.Allow...).Intersection(sets.New(p.Regions.Deny...)); both.Len() != 0 {
+			return fmt.Errorf("providers.%s.regions: %v are both allowed and denied", name, sets.List(both))
+		}
+		for source, s := range p.Schedules {
+			if !scheduledSources.Has(source) {
+				return fmt.Errorf("providers.%s.schedules: unknown source %q, the sources are %v", name, source,
+					sets.List(scheduledSources))
+			}
+			if s.Cron != "" {
+				if _, err := scheduler.ParseCron(s.Cron); err != nil {
+					return fmt.Errorf("providers.%s.schedules.%s.cron: %v", name, source, err)
+				}
+			}
+			if (s.Jitter != nil && s.Jitter.Duration < 0) || (s.MaxRuntime != nil && s.MaxRuntime.Duration < 0) {
+				return fmt.Errorf("providers.%s.schedules.%s: jitter and maxRuntime should not be negative", name,
+					source)
+			}
+		}
+	}
+	return nil
+}
+
+func (c LeaderElectionConfig) validate() error {
+	if errs := validation.IsDNS1123Subdomain(c.LeaseName); len(errs) != 0 {
+		return fmt.Errorf("leaseName %q: %v", c.LeaseName, errs)
+	}
+	// The providers and the chunk numbers are appended to the prefix
+	if errs := validation.IsDNS1123Label(c.SnapshotPrefix); len(errs) != 0 {
+		return fmt.Errorf("snapshotPrefix %q: %v", c.SnapshotPrefix, errs)
+	}
+	if c.Namespace != "" {
+		if errs := validation.IsDNS1123Label(c.Namespace); len(errs) != 0 {
+			return fmt.Errorf("namespace %q: %v", c.Namespace, errs)
+		}
+	}
+	for field, d := range map[string]metav1.Duration{
+		"leaseDuration":   c.LeaseDuration,
+		"renewDeadline":   c.RenewDeadline,
+		"retryPeriod":     c.RetryPeriod,
+		"publishInterval": c.PublishInterval,
+		"syncInterval":    c.SyncInterval,
+	} {
+		if d.Duration <= 0 {
+			return fmt.Errorf("%s should be positive, got %v", field, d.Duration)
+		}
+	}
+	// The same constraints as the leader election of client-go
+	if c.LeaseDuration.Duration <= c.RenewDeadline.Duration {
+		return fmt.Errorf("leaseDuration should be longer than renewDeadline")
+	}
+	if float64(c.RenewDeadline.Duration) <= leaderElectionRetryJitterFactor*float64(c.RetryPeriod.Duration) {
+		return fmt.Errorf("renewDeadline should be longer than %v times retryPeriod", leaderElectionRetryJitterFactor)
+	}
+	return nil
+}
+
+// ProviderSettings returns the settings applied to the provider, the zero values keep the defaults.
+func (c *Config) ProviderSettings(name string) client.ProviderSettings {
+	p := c.Providers[name]
+	settings := client.ProviderSettings{
+		Concurrency:  p.Concurrency,
+		AllowRegions: p.Regions.Allow,
+		DenyRegions:  p.Regions.Deny,
+	}
+	if p.Intervals.OnDemand != nil {
+		settings.OnDemandInterval = p.Intervals.OnDemand.Duration
+	}
+	if p.Intervals.Spot != nil {
+		settings.SpotInterval = p.Intervals.Spot.Duration
+	}
+	if p.Intervals.Metadata != nil {
+		settings.MetadataInterval = p.Intervals.Metadata.Duration
+	}
+	if len(p.Schedules) != 0 {
+		settings.Schedules = map[string]client.ScheduleSettings{}
+	}
+	for source, s := range p.Schedules {
+		schedule := client.ScheduleSettings{}
+		if s.Cron != "" {
+			// The expressions have been validated
+			schedule.Cron, _ = scheduler.ParseCron(s.Cron)
+		}
+		if s.Jitter != nil {
+			schedule.Jitter = &s.Jitter.Duration
+		}
+		if s.MaxRuntime != nil {
+			schedule.MaxRuntime = s.MaxRuntime.Duration
+		}
+		settings.Schedules[source] = schedule
+	}
+	return settings
+}
+
+// ProviderEnabled returns whether the provider is enabled or disabled explicitly by the config file.
+func (c *Config) ProviderEnabled(name string) (enabled bool, ok bool) {
+	p, found := c.Providers[name]
+	if !found || p.Enabled == nil {
+		return false, false
+	}
+	return *p.Enabled, true
+}
@@ -0,0 +1,233 @@
+// Package health contains the liveness and the readiness checks of priceserver.
+package health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+	"github.com/cloudpilot-ai/priceserver/pkg/client"
+	"github.com/cloudpilot-ai/priceserver/pkg/config"
+)
+
+const (
+	// CheckRunning fails when the Run loop of a provider exits before the shutdown
+	CheckRunning = "running"
+	// CheckLoaded fails when a built-in provider serves no instance type
+	CheckLoaded = "loaded"
+	// CheckRefreshed fails when a source required before serving is never refreshed successfully
+	CheckRefreshed = "refreshed"
+	// CheckFresh fails when no region of a source is refreshed successfully within the staleness
+	CheckFresh = "fresh"
+)
+
+// Checker checks the liveness by the Run loops of the providers, and the readiness by the data of the providers
+// and the age of their last successful refreshes.
+type Checker struct {
+	registry *client.ProviderRegistry
+	// startTime is the age of the sources never refreshed successfully, they are served from the built-in data
+	startTime time.Time
+
+	mutex sync.RWMutex
+	// exited are the providers whose Run loop returned before the shutdown
+	exited     map[string]bool
+	thresholds map[string]time.Duration
+	// refreshes is nil when every provider is refreshed by this replica
+	refreshes func(provider string) bool
+}
+
+// NewChecker creates the checker, the config should have been validated.
+func NewChecker(registry *client.ProviderRegistry, c config.HealthConfig) *Checker {
+	return &Checker{
+		registry:   registry,
+		startTime:  time.Now(),
+		exited:     map[string]bool{},
+		thresholds: c.Staleness.Thresholds(),
+	}
+}
+
+// Update replaces the staleness for the following checks.
+func (c *Checker) Update(config config.HealthConfig) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.thresholds = config.Staleness.Thresholds()
+}
+
+// SetLeadership checks only the snapshots of the providers not refreshed by this replica, their prices are loaded
+// from the snapshots published by the leader. It should be called before the checks are served.
+func (c *Checker) SetLeadership(refreshes func(provider string) bool) {
+	c.refreshes = refreshes
+}
+
+// Run runs the Run loop of the provider until the ctx is done, the liveness fails if it returns earlier.
+func (c *Checker) Run(ctx context.Context, p *client.RegisteredProvider) {
+	p.Provider.Run(ctx)
+	if ctx.Err() != nil {
+		return
+	}
+
+	klog.Errorf("The refresh loop of provider %s exited unexpectedly", p.Name)
+	c.mutex.Lock()
+	c.exited[p.Name] = true
+	c.mutex.Unlock()
+}
+
+// Liveness reports whether the Run loops of all the providers are running.
+func (c *Checker) Liveness() apis.HealthReport {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	var failures []apis.HealthCheckFailure
+	for _, p := range c.registry.List() {
+		if c.exited[p.Name] {
+			failures = append(failures, apis.HealthCheckFailure{
+				Check:    CheckRunning,
+				Provider: p.Name,
+				Message:  "the refresh loop exited",
+			})
+		}
+	}
+	return apis.HealthReport{Healthy: len(failures) == 0, Failures: failures}
+}
+
+// Readiness reports whether the built-in providers have loaded their data, the required sources have been
+// refreshed, and every source refreshed by the providers is fresher than its staleness in any region. The regions
+// older than the staleness are reported as stale without failing the readiness.
+func (c *Checker) Readiness() apis.HealthReport {
+	c.mutex.RLock()
+	thresholds := c.thresholds
+	c.mutex.RUnlock()
+
+	var failures, stale []apis.HealthCheckFailure
+	for _, p := range c.registry.List() {
+		// The plugins are polled after the startup, so they may have no data yet
+		if _, ok := p.Provider.(*client.PluginPriceClient); !ok && len(p.Provider.ListInstanceTypes()) == 0 {
+			failures = append(failures, apis.HealthCheckFailure{
+				Check:    CheckLoaded,
+				Provider: p.Name,
+				Message:  "no instance type is loaded",
+			})
+		}
+
+		reporter, ok := p.Provider.(client.RefreshStatusReporter)
+		if !ok {
+			continue
+		}
+		status := reporter.RefreshStatus()
+		if c.refreshes != nil && !c.refreshes(p.Name) {
+			status = snapshotStatus(status)
+		}
+		sourceFailures, staleRegions := c.checkRefreshes(p.Name, status, thresholds)
+		failures = append(failures, sourceFailures...)
+		stale = append(stale, staleRegions...)
+	}
+	return apis.HealthReport{Healthy: len(failures) == 0, Failures: failures, Stale: stale}
+}
+
+// snapshotStatus keeps the syncs of the snapshots only, the other refreshes are left by a previous leadership.
+// The sources are judged by the refresh times published by the leader instead, so the prices served by a follower
+// become stale as the snapshot gets old, however recently it is synced.
+func snapshotStatus(status apis.ProviderRefreshStatus) apis.ProviderRefreshStatus {
+	ret := apis.ProviderRefreshStatus{Ready: true}
+	for _, refresh := range status.Refreshes {
+		if refresh.Source == apis.SourceSnapshot {
+			ret.Refreshes = append(ret.Refreshes, refresh)
+		}
+	}
+	for source, refreshTime := range status.PublishedRefreshTimes {
+		ret.Refreshes = append(ret.Refreshes, apis.RefreshStatus{Source: source, LastSuccessTime: &refreshTime})
+	}
+	return ret
+}
+
+// checkRefreshes fails the sources never refreshed or not refreshed in any region within the staleness, the regions
+// left behind by the other regions of a fresh source are returned as stale.
+func (c *Checker) checkRefreshes(provider string, status apis.ProviderRefreshStatus,
+	thresholds map[string]time.Duration) (failures, stale []apis.HealthCheckFailure) {
+	// The last success of every source by the region, the zero time means the region is never refreshed
+	// successfully
+	lastSuccess := map[string]map[string]time.Time{}
+	// The latest success of every source across the regions
+	latestSuccess := map[string]time.Time{}
+	for _, refresh := range status.Refreshes {
+		if lastSuccess[refresh.Source] == nil {
+			lastSuccess[refresh.Source] = map[string]time.Time{}
+		}
+		last := lastSuccess[refresh.Source][refresh.Region]
+		if refresh.LastSuccessTime != nil && refresh.LastSuccessTime.After(last) {
+			last = *refresh.LastSuccessTime
+		}
+		lastSuccess[refresh.Source][refresh.Region] = last
+		if last.After(latestSuccess[refresh.Source]) {
+			latestSuccess[refresh.Source] = last
+		}
+	}
+
+	required := map[string]bool{}
+	for _, source := range status.RequiredSources {
+		required[source] = true
+		if latestSuccess[source].IsZero() {
+			failures = append(failures, apis.HealthCheckFailure{
+				Check:    CheckRefreshed,
+				Provider: provider,
+				Source:   source,
+				Message:  "the source is never refreshed successfully",
+			})
+		}
+	}
+
+	sources := make([]string, 0, len(lastSuccess))
+	for source := range lastSuccess {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	for _, source := range sources {
+		latest, threshold := latestSuccess[source], thresholds[source]
+		if threshold == 0 || (latest.IsZero() && required[source]) {
+			continue
+		}
+
+		failure := apis.HealthCheckFailure{Check: CheckFresh, Provider: provider, Source: source}
+		if c.checkFresh(&failure, "source", latest, threshold) {
+			failures = append(failures, failure)
+			continue
+		}
+
+		regions := make([]string, 0, len(lastSuccess[source]))
+		for region := range lastSuccess[source] {
+			regions = append(regions, region)
+		}
+		sort.Strings(regions)
+		for _, region := range regions {
+			failure := apis.HealthCheckFailure{Check: CheckFresh, Provider: provider, Source: source, Region: region}
+			if c.checkFresh(&failure, "region", lastSuccess[source][region], threshold) {
+				stale = append(stale, failure)
+			}
+		}
+	}
+	return failures, stale
+}
+
+// checkFresh fills the failure and returns true when the last success is older than the threshold, the never
+// refreshed ones are served from the built-in data since the startup. The subject is the source or the region.
+func (c *Checker) checkFresh(failure *apis.HealthCheckFailure, subject string, last time.Time,
+	threshold time.Duration) bool {
+	if last.IsZero() {
+		if time.Since(c.startTime) <= threshold {
+			return false
+		}
+		failure.Message = fmt.Sprintf("the %s is never refreshed successfully in %v", subject, threshold)
+		return true
+	}
+	if time.Since(last) <= threshold {
+		return false
+	}
+	failure.Message = fmt.Sprintf("the %s is not refreshed successfully in %v", subject, threshold)
+	failure.LastSuccessTime = &last
+	return true
+}
@@ -0,0 +1,98 @@
+package health
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+)
+
+func TestCheckRefreshes(t *testing.T) {
+	now := time.Now()
+	ago := func(d time.Duration) *time.Time {
+		ret := now.Add(-d)
+		return &ret
+	}
+	thresholds := map[string]time.Duration{apis.SourceSpot: time.Hour, apis.SourceMetadata: time.Hour}
+
+	for _, tc := range []struct {
+		name string
+		// the checker is started long ago unless it is set
+		startTime time.Time
+		status    apis.ProviderRefreshStatus
+		// the failures and the stale regions formatted as <check>/<source>/<region>
+		failures []string
+		stale    []string
+	}{
+		{
+			name: "fresh",
+			status: apis.ProviderRefreshStatus{Refreshes: []apis.RefreshStatus{
+				{Region: "r1", Source: apis.SourceSpot, LastSuccessTime: ago(time.Minute)},
+				{Region: "r2", Source: apis.SourceSpot, LastSuccessTime: ago(time.Minute)},
+			}},
+		},
+		{
+			name: "one region left behind",
+			status: apis.ProviderRefreshStatus{Refreshes: []apis.RefreshStatus{
+				{Region: "r1", Source: apis.SourceSpot, LastSuccessTime: ago(time.Minute)},
+				{Region: "r2", Source: apis.SourceSpot, LastSuccessTime: ago(2 * time.Hour)},
+				{Region: "r3", Source: apis.SourceSpot},
+			}},
+			stale: []string{"fresh/spot/r2", "fresh/spot/r3"},
+		},
+		{
+			name:      "never refreshed region right after the startup",
+			startTime: now,
+			status: apis.ProviderRefreshStatus{Refreshes: []apis.RefreshStatus{
+				{Region: "r1", Source: apis.SourceSpot, LastSuccessTime: ago(time.Minute)},
+				{Region: "r2", Source: apis.SourceSpot},
+			}},
+		},
+		{
+			name: "all regions stale",
+			status: apis.ProviderRefreshStatus{Refreshes: []apis.RefreshStatus{
+				{Region: "r1", Source: apis.SourceSpot, LastSuccessTime: ago(2 * time.Hour)},
+				{Region: "r2", Source: apis.SourceSpot, LastSuccessTime: ago(3 * time.Hour)},
+				{Source: apis.SourceMetadata, LastSuccessTime: ago(time.Minute)},
+			}},
+			failures: []string{"fresh/spot/"},
+		},
+		{
+			name: "required source never refreshed",
+			status: apis.ProviderRefreshStatus{
+				RequiredSources: []string{apis.SourceSpot},
+				Refreshes:       []apis.RefreshStatus{{Region: "r1", Source: apis.SourceSpot}},
+			},
+			failures: []string{"refreshed/spot/"},
+		},
+		{
+			name: "source without staleness",
+			status: apis.ProviderRefreshStatus{Refreshes: []apis.RefreshStatus{
+				{Region: "r1", Source: apis.SourceOnDemand, LastSuccessTime: ago(100 * time.Hour)},
+			}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Checker{startTime: tc.startTime}
+			if c.startTime.IsZero() {
+				c.startTime = now.Add(-24 * time.Hour)
+			}
+			failures, stale := c.checkRefreshes("p", tc.status, thresholds)
+			if got := formatFailures(failures); got != fmt.Sprint(tc.failures) {
+				t.Errorf("Expected the failures %v, got %v", tc.failures, got)
+			}
+			if got := formatFailures(stale); got != fmt.Sprint(tc.stale) {
+				t.Errorf("Expected the stale regions %v, got %v", tc.stale, got)
+			}
+		})
+	}
+}
+
+func formatFailures(failures []apis.HealthCheckFailure) string {
+	var ret []string
+	for _, f := range failures {
+		ret = append(ret, fmt.Sprintf("%s/%s/%s", f.Check, f.Source, f.Region))
+	}
+	return fmt.Sprint(ret)
+}
@@ -0,0 +1,151 @@
+// Package metrics contains the prometheus metrics of priceserver.
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const (
+	namespace = "priceserver"
+
+	ResultSuccess = "success"
+	ResultError   = "error"
+)
+
+var (
+	// Registry holds all the metrics of priceserver, it is served on /metrics
+	Registry = prometheus.NewRegistry()
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "The latency of the http requests by the route, the unmatched requests have an empty route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "code"})
+
+	RefreshDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "refresh_duration_seconds",
+		Help:      "The duration of the price refreshes by the provider, the region, the source and the result.",
+		Buckets:   prometheus.ExponentialBuckets(0.5, 2, 12),
+	}, []string{"provider", "region", "source", "result"})
+
+	CloudAPICalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cloud_api_calls_total",
+		Help:      "The calls to the cloud apis by the provider and the api.",
+	}, []string{"provider", "api"})
+
+	CloudAPIErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cloud_api_errors_total",
+		Help:      "The failed calls to the cloud apis by the provider and the api.",
+	}, []string{"provider", "api"})
+
+	Leader = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "leader",
+		Help:      "Whether the replica is the leader refreshing the prices, it is 0 without the leader election.",
+	})
+
+	dataAgeDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "data_age_seconds"),
+		"The seconds since the last successful refresh by the provider, the region and the source.",
+		[]string{"provider", "region", "source"}, nil)
+
+	instanceTypesDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "instance_types"),
+		"The instance types served by the provider and the region.",
+		[]string{"provider", "region"}, nil)
+)
+
+type refreshKey struct {
+	provider string
+	region   string
+	source   string
+}
+
+var (
+	lastSuccessMutex sync.RWMutex
+	lastSuccess      = map[refreshKey]time.Time{}
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		RefreshDuration,
+		CloudAPICalls,
+		CloudAPIErrors,
+		Leader,
+		dataAgeCollector{},
+	)
+}
+
+// ObserveRefresh records a refresh of one source in one region started at startTime, the region is empty for the
+// refreshes not split by region.
+func ObserveRefresh(provider, region, source string, startTime time.Time, err error) {
+	result := ResultSuccess
+	if err != nil {
+		result = ResultError
+	}
+	RefreshDuration.WithLabelValues(provider, region, source, result).Observe(time.Since(startTime).Seconds())
+
+	if err == nil {
+		lastSuccessMutex.Lock()
+		lastSuccess[refreshKey{provider: provider, region: region, source: source}] = time.Now()
+		lastSuccessMutex.Unlock()
+	}
+}
+
+// ObserveCloudAPICall records a call to the cloud api, err is the error returned by the call.
+func ObserveCloudAPICall(provider, api string, err error) {
+	CloudAPICalls.WithLabelValues(provider, api).Inc()
+	if err != nil {
+		CloudAPIErrors.WithLabelValues(provider, api).Inc()
+	}
+}
+
+// dataAgeCollector computes the age of the data at scrape time, so the age keeps growing when the refreshes stop.
+type dataAgeCollector struct{}
+
+func (dataAgeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- dataAgeDesc
+}
+
+func (dataAgeCollector) Collect(ch chan<- prometheus.Metric) {
+	lastSuccessMutex.RLock()
+	defer lastSuccessMutex.RUnlock()
+
+	now := time.Now()
+	for k, t := range lastSuccess {
+		ch <- prometheus.MustNewConstMetric(dataAgeDesc, prometheus.GaugeValue, now.Sub(t).Seconds(),
+			k.provider, k.region, k.source)
+	}
+}
+
+// InstanceTypeCollector reports the instance types served by region, count returns the counts keyed by the
+// provider and then the region.
+type InstanceTypeCollector struct {
+	count func() map[string]map[string]int
+}
+
+func NewInstanceTypeCollector(count func() map[string]map[string]int) *InstanceTypeCollector {
+	return &InstanceTypeCollector{count: count}
+}
+
+func (c *InstanceTypeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- instanceTypesDesc
+}
+
+func (c *InstanceTypeCollector) Collect(ch chan<- prometheus.Metric) {
+	for provider, regions := range c.count() {
+		for region, count := range regions {
+			ch <- prometheus.MustNewConstMetric(instanceTypesDesc, prometheus.GaugeValue, float64(count),
+				provider, region)
+		}
+	}
+}
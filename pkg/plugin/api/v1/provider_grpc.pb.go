@@ -0,0 +1,191 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: pkg/plugin/api/v1/provider.proto
+
+package pluginv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	PriceProvider_ListRegions_FullMethodName        = "/priceserver.plugin.v1.PriceProvider/ListRegions"
+	PriceProvider_ListInstancePrices_FullMethodName = "/priceserver.plugin.v1.PriceProvider/ListInstancePrices"
+	PriceProvider_GetInstancePrice_FullMethodName   = "/priceserver.plugin.v1.PriceProvider/GetInstancePrice"
+)
+
+// PriceProviderClient is the client API for PriceProvider service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PriceProviderClient interface {
+	// ListRegions returns all the regions the plugin has prices for.
+	ListRegions(ctx context.Context, in *ListRegionsRequest, opts ...grpc.CallOption) (*ListRegionsResponse, error)
+	// ListInstancePrices returns the prices of all the instance types in one region.
+	ListInstancePrices(ctx context.Context, in *ListInstancePricesRequest, opts ...grpc.CallOption) (*ListInstancePricesResponse, error)
+	// GetInstancePrice returns the price of one instance type in one region, NOT_FOUND should be returned when
+	// the instance type is unknown.
+	GetInstancePrice(ctx context.Context, in *GetInstancePriceRequest, opts ...grpc.CallOption) (*InstanceTypePrice, error)
+}
+
+type priceProviderClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPriceProviderClient(cc grpc.ClientConnInterface) PriceProviderClient {
+	return &priceProviderClient{cc}
+}
+
+func (c *priceProviderClient) ListRegions(ctx context.Context, in *ListRegionsRequest, opts ...grpc.CallOption) (*ListRegionsResponse, error) {
+	out := new(ListRegionsResponse)
+	err := c.cc.Invoke(ctx, PriceProvider_ListRegions_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *priceProviderClient) ListInstancePrices(ctx context.Context, in *ListInstancePricesRequest, opts ...grpc.CallOption) (*ListInstancePricesResponse, error) {
+	out := new(ListInstancePricesResponse)
+	err := c.cc.Invoke(ctx, PriceProvider_ListInstancePrices_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *priceProviderClient) GetInstancePrice(ctx context.Context, in *GetInstancePriceRequest, opts ...grpc.CallOption) (*InstanceTypePrice, error) {
+	out := new(InstanceTypePrice)
+	err := c.cc.Invoke(ctx, PriceProvider_GetInstancePrice_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PriceProviderServer is the server API for PriceProvider service.
+// All implementations must embed UnimplementedPriceProviderServer
+// for forward compatibility
+type PriceProviderServer interface {
+	// ListRegions returns all the regions the plugin has prices for.
+	ListRegions(context.Context, *ListRegionsRequest) (*ListRegionsResponse, error)
+	// ListInstancePrices returns the prices of all the instance types in one region.
+	ListInstancePrices(context.Context, *ListInstancePricesRequest) (*ListInstancePricesResponse, error)
+	// GetInstancePrice returns the price of one instance type in one region, NOT_FOUND should be returned when
+	// the instance type is unknown.
+	GetInstancePrice(context.Context, *GetInstancePriceRequest) (*InstanceTypePrice, error)
+	mustEmbedUnimplementedPriceProviderServer()
+}
+
+// UnimplementedPriceProviderServer must be embedded to have forward compatible implementations.
+type UnimplementedPriceProviderServer struct {
+}
+
+func (UnimplementedPriceProviderServer) ListRegions(context.Context, *ListRegionsRequest) (*ListRegionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRegions not implemented")
+}
+func (UnimplementedPriceProviderServer) ListInstancePrices(context.Context, *ListInstancePricesRequest) (*ListInstancePricesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListInstancePrices not implemented")
+}
+func (UnimplementedPriceProviderServer) GetInstancePrice(context.Context, *GetInstancePriceRequest) (*InstanceTypePrice, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetInstancePrice not implemented")
+}
+func (UnimplementedPriceProviderServer) mustEmbedUnimplementedPriceProviderServer() {}
+
+// UnsafePriceProviderServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PriceProviderServer will
+// result in compilation errors.
+type UnsafePriceProviderServer interface {
+	mustEmbedUnimplementedPriceProviderServer()
+}
+
+func RegisterPriceProviderServer(s grpc.ServiceRegistrar, srv PriceProviderServer) {
+	s.RegisterService(&PriceProvider_ServiceDesc, srv)
+}
+
+func _PriceProvider_ListRegions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRegionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PriceProviderServer).ListRegions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PriceProvider_ListRegions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PriceProviderServer).ListRegions(ctx, req.(*ListRegionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PriceProvider_ListInstancePrices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListInstancePricesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PriceProviderServer).ListInstancePrices(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PriceProvider_ListInstancePrices_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PriceProviderServer).ListInstancePrices(ctx, req.(*ListInstancePricesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PriceProvider_GetInstancePrice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetInstancePriceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PriceProviderServer).GetInstancePrice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PriceProvider_GetInstancePrice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PriceProviderServer).GetInstancePrice(ctx, req.(*GetInstancePriceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// PriceProvider_ServiceDesc is the grpc.ServiceDesc for PriceProvider service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PriceProvider_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "priceserver.plugin.v1.PriceProvider",
+	HandlerType: (*PriceProviderServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListRegions",
+			Handler:    _PriceProvider_ListRegions_Handler,
+		},
+		{
+			MethodName: "ListInstancePrices",
+			Handler:    _PriceProvider_ListInstancePrices_Handler,
+		},
+		{
+			MethodName: "GetInstancePrice",
+			Handler:    _PriceProvider_GetInstancePrice_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "pkg/plugin/api/v1/provider.proto",
+}
@@ -0,0 +1,60 @@
+package plugin
+
+import (
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+	pluginv1 "github.com/cloudpilot-ai/priceserver/pkg/plugin/api/v1"
+)
+
+// ToInstanceTypePrice converts the price returned by the plugin to the price served by priceserver.
+func ToInstanceTypePrice(p *pluginv1.InstanceTypePrice) *apis.InstanceTypePrice {
+	if p == nil {
+		return nil
+	}
+	arch := p.GetArch()
+	if arch == "" {
+		arch = "amd64"
+	}
+	return &apis.InstanceTypePrice{
+		InstanceTypeMetadata: apis.InstanceTypeMetadata{
+			Arch:           arch,
+			VCPU:           p.GetVcpu(),
+			Memory:         p.GetMemory(),
+			GPU:            p.GetGpu(),
+			InstanceFamily: p.GetInstanceFamily(),
+		},
+		Zones:                 append([]string{}, p.GetZones()...),
+		OnDemandPricePerHour:  p.GetOnDemandPricePerHour(),
+		SpotPricePerHour:      copyPrices(p.GetSpotPricePerHour()),
+		CommittedPricePerHour: copyPrices(p.GetCommittedPricePerHour()),
+	}
+}
+
+// FromInstanceTypePrice converts the price of priceserver to the plugin contract, it is used by the plugins
+// reusing the apis types.
+func FromInstanceTypePrice(p *apis.InstanceTypePrice) *pluginv1.InstanceTypePrice {
+	if p == nil {
+		return nil
+	}
+	return &pluginv1.InstanceTypePrice{
+		Arch:                  p.Arch,
+		Vcpu:                  p.VCPU,
+		Memory:                p.Memory,
+		Gpu:                   p.GPU,
+		InstanceFamily:        p.InstanceFamily,
+		Zones:                 append([]string{}, p.Zones...),
+		OnDemandPricePerHour:  p.OnDemandPricePerHour,
+		SpotPricePerHour:      copyPrices(p.SpotPricePerHour),
+		CommittedPricePerHour: copyPrices(p.CommittedPricePerHour),
+	}
+}
+
+func copyPrices(prices map[string]float64) map[string]float64 {
+	if len(prices) == 0 {
+		return nil
+	}
+	ret := make(map[string]float64, len(prices))
+	for k, v := range prices {
+		ret[k] = v
+	}
+	return ret
+}
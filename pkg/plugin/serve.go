@@ -0,0 +1,64 @@
+// Package plugin contains the contract between priceserver and the out-of-process provider plugins,
+// and the helpers for writing a plugin in go.
+package plugin
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"google.golang.org/grpc"
+	"k8s.io/klog"
+
+	pluginv1 "github.com/cloudpilot-ai/priceserver/pkg/plugin/api/v1"
+)
+
+const (
+	// AddressEnv is set by priceserver for the plugins it starts, it is like unix:///tmp/plugin.sock or
+	// 127.0.0.1:9000
+	AddressEnv = "PRICESERVER_PLUGIN_ADDRESS"
+
+	// MaxMessageSize allows listing all the instance prices of a large region in one message
+	MaxMessageSize = 64 << 20
+)
+
+// Listen listens on the address, like unix:///tmp/plugin.sock or 127.0.0.1:9000, the stale unix socket is removed.
+func Listen(address string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(address, "unix://"); ok {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		return net.Listen("unix", path)
+	}
+	return net.Listen("tcp", address)
+}
+
+// Serve serves the provider on the address from AddressEnv until SIGTERM or SIGINT is received.
+func Serve(provider pluginv1.PriceProviderServer) error {
+	address := os.Getenv(AddressEnv)
+	if address == "" {
+		return fmt.Errorf("%s is not set", AddressEnv)
+	}
+
+	listener, err := Listen(address)
+	if err != nil {
+		return err
+	}
+
+	server := grpc.NewServer(grpc.MaxSendMsgSize(MaxMessageSize))
+	pluginv1.RegisterPriceProviderServer(server, provider)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		<-signals
+		klog.Infof("Stopping the price provider plugin...")
+		server.GracefulStop()
+	}()
+
+	klog.Infof("Serving the price provider plugin on %s", address)
+	return server.Serve(listener)
+}
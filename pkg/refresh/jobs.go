@@ -0,0 +1,267 @@
+// Package refresh runs the refreshes requested by the admin apis as jobs.
+package refresh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"k8s.io/klog"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+	"github.com/cloudpilot-ai/priceserver/pkg/client"
+	"github.com/cloudpilot-ai/priceserver/pkg/scheduler"
+)
+
+// maxFinishedJobs bounds the finished jobs kept for polling, the oldest ones are dropped first
+const maxFinishedJobs = 200
+
+var (
+	ErrProviderNotFound = errors.New("provider is not found")
+	ErrJobNotFound      = errors.New("refresh job is not found")
+	ErrNotLeader        = errors.New("the replica is not the leader")
+)
+
+// Leadership tells which providers are refreshed by this replica, the followers serve the prices published by the
+// leader instead.
+type Leadership interface {
+	// Refreshes returns whether the provider is refreshed by this replica, and the identity of the leader
+	Refreshes(provider string) (ok bool, leader string)
+}
+
+type job struct {
+	apis.RefreshJob
+	refresher client.Refresher
+	// reporter is nil when the provider does not track its refreshes
+	reporter client.RefreshStatusReporter
+}
+
+// Manager runs the refresh jobs until its ctx is done. The jobs of one provider run one by one, and a job of the
+// same scope as a pending or running job is coalesced into it.
+type Manager struct {
+	ctx      context.Context
+	registry *client.ProviderRegistry
+	// scheduler keeps the jobs from overlapping the scheduled refreshes of the same source
+	scheduler *scheduler.Scheduler
+	// leadership is nil when every provider is refreshed by this replica
+	leadership Leadership
+
+	mutex sync.Mutex
+	jobs  map[string]*job
+	// active are the pending and the running jobs by the scope
+	active map[apis.RefreshScope]*job
+	// finished are the ids of the finished jobs in the finishing order
+	finished []string
+	// slots serialize the jobs of every provider
+	slots map[string]chan struct{}
+}
+
+func NewManager(ctx context.Context, registry *client.ProviderRegistry, scheduler *scheduler.Scheduler) *Manager {
+	return &Manager{
+		ctx:       ctx,
+		registry:  registry,
+		scheduler: scheduler,
+		jobs:      map[string]*job{},
+		active:    map[apis.RefreshScope]*job{},
+		slots:     map[string]chan struct{}{},
+	}
+}
+
+// SetLeadership rejects the jobs of the providers not refreshed by this replica, it should be called before any
+// job is submitted.
+func (m *Manager) SetLeadership(leadership Leadership) {
+	m.leadership = leadership
+}
+
+func (m *Manager) checkLeader(provider string) error {
+	if m.leadership == nil {
+		return nil
+	}
+	if ok, leader := m.leadership.Refreshes(provider); !ok {
+		return fmt.Errorf("%w, provider %s is refreshed by the leader %q", ErrNotLeader, provider, leader)
+	}
+	return nil
+}
+
+// Submit starts a job refreshing the scope, the pending or running job of the same scope is returned instead
+// with coalesced set.
+func (m *Manager) Submit(scope apis.RefreshScope) (ret apis.RefreshJob, coalesced bool, err error) {
+	p, ok := m.registry.Get(scope.Provider)
+	if !ok {
+		return ret, false, fmt.Errorf("%w: %s", ErrProviderNotFound, scope.Provider)
+	}
+	refresher, ok := p.Provider.(client.Refresher)
+	if !ok {
+		return ret, false, fmt.Errorf("provider %s does not support the refreshes", scope.Provider)
+	}
+	if err := refresher.ValidateRefresh(scope); err != nil {
+		return ret, false, err
+	}
+	if err := m.checkLeader(scope.Provider); err != nil {
+		return ret, false, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if j, ok := m.active[scope]; ok {
+		return m.snapshot(j), true, nil
+	}
+	j := &job{
+		RefreshJob: apis.RefreshJob{
+			ID:           uuid.NewString(),
+			RefreshScope: scope,
+			State:        apis.RefreshJobPending,
+			CreateTime:   time.Now(),
+		},
+		refresher: refresher,
+	}
+	j.reporter, _ = p.Provider.(client.RefreshStatusReporter)
+	m.jobs[j.ID] = j
+	m.active[scope] = j
+	slot, ok := m.slots[scope.Provider]
+	if !ok {
+		slot = make(chan struct{}, 1)
+		m.slots[scope.Provider] = slot
+	}
+
+	klog.Infof("Refresh job %s is submitted: %+v", j.ID, scope)
+	go m.run(j, slot)
+	return m.snapshot(j), false, nil
+}
+
+// Get returns the job, the refreshes of a running job are the ones attempted so far.
+func (m *Manager) Get(id string) (apis.RefreshJob, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return apis.RefreshJob{}, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+	}
+	return m.snapshot(j), nil
+}
+
+// List returns the kept jobs, the latest first.
+func (m *Manager) List() []apis.RefreshJob {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	ret := make([]apis.RefreshJob, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		ret = append(ret, m.snapshot(j))
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].CreateTime.After(ret[j].CreateTime)
+	})
+	return ret
+}
+
+func (m *Manager) run(j *job, slot chan struct{}) {
+	select {
+	case slot <- struct{}{}:
+	case <-m.ctx.Done():
+		m.finish(j, nil, m.ctx.Err())
+		return
+	}
+	defer func() {
+		<-slot
+	}()
+	// The leadership may be lost while the job is pending
+	if err := m.checkLeader(j.Provider); err != nil {
+		m.finish(j, nil, err)
+		return
+	}
+
+	startTime := time.Now()
+	m.mutex.Lock()
+	j.State = apis.RefreshJobRunning
+	j.StartTime = &startTime
+	m.mutex.Unlock()
+
+	klog.Infof("Refresh job %s is started", j.ID)
+	m.scheduler.Exclusive(m.ctx, j.Provider, j.Source, func(ctx context.Context) {
+		j.refresher.Refresh(ctx, j.RefreshScope)
+	})
+	m.finish(j, attempted(j, startTime), m.ctx.Err())
+}
+
+// finish completes the job, err is the error stopping the job before it is done.
+func (m *Manager) finish(j *job, refreshes []apis.RefreshStatus, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	finishTime := time.Now()
+	j.FinishTime = &finishTime
+	j.Refreshes = refreshes
+
+	failed := 0
+	for _, r := range refreshes {
+		if refreshFailed(r) {
+			failed++
+		}
+	}
+	switch {
+	case errors.Is(err, ErrNotLeader):
+		j.State = apis.RefreshJobFailed
+		j.Message = err.Error()
+	case err != nil:
+		j.State = apis.RefreshJobCanceled
+		j.Message = "the job is canceled by the shutdown"
+	case failed != 0:
+		j.State = apis.RefreshJobFailed
+		j.Message = fmt.Sprintf("%d of %d refreshes failed", failed, len(refreshes))
+	case len(refreshes) == 0 && j.reporter != nil:
+		j.State = apis.RefreshJobFailed
+		j.Message = "nothing is refreshed, the regions may fail to be listed"
+	default:
+		j.State = apis.RefreshJobSucceeded
+	}
+	klog.Infof("Refresh job %s is %s: %s", j.ID, j.State, j.Message)
+
+	delete(m.active, j.RefreshScope)
+	m.finished = append(m.finished, j.ID)
+	if len(m.finished) > maxFinishedJobs {
+		delete(m.jobs, m.finished[0])
+		m.finished = m.finished[1:]
+	}
+}
+
+// snapshot copies the job, it should be called with the mutex held.
+func (m *Manager) snapshot(j *job) apis.RefreshJob {
+	ret := j.RefreshJob
+	if j.State == apis.RefreshJobRunning {
+		ret.Refreshes = attempted(j, *j.StartTime)
+	}
+	if ret.Refreshes == nil {
+		ret.Refreshes = []apis.RefreshStatus{}
+	}
+	return ret
+}
+
+// attempted returns the refreshes of the scope attempted since the startTime.
+func attempted(j *job, startTime time.Time) []apis.RefreshStatus {
+	if j.reporter == nil {
+		return nil
+	}
+
+	var ret []apis.RefreshStatus
+	for _, r := range j.reporter.RefreshStatus().Refreshes {
+		if r.Source != j.Source || (j.Region != "" && r.Region != j.Region) {
+			continue
+		}
+		if r.LastAttemptTime == nil || r.LastAttemptTime.Before(startTime) {
+			continue
+		}
+		ret = append(ret, r)
+	}
+	return ret
+}
+
+func refreshFailed(r apis.RefreshStatus) bool {
+	return r.LastSuccessTime == nil || r.LastSuccessTime.Before(*r.LastAttemptTime)
+}
@@ -0,0 +1,357 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+	"github.com/cloudpilot-ai/priceserver/pkg/client"
+	"github.com/cloudpilot-ai/priceserver/pkg/config"
+	"github.com/cloudpilot-ai/priceserver/pkg/metrics"
+)
+
+// serviceAccountNamespaceFile is the namespace of the pod mounted with the service account token
+const serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// Replica campaigns for the leadership with a lease. The leader runs the refreshes and publishes the snapshots of
+// the providers, the followers load the latest published snapshots, so the replicas serve the same prices while
+// only the leader calls the cloud apis.
+type Replica struct {
+	client    kubernetes.Interface
+	config    config.LeaderElectionConfig
+	namespace string
+	identity  string
+	snapshots *SnapshotStore
+	// providers are the providers shared through the snapshots, they should implement client.Snapshotter
+	providers []*client.RegisteredProvider
+	// refresh runs the refreshes of the providers until the ctx is done
+	refresh func(ctx context.Context)
+
+	leading atomic.Bool
+	// leadMutex keeps a new term from starting before the refreshes of the previous one are stopped
+	leadMutex   sync.Mutex
+	leaderMutex sync.RWMutex
+	leader      string
+	// syncMutex keeps the followers from loading a snapshot once the leadership is taken, it also guards
+	// published
+	syncMutex sync.Mutex
+	// published are the manifests of the snapshots loaded or published, keyed by the provider
+	published map[string]SnapshotInfo
+}
+
+// NewReplica creates the replica of the namespace, the config should have been validated.
+func NewReplica(kubeClient kubernetes.Interface, c config.LeaderElectionConfig, namespace, identity string,
+	providers []*client.RegisteredProvider, refresh func(ctx context.Context)) (*Replica, error) {
+	for _, p := range providers {
+		if _, ok := p.Provider.(client.Snapshotter); !ok {
+			return nil, fmt.Errorf("provider %s does not support the snapshots", p.Name)
+		}
+	}
+
+	r := &Replica{
+		client:    kubeClient,
+		config:    c,
+		namespace: namespace,
+		identity:  identity,
+		snapshots: NewSnapshotStore(kubeClient, namespace, c.SnapshotPrefix, identity),
+		providers: providers,
+		refresh:   refresh,
+		published: map[string]SnapshotInfo{},
+	}
+	// The elector is created for every term, so the config is checked once here
+	if _, err := leaderelection.NewLeaderElector(r.electionConfig()); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Identity returns the identity of the replica in the leader election, the name of the pod or the hostname.
+func Identity() (string, error) {
+	if name := os.Getenv(apis.PodNameEnv); name != "" {
+		return name, nil
+	}
+	return os.Hostname()
+}
+
+// Namespace returns the configured namespace, or the namespace of the pod.
+func Namespace(configured string) (string, error) {
+	if configured != "" {
+		return configured, nil
+	}
+	if namespace := os.Getenv(apis.PodNamespaceEnv); namespace != "" {
+		return namespace, nil
+	}
+	data, err := os.ReadFile(serviceAccountNamespaceFile)
+	if err != nil {
+		return "", fmt.Errorf("the namespace is not set by the config file nor %s: %w", apis.PodNamespaceEnv, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func (r *Replica) electionConfig() leaderelection.LeaderElectionConfig {
+	return leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta:  metav1.ObjectMeta{Name: r.config.LeaseName, Namespace: r.namespace},
+			Client:     r.client.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{Identity: r.identity},
+		},
+		LeaseDuration: r.config.LeaseDuration.Duration,
+		RenewDeadline: r.config.RenewDeadline.Duration,
+		RetryPeriod:   r.config.RetryPeriod.Duration,
+		// The lease is released on shutdown, so a follower takes over without waiting for it to expire
+		ReleaseOnCancel: true,
+		Name:            r.config.LeaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: r.lead,
+			OnStoppedLeading: func() {},
+			OnNewLeader: func(identity string) {
+				klog.Infof("The leader of priceserver is %s", identity)
+				r.leaderMutex.Lock()
+				r.leader = identity
+				r.leaderMutex.Unlock()
+			},
+		},
+	}
+}
+
+// Run follows the published snapshots and campaigns for the leadership until the ctx is done, it returns after
+// the refreshes of the leader are stopped.
+func (r *Replica) Run(ctx context.Context) {
+	klog.Infof("Replica %s campaigns for the lease %s/%s", r.identity, r.namespace, r.config.LeaseName)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.follow(ctx)
+	}()
+
+	for {
+		elector, err := leaderelection.NewLeaderElector(r.electionConfig())
+		if err != nil {
+			klog.Errorf("Failed to create the leader elector: %v", err)
+			break
+		}
+		elector.Run(ctx)
+		if ctx.Err() != nil {
+			break
+		}
+		klog.Warningf("Replica %s lost the leadership, campaign again", r.identity)
+	}
+
+	wg.Wait()
+	// Wait for the term in progress
+	r.leadMutex.Lock()
+	defer r.leadMutex.Unlock()
+}
+
+// Refreshes returns whether the provider is refreshed by this replica and the identity of the leader, the
+// providers not shared through the snapshots are refreshed by every replica.
+func (r *Replica) Refreshes(provider string) (bool, string) {
+	r.leaderMutex.RLock()
+	leader := r.leader
+	r.leaderMutex.RUnlock()
+
+	for _, p := range r.providers {
+		if p.Name == provider {
+			return r.leading.Load(), leader
+		}
+	}
+	return true, leader
+}
+
+// lead runs the refreshes and publishes the snapshots until the ctx is canceled by losing the leadership.
+func (r *Replica) lead(ctx context.Context) {
+	r.leadMutex.Lock()
+	defer r.leadMutex.Unlock()
+
+	klog.Infof("Replica %s becomes the leader, start refreshing the prices", r.identity)
+	r.leading.Store(true)
+	metrics.Leader.Set(1)
+	defer func() {
+		r.leading.Store(false)
+		metrics.Leader.Set(0)
+		klog.Infof("Replica %s stops leading, the refreshes are stopped", r.identity)
+	}()
+
+	// The latest snapshots are loaded first, so the new leader continues from the prices served by the followers
+	r.syncMutex.Lock()
+	r.syncSnapshots(ctx)
+	r.syncMutex.Unlock()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.refresh(ctx)
+	}()
+	// Without any published snapshot, like the first start of the deployment, the initial sources are refreshed
+	// at once instead of waiting for the schedules
+	for _, p := range r.providers {
+		initialRefresher, ok := p.Provider.(client.InitialRefresher)
+		if !ok || r.publishedSnapshot(p.Name).Generation != "" {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			klog.Infof("No snapshot of provider %s is published, refresh the initial sources", p.Name)
+			initialRefresher.RefreshInitialSources(ctx)
+		}()
+	}
+
+	ticker := time.NewTicker(r.config.PublishInterval.Duration)
+	defer ticker.Stop()
+	for {
+		r.publishSnapshots(ctx)
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// follow loads the new snapshots while the replica is not the leader.
+func (r *Replica) follow(ctx context.Context) {
+	ticker := time.NewTicker(r.config.SyncInterval.Duration)
+	defer ticker.Stop()
+	for {
+		r.syncMutex.Lock()
+		if !r.leading.Load() {
+			r.syncSnapshots(ctx)
+		}
+		r.syncMutex.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Replica) publishedSnapshot(provider string) SnapshotInfo {
+	r.syncMutex.Lock()
+	defer r.syncMutex.Unlock()
+	return r.published[provider]
+}
+
+// syncSnapshots loads the published snapshots not loaded yet, it should be called with the syncMutex held. The
+// refresh times of the snapshots are recorded even when the prices are not changed, so the followers of a leader
+// not refreshing any more become stale like the leader.
+func (r *Replica) syncSnapshots(ctx context.Context) {
+	for _, p := range r.providers {
+		snapshotter := p.Provider.(client.Snapshotter)
+		startTime := time.Now()
+		info, err := r.syncSnapshot(ctx, p.Name, snapshotter)
+		switch {
+		case errors.Is(err, ErrSnapshotNotFound):
+			klog.V(2).Infof("No snapshot of provider %s is published yet", p.Name)
+		case err != nil:
+			klog.Errorf("Failed to sync the snapshot of provider %s: %v", p.Name, err)
+		default:
+			if info.Generation != r.published[p.Name].Generation {
+				klog.Infof("The snapshot %s of provider %s is loaded", info.Generation, p.Name)
+			}
+			r.published[p.Name] = info
+		}
+		snapshotter.ObserveSnapshotSync(startTime, info.RefreshTimes, err)
+	}
+}
+
+func (r *Replica) syncSnapshot(ctx context.Context, provider string, snapshotter client.Snapshotter) (SnapshotInfo,
+	error) {
+	info, err := r.snapshots.Published(ctx, provider)
+	if err != nil || info.Generation == r.published[provider].Generation {
+		return info, err
+	}
+
+	data, info, err := r.snapshots.Fetch(ctx, provider)
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	if err := snapshotter.LoadSnapshot(data); err != nil {
+		return SnapshotInfo{}, fmt.Errorf("failed to load the snapshot %s: %w", info.Generation, err)
+	}
+	return info, nil
+}
+
+// publishSnapshots publishes the prices changed since the last publish. The refresh times are updated alone when
+// the prices are refreshed without any change, and nothing is written when they are not refreshed.
+func (r *Replica) publishSnapshots(ctx context.Context) {
+	r.syncMutex.Lock()
+	defer r.syncMutex.Unlock()
+
+	for _, p := range r.providers {
+		snapshotter := p.Provider.(client.Snapshotter)
+		startTime := time.Now()
+		published := r.published[p.Name]
+		refreshTimes := latestRefreshTimes(published.RefreshTimes, snapshotter.LastRefreshTimes())
+		data, err := snapshotter.Snapshot()
+		if err != nil {
+			klog.Errorf("Failed to take the snapshot of provider %s: %v", p.Name, err)
+			snapshotter.ObserveSnapshotSync(startTime, published.RefreshTimes, err)
+			continue
+		}
+
+		var info SnapshotInfo
+		switch generation := Generation(data); {
+		case generation != published.Generation:
+			info, err = r.snapshots.Publish(ctx, p.Name, data, refreshTimes)
+			if err == nil {
+				klog.Infof("The snapshot %s of provider %s is published", info.Generation, p.Name)
+			}
+		case !sameRefreshTimes(refreshTimes, published.RefreshTimes):
+			info, err = r.snapshots.UpdateRefreshTimes(ctx, p.Name, generation, refreshTimes)
+		default:
+			info = published
+		}
+		if err != nil {
+			klog.Errorf("Failed to publish the snapshot of provider %s: %v", p.Name, err)
+			snapshotter.ObserveSnapshotSync(startTime, published.RefreshTimes, err)
+			continue
+		}
+		r.published[p.Name] = info
+		snapshotter.ObserveSnapshotSync(startTime, info.RefreshTimes, nil)
+	}
+}
+
+// latestRefreshTimes merges the refresh times, the latest one of every source is kept, so a new leader keeps the
+// refresh times of the snapshot loaded until it refreshes the sources itself.
+func latestRefreshTimes(refreshTimes ...map[string]time.Time) map[string]time.Time {
+	ret := map[string]time.Time{}
+	for _, times := range refreshTimes {
+		for source, refreshTime := range times {
+			refreshTime = refreshTime.UTC()
+			if refreshTime.After(ret[source]) {
+				ret[source] = refreshTime
+			}
+		}
+	}
+	return ret
+}
+
+func sameRefreshTimes(a, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for source, refreshTime := range a {
+		if !refreshTime.Equal(b[source]) {
+			return false
+		}
+	}
+	return true
+}
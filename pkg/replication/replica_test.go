@@ -0,0 +1,326 @@
+package replication
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+	"github.com/cloudpilot-ai/priceserver/pkg/client"
+	"github.com/cloudpilot-ai/priceserver/pkg/config"
+	"github.com/cloudpilot-ai/priceserver/pkg/health"
+)
+
+const (
+	testNamespace = "priceserver"
+	testProvider  = "test"
+)
+
+// fakeProvider serves a snapshot like the offline mode, the local refreshes are faked by the refresh times.
+type fakeProvider struct {
+	*client.SnapshotPriceClient
+	refreshTimes     map[string]time.Time
+	initialRefreshes atomic.Int32
+}
+
+func (p *fakeProvider) LastRefreshTimes() map[string]time.Time {
+	return p.refreshTimes
+}
+
+func (p *fakeProvider) RefreshInitialSources(ctx context.Context) {
+	p.initialRefreshes.Add(1)
+}
+
+// priceData returns the prices in the format of the snapshots taken by the providers.
+func priceData(t *testing.T, onDemandPrice float64) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(map[string]*apis.RegionalInstancePrice{
+		"us-east-1": {InstanceTypePrices: map[string]*apis.InstanceTypePrice{
+			"m5.large": {
+				InstanceTypeMetadata: apis.InstanceTypeMetadata{Arch: "amd64", VCPU: 2, Memory: 8},
+				Zones:                []string{"us-east-1a"},
+				OnDemandPricePerHour: onDemandPrice,
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal the prices: %v", err)
+	}
+	return data
+}
+
+func newFakeProvider(t *testing.T, onDemandPrice float64) *fakeProvider {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, client.SnapshotFileName(testProvider)), priceData(t, onDemandPrice),
+		0644); err != nil {
+		t.Fatalf("Failed to write the snapshot: %v", err)
+	}
+	c, err := client.NewSnapshotPriceClient(testProvider, dir)
+	if err != nil {
+		t.Fatalf("Failed to create the provider: %v", err)
+	}
+	return &fakeProvider{SnapshotPriceClient: c}
+}
+
+func newTestReplica(t *testing.T, kubeClient *fake.Clientset, identity string, provider *fakeProvider,
+	refresh func(ctx context.Context)) *Replica {
+	t.Helper()
+
+	if refresh == nil {
+		refresh = func(ctx context.Context) {}
+	}
+	providers := []*client.RegisteredProvider{{Name: testProvider, Service: "ec2", Provider: provider}}
+	r, err := NewReplica(kubeClient, config.Default().LeaderElection, testNamespace, identity, providers, refresh)
+	if err != nil {
+		t.Fatalf("Failed to create the replica: %v", err)
+	}
+	return r
+}
+
+func onDemandPrice(p client.Provider) float64 {
+	price := p.GetInstancePrice("us-east-1", "m5.large")
+	if price == nil {
+		return 0
+	}
+	return price.OnDemandPricePerHour
+}
+
+// configMapWrites counts the configmaps created, updated or deleted since the actions are cleared.
+func configMapWrites(kubeClient *fake.Clientset) int {
+	count := 0
+	for _, action := range kubeClient.Actions() {
+		if action.GetResource().Resource != "configmaps" {
+			continue
+		}
+		switch action.(type) {
+		case k8stesting.CreateAction, k8stesting.UpdateAction, k8stesting.DeleteAction:
+			count++
+		}
+	}
+	return count
+}
+
+func TestFollowerSyncsPublishedSnapshot(t *testing.T) {
+	ctx := context.Background()
+	kubeClient := fake.NewSimpleClientset()
+	leaderStore := NewSnapshotStore(kubeClient, testNamespace, config.Default().LeaderElection.SnapshotPrefix,
+		"leader")
+	provider := newFakeProvider(t, 0.1)
+	follower := newTestReplica(t, kubeClient, "follower", provider, nil)
+
+	// Nothing is loaded before any snapshot is published
+	follower.syncSnapshots(ctx)
+	if price := onDemandPrice(provider); price != 0.1 {
+		t.Fatalf("Expected the builtin price 0.1, got %v", price)
+	}
+
+	spotTime := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+	published, err := leaderStore.Publish(ctx, testProvider, priceData(t, 0.2),
+		map[string]time.Time{apis.SourceSpot: spotTime})
+	if err != nil {
+		t.Fatalf("Failed to publish the snapshot: %v", err)
+	}
+	follower.syncSnapshots(ctx)
+	if price := onDemandPrice(provider); price != 0.2 {
+		t.Errorf("Expected the published price 0.2, got %v", price)
+	}
+	if generation := follower.publishedSnapshot(testProvider).Generation; generation != published.Generation {
+		t.Errorf("Expected the generation %s loaded, got %s", published.Generation, generation)
+	}
+	status := provider.RefreshStatus()
+	if !status.PublishedRefreshTimes[apis.SourceSpot].Equal(spotTime) {
+		t.Errorf("Expected the published spot refresh time %v, got %v", spotTime, status.PublishedRefreshTimes)
+	}
+
+	// The snapshot of the same generation is not fetched again
+	kubeClient.ClearActions()
+	follower.syncSnapshots(ctx)
+	if len(kubeClient.Actions()) != 1 {
+		t.Errorf("Expected the first chunk read only, got %v", kubeClient.Actions())
+	}
+}
+
+func TestFollowerReadinessFollowsSnapshotAge(t *testing.T) {
+	ctx := context.Background()
+	kubeClient := fake.NewSimpleClientset()
+	leaderStore := NewSnapshotStore(kubeClient, testNamespace, config.Default().LeaderElection.SnapshotPrefix,
+		"leader")
+	provider := newFakeProvider(t, 0.1)
+	follower := newTestReplica(t, kubeClient, "follower", provider, nil)
+
+	registry := client.NewProviderRegistry()
+	if err := registry.Register(testProvider, "ec2", provider); err != nil {
+		t.Fatalf("Failed to register the provider: %v", err)
+	}
+	checker := health.NewChecker(registry, config.Default().Health)
+	checker.SetLeadership(func(provider string) bool { return false })
+
+	// The leader stopped refreshing the spot prices 7 hours ago, beyond the default staleness of 6 hours
+	staleTime := time.Now().Add(-7 * time.Hour).UTC().Truncate(time.Second)
+	published, err := leaderStore.Publish(ctx, testProvider, priceData(t, 0.2),
+		map[string]time.Time{apis.SourceSpot: staleTime})
+	if err != nil {
+		t.Fatalf("Failed to publish the snapshot: %v", err)
+	}
+	// The syncs succeed, but the follower serves the stale prices
+	for i := 0; i < 2; i++ {
+		follower.syncSnapshots(ctx)
+		report := checker.Readiness()
+		if report.Healthy || len(report.Failures) != 1 || report.Failures[0].Check != health.CheckFresh ||
+			report.Failures[0].Source != apis.SourceSpot {
+			t.Fatalf("Expected the stale spot prices to fail the readiness, got %+v", report)
+		}
+	}
+
+	// The leader refreshed the spot prices without any change
+	if _, err := leaderStore.UpdateRefreshTimes(ctx, testProvider, published.Generation,
+		map[string]time.Time{apis.SourceSpot: time.Now().UTC()}); err != nil {
+		t.Fatalf("Failed to update the refresh times: %v", err)
+	}
+	follower.syncSnapshots(ctx)
+	if report := checker.Readiness(); !report.Healthy {
+		t.Errorf("Expected the follower ready, got %+v", report)
+	}
+}
+
+func TestLeaderPublishesOnGenerationChange(t *testing.T) {
+	ctx := context.Background()
+	kubeClient := fake.NewSimpleClientset()
+	provider := newFakeProvider(t, 0.1)
+	refreshTime := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+	provider.refreshTimes = map[string]time.Time{apis.SourceSpot: refreshTime}
+	leader := newTestReplica(t, kubeClient, "leader", provider, nil)
+	store := NewSnapshotStore(kubeClient, testNamespace, config.Default().LeaderElection.SnapshotPrefix, "reader")
+
+	leader.publishSnapshots(ctx)
+	first, err := store.Published(ctx, testProvider)
+	if err != nil {
+		t.Fatalf("Expected the snapshot published: %v", err)
+	}
+	if first.Publisher != "leader" || !first.RefreshTimes[apis.SourceSpot].Equal(refreshTime) {
+		t.Errorf("Unexpected manifest %+v", first)
+	}
+
+	// Neither the prices nor the refresh times are changed
+	kubeClient.ClearActions()
+	leader.publishSnapshots(ctx)
+	if writes := configMapWrites(kubeClient); writes != 0 {
+		t.Errorf("Expected nothing published without any change, got %d writes", writes)
+	}
+
+	// The prices are refreshed without any change, only the refresh times of the first chunk are updated
+	provider.refreshTimes = map[string]time.Time{apis.SourceSpot: refreshTime.Add(time.Minute)}
+	kubeClient.ClearActions()
+	leader.publishSnapshots(ctx)
+	if writes := configMapWrites(kubeClient); writes != 1 {
+		t.Errorf("Expected the first chunk updated only, got %d writes", writes)
+	}
+	updated, err := store.Published(ctx, testProvider)
+	if err != nil {
+		t.Fatalf("Failed to read the snapshot: %v", err)
+	}
+	if updated.Generation != first.Generation ||
+		!updated.RefreshTimes[apis.SourceSpot].Equal(refreshTime.Add(time.Minute)) {
+		t.Errorf("Expected the refresh times updated for the same generation, got %+v", updated)
+	}
+
+	// The prices are changed
+	if err := provider.LoadSnapshot(priceData(t, 0.3)); err != nil {
+		t.Fatalf("Failed to load the prices: %v", err)
+	}
+	leader.publishSnapshots(ctx)
+	data, changed, err := store.Fetch(ctx, testProvider)
+	if err != nil {
+		t.Fatalf("Failed to fetch the snapshot: %v", err)
+	}
+	if changed.Generation == first.Generation || changed.Generation != Generation(data) {
+		t.Errorf("Expected a new generation published, got %+v", changed)
+	}
+}
+
+func TestNewLeaderLoadsSnapshotBeforeRefreshing(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset()
+	oldLeaderStore := NewSnapshotStore(kubeClient, testNamespace, config.Default().LeaderElection.SnapshotPrefix,
+		"old-leader")
+	spotTime := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+	if _, err := oldLeaderStore.Publish(context.Background(), testProvider, priceData(t, 0.2),
+		map[string]time.Time{apis.SourceSpot: spotTime}); err != nil {
+		t.Fatalf("Failed to publish the snapshot: %v", err)
+	}
+
+	provider := newFakeProvider(t, 0.1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	refreshed := make(chan float64, 1)
+	leader := newTestReplica(t, kubeClient, "new-leader", provider, func(ctx context.Context) {
+		refreshed <- onDemandPrice(provider)
+		<-ctx.Done()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		leader.lead(ctx)
+	}()
+	select {
+	case price := <-refreshed:
+		if price != 0.2 {
+			t.Errorf("Expected the published price 0.2 loaded before the refreshes, got %v", price)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("The refreshes are not started")
+	}
+	cancel()
+	<-done
+
+	if n := provider.initialRefreshes.Load(); n != 0 {
+		t.Errorf("Expected no initial refresh with a snapshot published, got %d", n)
+	}
+	// The refresh times of the loaded snapshot are kept until the new leader refreshes the sources
+	info := leader.publishedSnapshot(testProvider)
+	if info.Publisher != "old-leader" || !info.RefreshTimes[apis.SourceSpot].Equal(spotTime) {
+		t.Errorf("Expected the snapshot of the old leader kept, got %+v", info)
+	}
+}
+
+func TestNewLeaderRefreshesInitialSourcesWithoutSnapshot(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset()
+	provider := newFakeProvider(t, 0.1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	refreshed := make(chan struct{}, 1)
+	leader := newTestReplica(t, kubeClient, "leader", provider, func(ctx context.Context) {
+		refreshed <- struct{}{}
+		<-ctx.Done()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		leader.lead(ctx)
+	}()
+	select {
+	case <-refreshed:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("The refreshes are not started")
+	}
+	cancel()
+	<-done
+
+	if n := provider.initialRefreshes.Load(); n != 1 {
+		t.Errorf("Expected the initial sources refreshed once, got %d", n)
+	}
+	if info := leader.publishedSnapshot(testProvider); info.Publisher != "leader" {
+		t.Errorf("Expected the builtin prices published by the leader, got %+v", info)
+	}
+}
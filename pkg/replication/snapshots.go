@@ -0,0 +1,307 @@
+// Package replication runs the refreshes on the leader of the replicas only, the prices are shared with the
+// followers through the snapshots in the configmaps.
+package replication
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// chunkSize keeps every configmap well below the 1MiB limit of the objects
+	chunkSize = 512 * 1024
+	chunkKey  = "snapshot.json.gz"
+
+	managedByLabel        = "app.kubernetes.io/managed-by"
+	managedByValue        = "priceserver"
+	providerLabel         = "priceserver.cloudpilot.ai/provider"
+	generationAnnotation  = "priceserver.cloudpilot.ai/generation"
+	chunksAnnotation      = "priceserver.cloudpilot.ai/chunks"
+	publisherAnnotation   = "priceserver.cloudpilot.ai/publisher"
+	publishTimeAnnotation = "priceserver.cloudpilot.ai/publish-time"
+	// refreshTimesAnnotation is the last successful refresh of every source by the publisher, in json
+	refreshTimesAnnotation = "priceserver.cloudpilot.ai/refresh-times"
+)
+
+var ErrSnapshotNotFound = errors.New("no snapshot is published")
+
+// SnapshotInfo is the manifest of a published snapshot, it is kept in the annotations of the first chunk.
+type SnapshotInfo struct {
+	Generation  string
+	Publisher   string
+	PublishTime time.Time
+	// RefreshTimes are the last successful refreshes of the sources by the publisher, keyed by the source, so the
+	// readers judge the age of the prices by them instead of the time of the sync
+	RefreshTimes map[string]time.Time
+}
+
+// SnapshotStore keeps the snapshots of the providers in the configmaps. A snapshot is gzipped and split into the
+// configmaps <prefix>-<provider>, <prefix>-<provider>-1 and so on, the first one is written last with the count of
+// the chunks, so the readers never see a partially written snapshot. The generation of a snapshot is the sha256 of
+// the uncompressed snapshot.
+type SnapshotStore struct {
+	client    kubernetes.Interface
+	namespace string
+	prefix    string
+	// identity is the publisher recorded in the snapshots
+	identity string
+}
+
+func NewSnapshotStore(client kubernetes.Interface, namespace, prefix, identity string) *SnapshotStore {
+	return &SnapshotStore{client: client, namespace: namespace, prefix: prefix, identity: identity}
+}
+
+// Generation returns the generation of the snapshot.
+func Generation(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *SnapshotStore) chunkName(provider string, i int) string {
+	if i == 0 {
+		return fmt.Sprintf("%s-%s", s.prefix, provider)
+	}
+	return fmt.Sprintf("%s-%s-%d", s.prefix, provider, i)
+}
+
+// Publish writes the snapshot of the provider with the refresh times of the sources, the chunks left by a larger
+// snapshot are deleted.
+func (s *SnapshotStore) Publish(ctx context.Context, provider string, data []byte,
+	refreshTimes map[string]time.Time) (SnapshotInfo, error) {
+	info := SnapshotInfo{
+		Generation:   Generation(data),
+		Publisher:    s.identity,
+		PublishTime:  time.Now().UTC().Truncate(time.Second),
+		RefreshTimes: refreshTimes,
+	}
+	manifest, err := info.annotations()
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return SnapshotInfo{}, err
+	}
+	if err := w.Close(); err != nil {
+		return SnapshotInfo{}, err
+	}
+
+	compressed := buf.Bytes()
+	var chunks [][]byte
+	for len(compressed) > chunkSize {
+		chunks = append(chunks, compressed[:chunkSize])
+		compressed = compressed[chunkSize:]
+	}
+	chunks = append(chunks, compressed)
+
+	previousChunks := 0
+	if first, err := s.client.CoreV1().ConfigMaps(s.namespace).Get(ctx, s.chunkName(provider, 0),
+		metav1.GetOptions{}); err == nil {
+		previousChunks, _ = strconv.Atoi(first.Annotations[chunksAnnotation])
+	} else if !apierrors.IsNotFound(err) {
+		return SnapshotInfo{}, err
+	}
+
+	// The first chunk is the manifest of the others, so it is written last
+	for i := len(chunks) - 1; i >= 0; i-- {
+		annotations := map[string]string{generationAnnotation: info.Generation}
+		if i == 0 {
+			annotations = manifest
+			annotations[chunksAnnotation] = strconv.Itoa(len(chunks))
+		}
+		if err := s.apply(ctx, provider, s.chunkName(provider, i), annotations, chunks[i]); err != nil {
+			return SnapshotInfo{}, err
+		}
+	}
+
+	for i := len(chunks); i < previousChunks; i++ {
+		err := s.client.CoreV1().ConfigMaps(s.namespace).Delete(ctx, s.chunkName(provider, i),
+			metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return SnapshotInfo{}, err
+		}
+	}
+	return info, nil
+}
+
+// UpdateRefreshTimes updates the refresh times of the published snapshot without rewriting the chunks, like when
+// the prices are refreshed without any change. It fails when the snapshot is not of the generation.
+func (s *SnapshotStore) UpdateRefreshTimes(ctx context.Context, provider, generation string,
+	refreshTimes map[string]time.Time) (SnapshotInfo, error) {
+	first, err := s.getChunk(ctx, provider, 0)
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	if first.Annotations[generationAnnotation] != generation {
+		return SnapshotInfo{}, fmt.Errorf("the snapshot %s is replaced by %s", generation,
+			first.Annotations[generationAnnotation])
+	}
+
+	info := SnapshotInfo{
+		Generation:   generation,
+		Publisher:    s.identity,
+		PublishTime:  time.Now().UTC().Truncate(time.Second),
+		RefreshTimes: refreshTimes,
+	}
+	manifest, err := info.annotations()
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	for k, v := range manifest {
+		first.Annotations[k] = v
+	}
+	// The update is rejected on conflict, so a snapshot replaced since the read is never stamped
+	if _, err := s.client.CoreV1().ConfigMaps(s.namespace).Update(ctx, first, metav1.UpdateOptions{}); err != nil {
+		return SnapshotInfo{}, err
+	}
+	return info, nil
+}
+
+func (info SnapshotInfo) annotations() (map[string]string, error) {
+	refreshTimes, err := json.Marshal(info.RefreshTimes)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		generationAnnotation:   info.Generation,
+		publisherAnnotation:    info.Publisher,
+		publishTimeAnnotation:  info.PublishTime.Format(time.RFC3339),
+		refreshTimesAnnotation: string(refreshTimes),
+	}, nil
+}
+
+// parseSnapshotInfo reads the manifest from the annotations of the first chunk, the snapshots published without
+// the refresh times are taken as never refreshed.
+func parseSnapshotInfo(annotations map[string]string) (SnapshotInfo, error) {
+	info := SnapshotInfo{
+		Generation:   annotations[generationAnnotation],
+		Publisher:    annotations[publisherAnnotation],
+		RefreshTimes: map[string]time.Time{},
+	}
+	if info.Generation == "" {
+		return SnapshotInfo{}, fmt.Errorf("the snapshot has no generation")
+	}
+	if value := annotations[publishTimeAnnotation]; value != "" {
+		publishTime, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return SnapshotInfo{}, fmt.Errorf("invalid publish time %q of the snapshot: %w", value, err)
+		}
+		info.PublishTime = publishTime
+	}
+	if value := annotations[refreshTimesAnnotation]; value != "" {
+		if err := json.Unmarshal([]byte(value), &info.RefreshTimes); err != nil {
+			return SnapshotInfo{}, fmt.Errorf("invalid refresh times %q of the snapshot: %w", value, err)
+		}
+	}
+	return info, nil
+}
+
+// apply creates or replaces the configmap of a chunk.
+func (s *SnapshotStore) apply(ctx context.Context, provider, name string, annotations map[string]string,
+	chunk []byte) error {
+	configMaps := s.client.CoreV1().ConfigMaps(s.namespace)
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   s.namespace,
+			Labels:      map[string]string{managedByLabel: managedByValue, providerLabel: provider},
+			Annotations: annotations,
+		},
+		BinaryData: map[string][]byte{chunkKey: chunk},
+	}
+
+	current, err := configMaps.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = configMaps.Create(ctx, configMap, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	configMap.ResourceVersion = current.ResourceVersion
+	_, err = configMaps.Update(ctx, configMap, metav1.UpdateOptions{})
+	return err
+}
+
+// Published returns the manifest of the published snapshot of the provider, only the first chunk is read. It
+// returns ErrSnapshotNotFound when none is published.
+func (s *SnapshotStore) Published(ctx context.Context, provider string) (SnapshotInfo, error) {
+	first, err := s.getChunk(ctx, provider, 0)
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	return parseSnapshotInfo(first.Annotations)
+}
+
+// Fetch reads the published snapshot of the provider, it fails when the snapshot is replaced during the read, and
+// the caller should try again later.
+func (s *SnapshotStore) Fetch(ctx context.Context, provider string) (data []byte, info SnapshotInfo, err error) {
+	first, err := s.getChunk(ctx, provider, 0)
+	if err != nil {
+		return nil, SnapshotInfo{}, err
+	}
+	info, err = parseSnapshotInfo(first.Annotations)
+	if err != nil {
+		return nil, SnapshotInfo{}, err
+	}
+	generation := info.Generation
+	count, err := strconv.Atoi(first.Annotations[chunksAnnotation])
+	if err != nil || count < 1 {
+		return nil, SnapshotInfo{}, fmt.Errorf("invalid chunk count %q of the snapshot",
+			first.Annotations[chunksAnnotation])
+	}
+
+	var compressed []byte
+	compressed = append(compressed, first.BinaryData[chunkKey]...)
+	for i := 1; i < count; i++ {
+		chunk, err := s.getChunk(ctx, provider, i)
+		if err != nil {
+			return nil, SnapshotInfo{}, err
+		}
+		if chunk.Annotations[generationAnnotation] != generation {
+			return nil, SnapshotInfo{}, fmt.Errorf("the snapshot is replaced during the read")
+		}
+		compressed = append(compressed, chunk.BinaryData[chunkKey]...)
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, SnapshotInfo{}, fmt.Errorf("failed to decompress the snapshot: %w", err)
+	}
+	data, err = io.ReadAll(r)
+	if err != nil {
+		return nil, SnapshotInfo{}, fmt.Errorf("failed to decompress the snapshot: %w", err)
+	}
+	if Generation(data) != generation {
+		return nil, SnapshotInfo{}, fmt.Errorf("the snapshot does not match its generation %s", generation)
+	}
+	return data, info, nil
+}
+
+func (s *SnapshotStore) getChunk(ctx context.Context, provider string, i int) (*corev1.ConfigMap, error) {
+	configMap, err := s.client.CoreV1().ConfigMaps(s.namespace).Get(ctx, s.chunkName(provider, i),
+		metav1.GetOptions{})
+	if apierrors.IsNotFound(err) && i == 0 {
+		return nil, fmt.Errorf("%w for provider %s", ErrSnapshotNotFound, provider)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return configMap, nil
+}
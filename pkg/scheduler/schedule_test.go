@@ -0,0 +1,151 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronField(t *testing.T) {
+	for _, tc := range []struct {
+		field    string
+		min, max int
+		expected []int
+	}{
+		{field: "*", min: 0, max: 6, expected: []int{0, 1, 2, 3, 4, 5, 6}},
+		{field: "5", min: 0, max: 59, expected: []int{5}},
+		{field: "1,3", min: 0, max: 59, expected: []int{1, 3}},
+		{field: "1-3", min: 0, max: 59, expected: []int{1, 2, 3}},
+		{field: "*/20", min: 0, max: 59, expected: []int{0, 20, 40}},
+		// a start with a step runs to the max
+		{field: "5/20", min: 0, max: 59, expected: []int{5, 25, 45}},
+		{field: "10-30/10", min: 0, max: 59, expected: []int{10, 20, 30}},
+		{field: "1-2,10-30/10,59", min: 0, max: 59, expected: []int{1, 2, 10, 20, 30, 59}},
+		{field: "*/5", min: 1, max: 12, expected: []int{1, 6, 11}},
+	} {
+		bits, err := parseCronField(tc.field, tc.min, tc.max)
+		if err != nil {
+			t.Errorf("Failed to parse %q: %v", tc.field, err)
+			continue
+		}
+		var expected uint64
+		for _, i := range tc.expected {
+			expected |= 1 << i
+		}
+		if bits != expected {
+			t.Errorf("Expected %q to match %v, got %b", tc.field, tc.expected, bits)
+		}
+	}
+
+	for _, field := range []string{"", "a", "1-", "-1", "*/0", "*/x", "*/-1", "60", "5-1", "1,", "1-2-3"} {
+		if _, err := parseCronField(field, 0, 59); err == nil {
+			t.Errorf("Expected %q to be invalid", field)
+		}
+	}
+}
+
+func TestParseCron(t *testing.T) {
+	for _, expr := range []string{
+		"* * * * *",
+		" */15 2-4 * * 1,3 ",
+		"0 0 29 2 *",
+		"0 0 * * 7",
+		"@hourly",
+		"@daily",
+		"@weekly",
+		"@monthly",
+	} {
+		s, err := ParseCron(expr)
+		if err != nil {
+			t.Errorf("Failed to parse %q: %v", expr, err)
+			continue
+		}
+		if s.String() != expr {
+			t.Errorf("Expected the schedule to be shown as %q, got %q", expr, s.String())
+		}
+	}
+
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"@yearly",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		// February 30 never comes
+		"0 0 30 2 *",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("Expected %q to be invalid", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	parse := func(value string) time.Time {
+		ret, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", value, err)
+		}
+		return ret
+	}
+
+	for _, tc := range []struct {
+		expr     string
+		from     string
+		expected string
+	}{
+		{expr: "*/15 * * * *", from: "2024-01-31T10:07:30Z", expected: "2024-01-31T10:15:00Z"},
+		{expr: "@hourly", from: "2024-01-31T10:07:30Z", expected: "2024-01-31T11:00:00Z"},
+		{expr: "@daily", from: "2024-01-31T10:07:30Z", expected: "2024-02-01T00:00:00Z"},
+		// the next run is after the matching time itself
+		{expr: "7 10 * * *", from: "2024-01-31T10:07:00Z", expected: "2024-02-01T10:07:00Z"},
+		// the months without the 31st are skipped
+		{expr: "0 0 31 * *", from: "2024-01-31T10:07:30Z", expected: "2024-03-31T00:00:00Z"},
+		// February 29 of the next leap year
+		{expr: "0 0 29 2 *", from: "2024-03-01T00:00:00Z", expected: "2028-02-29T00:00:00Z"},
+		{expr: "0 0 1 1 *", from: "2024-12-31T23:59:00Z", expected: "2025-01-01T00:00:00Z"},
+		// 7 is sunday as well as 0
+		{expr: "30 2 * * 7", from: "2024-01-31T10:07:30Z", expected: "2024-02-04T02:30:00Z"},
+		{expr: "30 2 * * 0", from: "2024-01-31T10:07:30Z", expected: "2024-02-04T02:30:00Z"},
+		{expr: "0 0 * * 1-5", from: "2024-02-02T23:59:00Z", expected: "2024-02-05T00:00:00Z"},
+		// either the day of month or the day of week matches when both are restricted
+		{expr: "0 0 15 * 1", from: "2024-01-31T10:07:30Z", expected: "2024-02-05T00:00:00Z"},
+		{expr: "0 0 15 * 1", from: "2024-02-13T00:00:00Z", expected: "2024-02-15T00:00:00Z"},
+		// both have to match when one of them is *
+		{expr: "0 0 * 3 1", from: "2024-01-31T10:07:30Z", expected: "2024-03-04T00:00:00Z"},
+		// the times are evaluated in UTC
+		{expr: "0 0 * * *", from: "2024-02-01T07:00:00+08:00", expected: "2024-02-01T00:00:00Z"},
+	} {
+		s, err := ParseCron(tc.expr)
+		if err != nil {
+			t.Errorf("Failed to parse %q: %v", tc.expr, err)
+			continue
+		}
+		if next := s.Next(parse(tc.from)); !next.Equal(parse(tc.expected)) {
+			t.Errorf("Expected the next run of %q after %s to be %s, got %s", tc.expr, tc.from, tc.expected, next)
+		}
+	}
+}
+
+func TestCronNextGivesUp(t *testing.T) {
+	// The dates never matching can not be parsed, so the schedule is built directly
+	s := &cronSchedule{expr: "0 0 30 2 *", minute: 1, hour: 1, dom: 1 << 30, month: 1 << 2, dow: 1<<7 - 1,
+		domRestricted: true}
+	if next := s.Next(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)); !next.IsZero() {
+		t.Errorf("Expected no next run of February 30, got %s", next)
+	}
+}
+
+func TestEvery(t *testing.T) {
+	s := Every(30 * time.Minute)
+	from := time.Date(2024, 1, 31, 10, 7, 30, 0, time.UTC)
+	if next := s.Next(from); !next.Equal(from.Add(30 * time.Minute)) {
+		t.Errorf("Expected the next run after 30m, got %s", next)
+	}
+	if s.String() != "every 30m0s" {
+		t.Errorf("Expected the schedule to be shown as every 30m0s, got %q", s.String())
+	}
+}
@@ -0,0 +1,320 @@
+// Package scheduler runs the refreshes of the sources as independent jobs, so a slow refresh of one source does
+// not delay the others.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+
+	"github.com/cloudpilot-ai/priceserver/pkg/apis"
+)
+
+var ErrJobNotFound = errors.New("scheduled job is not found")
+
+// Spec is how a job is scheduled, it is read again before every run.
+type Spec struct {
+	Schedule Schedule
+	// Jitter delays every run by a random duration up to it, so the replicas started together do not refresh
+	// in lockstep
+	Jitter time.Duration
+	// MaxRuntime cancels the run taking longer, 0 means no limit
+	MaxRuntime time.Duration
+}
+
+// Job refreshes one source of a provider.
+type Job struct {
+	Provider string
+	Source   string
+	// Spec returns the current spec of the job, so the reloaded settings apply from the next run
+	Spec func() Spec
+	Run  func(ctx context.Context)
+}
+
+type jobKey struct {
+	provider string
+	source   string
+}
+
+type job struct {
+	Job
+	// lock is held by the run in progress, either scheduled or requested by Exclusive, so the runs of the job
+	// never overlap
+	lock chan struct{}
+	// rescheduled wakes the loop to read the spec again
+	rescheduled chan struct{}
+
+	// The fields below are guarded by the mutex of the scheduler
+	paused         bool
+	running        bool
+	spec           Spec
+	nextRunTime    *time.Time
+	lastStartTime  *time.Time
+	lastFinishTime *time.Time
+	lastTimedOut   bool
+	skipped        int64
+}
+
+// Scheduler runs every registered job in its own loop until the ctx of Run is done.
+type Scheduler struct {
+	mutex sync.Mutex
+	jobs  map[jobKey]*job
+	// ctx is set by Run, the jobs registered later are started at once
+	ctx context.Context
+	wg  sync.WaitGroup
+}
+
+func New() *Scheduler {
+	return &Scheduler{jobs: map[jobKey]*job{}}
+}
+
+// Register adds the job, a provider registers one job per source.
+func (s *Scheduler) Register(j Job) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := jobKey{provider: j.Provider, source: j.Source}
+	if _, ok := s.jobs[key]; ok {
+		return fmt.Errorf("job %s/%s is already registered", j.Provider, j.Source)
+	}
+	added := &job{
+		Job:         j,
+		lock:        make(chan struct{}, 1),
+		rescheduled: make(chan struct{}, 1),
+	}
+	s.jobs[key] = added
+	if s.ctx != nil {
+		s.start(s.ctx, added)
+	}
+	return nil
+}
+
+// Run starts the jobs and waits for them to stop after the ctx is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mutex.Lock()
+	s.ctx = ctx
+	for _, j := range s.jobs {
+		s.start(ctx, j)
+	}
+	s.mutex.Unlock()
+
+	<-ctx.Done()
+	s.wg.Wait()
+}
+
+func (s *Scheduler) start(ctx context.Context, j *job) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.loop(ctx, j)
+	}()
+}
+
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	for {
+		spec := j.Spec()
+		next := spec.Schedule.Next(time.Now())
+		if !next.IsZero() && spec.Jitter > 0 {
+			next = next.Add(rand.N(spec.Jitter))
+		}
+
+		s.mutex.Lock()
+		j.spec = spec
+		j.nextRunTime = nil
+		if !next.IsZero() && !j.paused {
+			j.nextRunTime = &next
+		}
+		s.mutex.Unlock()
+
+		if next.IsZero() {
+			klog.Errorf("Job %s/%s has no next run by schedule %s", j.Provider, j.Source, spec.Schedule)
+		}
+		fired, done := wait(ctx, j, next)
+		if done {
+			return
+		}
+		if !fired {
+			continue
+		}
+
+		s.mutex.Lock()
+		paused := j.paused
+		s.mutex.Unlock()
+		if paused {
+			klog.V(4).Infof("Job %s/%s is paused, skip the run", j.Provider, j.Source)
+			continue
+		}
+		s.runScheduled(ctx, j, spec)
+	}
+}
+
+// wait waits until the next run, fired is false if the job is rescheduled first, and done is true if the ctx is
+// done first. The zero next waits for the rescheduling only.
+func wait(ctx context.Context, j *job, next time.Time) (fired, done bool) {
+	var timer <-chan time.Time
+	if !next.IsZero() {
+		t := time.NewTimer(time.Until(next))
+		defer t.Stop()
+		timer = t.C
+	}
+
+	select {
+	case <-timer:
+		return true, false
+	case <-j.rescheduled:
+		return false, false
+	case <-ctx.Done():
+		return false, true
+	}
+}
+
+// runScheduled skips the run if the job is being run by Exclusive.
+func (s *Scheduler) runScheduled(ctx context.Context, j *job, spec Spec) {
+	select {
+	case j.lock <- struct{}{}:
+	default:
+		klog.Infof("Job %s/%s is already running, skip the scheduled run", j.Provider, j.Source)
+		s.mutex.Lock()
+		j.skipped++
+		s.mutex.Unlock()
+		return
+	}
+	defer func() {
+		<-j.lock
+	}()
+	s.run(ctx, j, spec.MaxRuntime, j.Run)
+}
+
+func (s *Scheduler) run(ctx context.Context, j *job, maxRuntime time.Duration, run func(ctx context.Context)) {
+	startTime := time.Now()
+	s.mutex.Lock()
+	j.running = true
+	j.lastStartTime = &startTime
+	s.mutex.Unlock()
+
+	runCtx, cancel := ctx, context.CancelFunc(func() {})
+	if maxRuntime > 0 {
+		runCtx, cancel = context.WithTimeout(ctx, maxRuntime)
+	}
+	run(runCtx)
+	timedOut := errors.Is(runCtx.Err(), context.DeadlineExceeded)
+	cancel()
+	if timedOut {
+		klog.Errorf("Job %s/%s is canceled after the max runtime %v", j.Provider, j.Source, maxRuntime)
+	}
+
+	finishTime := time.Now()
+	s.mutex.Lock()
+	j.running = false
+	j.lastFinishTime = &finishTime
+	j.lastTimedOut = timedOut
+	s.mutex.Unlock()
+}
+
+// Exclusive runs fn as a run of the job after the run in progress finishes, the scheduled runs are skipped while
+// it is running. fn is run directly if the job is not registered.
+func (s *Scheduler) Exclusive(ctx context.Context, provider, source string, fn func(ctx context.Context)) {
+	s.mutex.Lock()
+	j, ok := s.jobs[jobKey{provider: provider, source: source}]
+	s.mutex.Unlock()
+	if !ok {
+		fn(ctx)
+		return
+	}
+
+	select {
+	case j.lock <- struct{}{}:
+	case <-ctx.Done():
+		return
+	}
+	defer func() {
+		<-j.lock
+	}()
+
+	s.mutex.Lock()
+	maxRuntime := j.spec.MaxRuntime
+	s.mutex.Unlock()
+	s.run(ctx, j, maxRuntime, fn)
+}
+
+// Reschedule reads the specs of all the jobs again, like after the settings are reloaded.
+func (s *Scheduler) Reschedule() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, j := range s.jobs {
+		notify(j.rescheduled)
+	}
+}
+
+// Pause skips the runs of the job until it is resumed, the run in progress is not canceled.
+func (s *Scheduler) Pause(provider, source string) error {
+	return s.setPaused(provider, source, true)
+}
+
+// Resume schedules the runs of the paused job again from now.
+func (s *Scheduler) Resume(provider, source string) error {
+	return s.setPaused(provider, source, false)
+}
+
+func (s *Scheduler) setPaused(provider, source string, paused bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	j, ok := s.jobs[jobKey{provider: provider, source: source}]
+	if !ok {
+		return fmt.Errorf("%w: %s/%s", ErrJobNotFound, provider, source)
+	}
+	if j.paused != paused {
+		j.paused = paused
+		klog.Infof("Job %s/%s is paused: %v", provider, source, paused)
+		notify(j.rescheduled)
+	}
+	return nil
+}
+
+// List returns the jobs sorted by the provider and the source.
+func (s *Scheduler) List() []apis.ScheduledJob {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	ret := make([]apis.ScheduledJob, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		job := apis.ScheduledJob{
+			Provider:       j.Provider,
+			Source:         j.Source,
+			Paused:         j.paused,
+			Running:        j.running,
+			NextRunTime:    j.nextRunTime,
+			LastStartTime:  j.lastStartTime,
+			LastFinishTime: j.lastFinishTime,
+			LastTimedOut:   j.lastTimedOut,
+			Skipped:        j.skipped,
+		}
+		if j.spec.Schedule != nil {
+			job.Schedule = j.spec.Schedule.String()
+			job.Jitter = j.spec.Jitter.String()
+			job.MaxRuntime = j.spec.MaxRuntime.String()
+		}
+		ret = append(ret, job)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Provider != ret[j].Provider {
+			return ret[i].Provider < ret[j].Provider
+		}
+		return ret[i].Source < ret[j].Source
+	})
+	return ret
+}
+
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,253 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testInterval = 10 * time.Millisecond
+	// testTimeout bounds the waits for the runs, it is long enough for the slow test machines
+	testTimeout = 5 * time.Second
+)
+
+// startScheduler runs the scheduler with the jobs until the test ends.
+func startScheduler(t *testing.T, jobs ...Job) *Scheduler {
+	t.Helper()
+
+	s := New()
+	for _, j := range jobs {
+		if err := s.Register(j); err != nil {
+			t.Fatalf("Failed to register job %s/%s: %v", j.Provider, j.Source, err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return s
+}
+
+func everySpec(spec Spec) func() Spec {
+	if spec.Schedule == nil {
+		spec.Schedule = Every(testInterval)
+	}
+	return func() Spec { return spec }
+}
+
+func waitFor(t *testing.T, ch <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(testTimeout):
+		t.Fatalf("Timed out waiting for %s", what)
+	}
+}
+
+func TestSchedulerRegister(t *testing.T) {
+	s := New()
+	j := Job{Provider: "p", Source: "s", Spec: everySpec(Spec{}), Run: func(ctx context.Context) {}}
+	if err := s.Register(j); err != nil {
+		t.Fatalf("Failed to register the job: %v", err)
+	}
+	if err := s.Register(j); err == nil {
+		t.Errorf("Expected the job registered twice to be rejected")
+	}
+	if err := s.Pause("p", "unknown"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Expected ErrJobNotFound pausing an unknown job, got %v", err)
+	}
+}
+
+func TestSchedulerRuns(t *testing.T) {
+	ran := make(chan struct{}, 1)
+	s := startScheduler(t, Job{Provider: "p", Source: "s", Spec: everySpec(Spec{}), Run: func(ctx context.Context) {
+		notify(ran)
+	}})
+	waitFor(t, ran, "the first run")
+	waitFor(t, ran, "the second run")
+
+	jobs := s.List()
+	if len(jobs) != 1 || jobs[0].Schedule != "every 10ms" || jobs[0].LastStartTime == nil {
+		t.Errorf("Expected the job to be listed with its runs, got %+v", jobs)
+	}
+}
+
+func TestSchedulerSkipsOverlappingRuns(t *testing.T) {
+	var scheduledRuns atomic.Int64
+	s := startScheduler(t, Job{Provider: "p", Source: "s", Spec: everySpec(Spec{}), Run: func(ctx context.Context) {
+		scheduledRuns.Add(1)
+	}})
+
+	started, release := make(chan struct{}), make(chan struct{})
+	exclusiveDone := make(chan struct{})
+	go func() {
+		s.Exclusive(context.Background(), "p", "s", func(ctx context.Context) {
+			close(started)
+			<-release
+		})
+		close(exclusiveDone)
+	}()
+	waitFor(t, started, "the exclusive run")
+
+	// The scheduled runs are skipped while the exclusive run holds the job
+	deadline := time.Now().Add(testTimeout)
+	for s.List()[0].Skipped < 2 && time.Now().Before(deadline) {
+		time.Sleep(testInterval)
+	}
+	runsDuringExclusive := scheduledRuns.Load()
+	time.Sleep(5 * testInterval)
+	if scheduledRuns.Load() != runsDuringExclusive {
+		t.Errorf("Expected no scheduled run while the exclusive run is in progress")
+	}
+	if jobs := s.List(); jobs[0].Skipped < 2 || !jobs[0].Running {
+		t.Errorf("Expected the scheduled runs to be skipped while the job is running, got %+v", jobs[0])
+	}
+
+	close(release)
+	waitFor(t, exclusiveDone, "the exclusive run to finish")
+	for scheduledRuns.Load() == runsDuringExclusive && time.Now().Before(deadline) {
+		time.Sleep(testInterval)
+	}
+	if scheduledRuns.Load() == runsDuringExclusive {
+		t.Errorf("Expected the scheduled runs to resume after the exclusive run")
+	}
+}
+
+func TestSchedulerExclusiveWaitsForTheRunInProgress(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	var runs atomic.Int64
+	s := startScheduler(t, Job{Provider: "p", Source: "s", Spec: everySpec(Spec{}), Run: func(ctx context.Context) {
+		if runs.Add(1) == 1 {
+			close(started)
+			<-release
+		}
+	}})
+	waitFor(t, started, "the scheduled run")
+
+	exclusiveDone := make(chan struct{})
+	go func() {
+		s.Exclusive(context.Background(), "p", "s", func(ctx context.Context) {})
+		close(exclusiveDone)
+	}()
+	select {
+	case <-exclusiveDone:
+		t.Fatalf("Expected the exclusive run to wait for the scheduled run in progress")
+	case <-time.After(5 * testInterval):
+	}
+
+	// The canceled ctx gives up waiting for the job
+	canceledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var canceledRan bool
+	s.Exclusive(canceledCtx, "p", "s", func(ctx context.Context) { canceledRan = true })
+	if canceledRan {
+		t.Errorf("Expected the exclusive run with the canceled ctx to give up")
+	}
+
+	close(release)
+	waitFor(t, exclusiveDone, "the exclusive run after the scheduled run")
+}
+
+func TestSchedulerExclusiveUnregistered(t *testing.T) {
+	s := New()
+	var ran bool
+	s.Exclusive(context.Background(), "p", "s", func(ctx context.Context) { ran = true })
+	if !ran {
+		t.Errorf("Expected the function of an unregistered job to run directly")
+	}
+}
+
+func TestSchedulerPauseResume(t *testing.T) {
+	ran := make(chan struct{}, 1)
+	s := New()
+	if err := s.Register(Job{Provider: "p", Source: "s", Spec: everySpec(Spec{}), Run: func(ctx context.Context) {
+		notify(ran)
+	}}); err != nil {
+		t.Fatalf("Failed to register the job: %v", err)
+	}
+	if err := s.Pause("p", "s"); err != nil {
+		t.Fatalf("Failed to pause the job: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	select {
+	case <-ran:
+		t.Fatalf("Expected no run of the paused job")
+	case <-time.After(5 * testInterval):
+	}
+	if jobs := s.List(); !jobs[0].Paused || jobs[0].NextRunTime != nil {
+		t.Errorf("Expected the paused job without the next run, got %+v", jobs[0])
+	}
+
+	if err := s.Resume("p", "s"); err != nil {
+		t.Fatalf("Failed to resume the job: %v", err)
+	}
+	waitFor(t, ran, "the run after resuming")
+	if jobs := s.List(); jobs[0].Paused {
+		t.Errorf("Expected the job resumed, got %+v", jobs[0])
+	}
+}
+
+func TestSchedulerMaxRuntime(t *testing.T) {
+	canceled := make(chan struct{}, 1)
+	s := startScheduler(t, Job{
+		Provider: "p",
+		Source:   "s",
+		Spec:     everySpec(Spec{MaxRuntime: testInterval}),
+		Run: func(ctx context.Context) {
+			<-ctx.Done()
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				notify(canceled)
+			}
+		},
+	})
+	waitFor(t, canceled, "the run canceled by the max runtime")
+
+	deadline := time.Now().Add(testTimeout)
+	for !s.List()[0].LastTimedOut && time.Now().Before(deadline) {
+		time.Sleep(testInterval)
+	}
+	if jobs := s.List(); !jobs[0].LastTimedOut || jobs[0].MaxRuntime != "10ms" {
+		t.Errorf("Expected the last run timed out by the max runtime 10ms, got %+v", jobs[0])
+	}
+}
+
+func TestSchedulerReschedule(t *testing.T) {
+	var interval atomic.Int64
+	interval.Store(int64(time.Hour))
+	ran := make(chan struct{}, 1)
+	s := startScheduler(t, Job{
+		Provider: "p",
+		Source:   "s",
+		Spec:     func() Spec { return Spec{Schedule: Every(time.Duration(interval.Load()))} },
+		Run:      func(ctx context.Context) { notify(ran) },
+	})
+
+	select {
+	case <-ran:
+		t.Fatalf("Expected no run within the hour")
+	case <-time.After(5 * testInterval):
+	}
+	// The spec is read again, so the shorter interval applies at once
+	interval.Store(int64(testInterval))
+	s.Reschedule()
+	waitFor(t, ran, "the run after rescheduling")
+}
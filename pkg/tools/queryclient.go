@@ -37,8 +37,15 @@ type QueryClientImpl struct {
 }
 
 const (
-	AlibabaCloudProvider = "alibabacloud"
-	AWSCloudProvider     = "aws"
+	AlibabaCloudProvider = apis.AlibabaCloudProviderName
+	AWSCloudProvider     = apis.AWSProviderName
+	GCPCloudProvider     = apis.GCPProviderName
+	AzureCloudProvider   = apis.AzureProviderName
+	TencentCloudProvider = apis.TencentCloudProviderName
+	HuaweiCloudProvider  = apis.HuaweiCloudProviderName
+	OCIProvider          = apis.OCIProviderName
+	VolcengineProvider   = apis.VolcengineProviderName
+	StaticProvider       = apis.StaticProviderName
 )
 
 func NewQueryClient(endpoint, cloudProvider, region string) (QueryClientInterface, error) {
@@ -57,6 +64,41 @@ func NewQueryClient(endpoint, cloudProvider, region string) (QueryClientInterfac
 		if err != nil {
 			return nil, err
 		}
+	case GCPCloudProvider:
+		queryBaseUrl, err = url.JoinPath(endpoint, "/api/v1/gcp/gce")
+		if err != nil {
+			return nil, err
+		}
+	case AzureCloudProvider:
+		queryBaseUrl, err = url.JoinPath(endpoint, "/api/v1/azure/vm")
+		if err != nil {
+			return nil, err
+		}
+	case TencentCloudProvider:
+		queryBaseUrl, err = url.JoinPath(endpoint, "/api/v1/tencentcloud/cvm")
+		if err != nil {
+			return nil, err
+		}
+	case HuaweiCloudProvider:
+		queryBaseUrl, err = url.JoinPath(endpoint, "/api/v1/huaweicloud/ecs")
+		if err != nil {
+			return nil, err
+		}
+	case OCIProvider:
+		queryBaseUrl, err = url.JoinPath(endpoint, "/api/v1/oci/compute")
+		if err != nil {
+			return nil, err
+		}
+	case VolcengineProvider:
+		queryBaseUrl, err = url.JoinPath(endpoint, "/api/v1/volcengine/ecs")
+		if err != nil {
+			return nil, err
+		}
+	case StaticProvider:
+		queryBaseUrl, err = url.JoinPath(endpoint, "/api/v1/static/compute")
+		if err != nil {
+			return nil, err
+		}
 	default:
 		return nil, fmt.Errorf("unsupported cloud provider: %s", cloudProvider)
 	}
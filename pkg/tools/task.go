@@ -2,7 +2,6 @@ package tools
 
 import (
 	"context"
-	"sync"
 
 	"k8s.io/client-go/util/workqueue"
 )
@@ -25,15 +24,11 @@ func (p *ParallelTask) Add(params []interface{}) {
 	p.items = append(p.items, params)
 }
 
-func (p *ParallelTask) Process() {
-	var wg sync.WaitGroup
-
-	wg.Add(len(p.items))
+// Process handles the items in parallel, the items not started yet are skipped when the ctx is done.
+func (p *ParallelTask) Process(ctx context.Context) {
 	parallelFunc := func(piece int) {
-		defer wg.Done()
 		p.handlerFunc(p.items[piece]...)
 	}
 
-	workqueue.ParallelizeUntil(context.Background(), 50, len(p.items), parallelFunc)
-	wg.Wait()
+	workqueue.ParallelizeUntil(ctx, 50, len(p.items), parallelFunc)
 }